@@ -3,5 +3,9 @@ package adapters
 import "github.com/spriigan/broker/user/interface/controller"
 
 type AppController struct {
-	User interface{ controller.UserController }
+	User    interface{ controller.UserController }
+	Audit   interface{ controller.AuditController }
+	Webhook interface{ controller.WebhookController }
+	Change  interface{ controller.ChangeController }
+	Health  interface{ controller.HealthController }
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// healthcheck probes a running broker over HTTP and exits non-zero unless it
+// answers 200, so it can be used as a container HEALTHCHECK.
+func healthcheck(args []string) int {
+	port, ok := os.LookupEnv("PORT")
+	if !ok || port == "" {
+		port = "8000"
+	}
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:"+port, "address of the broker to check")
+	ready := fs.Bool("ready", false, "check /readyz, which includes user-service, instead of /healthz")
+	timeout := fs.Duration("timeout", 3*time.Second, "time allowed for the check")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	path := "/healthz"
+	if *ready {
+		path = "/readyz"
+	}
+	client := http.Client{Timeout: *timeout}
+	res, err := client.Get("http://" + *addr + path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		return 1
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintln(os.Stderr, "unhealthy:", res.Status)
+		return 1
+	}
+	fmt.Println(res.Status)
+	return 0
+}
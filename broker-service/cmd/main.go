@@ -1,19 +1,66 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/spriigan/broker/config"
 	"github.com/spriigan/broker/infrastructure"
 	"github.com/spriigan/broker/infrastructure/router"
 	"github.com/spriigan/broker/registry"
+	"golang.org/x/exp/slog"
 )
 
 func main() {
-	app := infrastructure.Application()
-	register := registry.New()
-	appController, close := register.NewAppController()
-	defer close()
-	if err := app.Serve(router.Route(appController)); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(healthcheck(os.Args[2:]))
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
+	if opts.Print {
+		if err = cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	app := infrastructure.Application(cfg)
+	app.SetupLogging()
+	shutdownTracing, err := app.SetupTracing()
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	register := registry.New(cfg, app.Metrics)
+	appController, closeApp := register.NewAppController(ctx)
+	err = app.Serve(ctx, router.Route(appController, cfg.HTTP, register.NewRateLimit(), app.Metrics))
+	stop()
+	closeApp()
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("spans were lost", "error", err)
+	}
+	if err != nil {
+		fatal("server failed", err)
+	}
+	slog.Info("server stopped")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spriigan/RPApp/platform/configload"
+	"github.com/spriigan/broker/ratelimit"
+	"golang.org/x/exp/slog"
+)
+
+// Options are the command line switches about loading the configuration,
+// ValidationError lists every problem found in it.
+type (
+	Options         = configload.Options
+	ValidationError = configload.ValidationError
+)
+
+// Config is everything the broker can be tuned with. Each value is taken from,
+// in increasing order of precedence, its default, the YAML config file, the
+// environment variable and the command line flag named in its tags.
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	UserService UserService `yaml:"user_service"`
+	Changes     Changes     `yaml:"changes"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Log         Log         `yaml:"log"`
+}
+
+type HTTP struct {
+	Port              int           `yaml:"port" env:"PORT" flag:"port" usage:"port the HTTP server listens on"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"http-read-timeout" usage:"time allowed to read a whole request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" flag:"http-read-header-timeout" usage:"time allowed to read request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"http-write-timeout" usage:"time allowed to write a response, change streams extend it themselves"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"http-idle-timeout" usage:"how long keep-alive connections are kept open"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long in-flight requests may run after a shutdown signal"`
+	AdminToken        string        `yaml:"admin_token" env:"ADMIN_TOKEN" flag:"admin-token" secret:"true" usage:"bearer token of the admin routes, they are disabled when empty"`
+	// RequestTimeout bounds every request but the change streams, including
+	// the calls it makes to user-service. RouteTimeouts overrides it per route,
+	// keyed by method and path as routed, e.g. "GET /user/:username".
+	RequestTimeout time.Duration            `yaml:"request_timeout" env:"REQUEST_TIMEOUT" flag:"request-timeout" usage:"time allowed to handle a request"`
+	RouteTimeouts  map[string]time.Duration `yaml:"route_timeouts" env:"ROUTE_TIMEOUTS" flag:"route-timeouts" usage:"per route request timeouts, like 'POST /user=3s,GET /audit=5s'"`
+	// TrustedProxies may set X-Forwarded-For and X-Actor, the client address
+	// of requests from anywhere else is the one they connect from and their
+	// actor is anonymous unless they carry the admin token.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma separated addresses or CIDRs of the proxies in front of the broker"`
+	// APIKeys are the keys callers may identify with in X-API-Key, any other
+	// key is ignored.
+	APIKeys []string `yaml:"api_keys" env:"API_KEYS" flag:"api-keys" secret:"true" usage:"comma separated keys callers may send in X-API-Key"`
+	// MetricsPath is neither rate limited nor behind the admin token, keep it
+	// off the public listener with the proxy when that matters.
+	MetricsPath string `yaml:"metrics_path" env:"METRICS_PATH" flag:"metrics-path" usage:"path Prometheus metrics are served on, empty disables them"`
+}
+
+type UserService struct {
+	// Resolver "dns" spreads calls over every record of Addr, "static" over
+	// the Addrs listed.
+	Resolver            string        `yaml:"resolver" env:"USER_SERVICE_RESOLVER" flag:"user-service-resolver" usage:"how replicas are found, dns or static"`
+	Addr                string        `yaml:"addr" env:"USER_SERVICE_ADDR" flag:"user-service-addr" usage:"host:port of user-service, resolved through DNS"`
+	Addrs               []string      `yaml:"addrs" env:"USER_SERVICE_ADDRS" flag:"user-service-addrs" usage:"comma separated host:port of every replica for the static resolver"`
+	Balancer            string        `yaml:"balancer" env:"USER_SERVICE_BALANCER" flag:"user-service-balancer" usage:"how calls are spread over replicas, round_robin or least_request"`
+	HealthCheck         bool          `yaml:"health_check" env:"USER_SERVICE_HEALTH_CHECK" flag:"user-service-health-check" usage:"take replicas reporting NOT_SERVING out of rotation"`
+	ReconnectMaxBackoff time.Duration `yaml:"reconnect_max_backoff" env:"RECONNECT_MAX_BACKOFF" flag:"reconnect-max-backoff" usage:"longest pause between attempts to reconnect to user-service"`
+	Retry               Retry         `yaml:"retry"`
+	Breaker             Breaker       `yaml:"breaker"`
+	TLS                 TLS           `yaml:"tls"`
+}
+
+// TLS secures the connection to user-service. With mtls the broker presents
+// its certificate, which user-service requires for everything but health
+// checks. Certificate files are read again when they change.
+type TLS struct {
+	Mode           string        `yaml:"mode" env:"TLS_MODE" flag:"tls-mode" usage:"off, tls or mtls"`
+	CertFile       string        `yaml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"PEM client certificate presented with mtls"`
+	KeyFile        string        `yaml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"PEM key of the client certificate"`
+	CAFile         string        `yaml:"ca_file" env:"TLS_CA_FILE" flag:"tls-ca-file" usage:"PEM bundle user-service is verified against, the system roots when empty"`
+	ServerName     string        `yaml:"server_name" env:"TLS_SERVER_NAME" flag:"tls-server-name" usage:"name expected in the certificate of user-service, the dialed host when empty"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" flag:"tls-reload-interval" usage:"how often the certificate files are checked for changes"`
+	// DevDir replaces the files with ones issued by a throwaway CA kept in
+	// the directory, shared with user-service in local setups.
+	DevDir string `yaml:"dev_dir" env:"TLS_DEV_DIR" flag:"tls-dev-dir" usage:"generate a throwaway CA and certificates in this directory, never in production"`
+}
+
+// Retry is how userclient sends the idempotent calls again, the reads and
+// DeleteByUsername.
+type Retry struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"RETRY_MAX_ATTEMPTS" flag:"retry-max-attempts" usage:"attempts of an idempotent call to user-service, 1 disables retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"RETRY_INITIAL_BACKOFF" flag:"retry-initial-backoff" usage:"pause before the first retry, doubled after each one"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"RETRY_MAX_BACKOFF" flag:"retry-max-backoff" usage:"longest pause between retries"`
+}
+
+type Breaker struct {
+	FailureThreshold int           `yaml:"failure_threshold" env:"BREAKER_FAILURE_THRESHOLD" flag:"breaker-failure-threshold" usage:"consecutive failures of a method that open its circuit"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env:"BREAKER_OPEN_TIMEOUT" flag:"breaker-open-timeout" usage:"how long an open circuit fails calls fast before probing again"`
+}
+
+// RateLimit gives every caller a token bucket per route. Limits are written
+// like "10/1m", ten requests a minute with bursts of up to ten.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit-enabled" usage:"reject callers going over their limits with 429"`
+	// KeyBy lists what callers are told apart by, the first one a request
+	// carries wins. "user" only counts X-Actor from http.trusted_proxies and
+	// "api_key" only the keys in http.api_keys.
+	KeyBy   []string          `yaml:"key_by" env:"RATE_LIMIT_KEY_BY" flag:"rate-limit-key-by" usage:"comma separated ip, user and api_key, in order of preference"`
+	Default string            `yaml:"default" env:"RATE_LIMIT_DEFAULT" flag:"rate-limit-default" usage:"limit shared by the routes without their own, none when empty"`
+	Routes  map[string]string `yaml:"routes" env:"RATE_LIMIT_ROUTES" flag:"rate-limit-routes" usage:"per route limits, like 'POST /user=5/1m,GET /user=100/1m'"`
+	Backend string            `yaml:"backend" env:"RATE_LIMIT_BACKEND" flag:"rate-limit-backend" usage:"where buckets are kept, memory or redis to share them between instances"`
+	Redis   Redis             `yaml:"redis"`
+}
+
+type Redis struct {
+	Addr     string        `yaml:"addr" env:"RATE_LIMIT_REDIS_ADDR" flag:"rate-limit-redis-addr" usage:"host:port of the Redis protocol server"`
+	Password string        `yaml:"password" env:"RATE_LIMIT_REDIS_PASSWORD" flag:"rate-limit-redis-password" secret:"true" usage:"password sent with AUTH"`
+	PoolSize int           `yaml:"pool_size" env:"RATE_LIMIT_REDIS_POOL_SIZE" flag:"rate-limit-redis-pool-size" usage:"idle connections kept open"`
+	Timeout  time.Duration `yaml:"timeout" env:"RATE_LIMIT_REDIS_TIMEOUT" flag:"rate-limit-redis-timeout" usage:"time allowed to take a token before the request is let through"`
+}
+
+// Tracing records spans of requests and of the calls they make. Requests
+// from a caller that sampled its trace are always recorded, SampleRatio
+// applies to the others.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"where spans go, none, stdout or otlp"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"base URL of the OTLP/HTTP collector, spans are posted to /v1/traces"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"share of new traces that are recorded, from 0 to 1"`
+}
+
+type Changes struct {
+	History   int           `yaml:"history" env:"CHANGES_HISTORY" flag:"changes-history" usage:"recent changes kept to resume streams from memory"`
+	Buffer    int           `yaml:"buffer" env:"CHANGES_BUFFER" flag:"changes-buffer" usage:"changes queued per client before it is dropped"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"CHANGES_HEARTBEAT" flag:"changes-heartbeat" usage:"interval of keep-alive pings on change streams"`
+	// Origins are the admin dashboards browsers may open change WebSockets
+	// from, besides the broker's own origin.
+	Origins []string `yaml:"origins" env:"CHANGES_ORIGINS" flag:"changes-origins" usage:"comma separated origins, like https://admin.example.com, change WebSockets may be opened from"`
+}
+
+// Log is where the level starts, it can be changed at runtime through
+// /debug/log-level.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum level logged, debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"how records are written, json or text"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Port:              8000,
+			ReadTimeout:       5 * time.Second,
+			ReadHeaderTimeout: 3 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       20 * time.Second,
+			ShutdownTimeout:   10 * time.Second,
+			RequestTimeout:    time.Second,
+			RouteTimeouts: map[string]time.Duration{
+				// bcrypt makes these the slowest calls.
+				"POST /user":  3 * time.Second,
+				"PATCH /user": 3 * time.Second,
+			},
+			MetricsPath: "/metrics",
+		},
+		UserService: UserService{
+			Resolver:            "dns",
+			Addr:                "user-service:8000",
+			Balancer:            "round_robin",
+			HealthCheck:         true,
+			ReconnectMaxBackoff: 5 * time.Second,
+			Retry: Retry{
+				MaxAttempts:    3,
+				InitialBackoff: 50 * time.Millisecond,
+				MaxBackoff:     500 * time.Millisecond,
+			},
+			Breaker: Breaker{
+				FailureThreshold: 5,
+				OpenTimeout:      10 * time.Second,
+			},
+			TLS: TLS{
+				Mode:           "off",
+				ReloadInterval: 30 * time.Second,
+			},
+		},
+		Changes: Changes{
+			History:   1024,
+			Buffer:    256,
+			Heartbeat: 15 * time.Second,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			KeyBy:   []string{"ip"},
+			Default: "120/1m",
+			Routes: map[string]string{
+				"POST /user": "10/1m",
+			},
+			Backend: "memory",
+			Redis: Redis{
+				Addr:     "redis:6379",
+				PoolSize: 10,
+				Timeout:  100 * time.Millisecond,
+			},
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://otel-collector:4318",
+			SampleRatio: 1,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Load reads the configuration for args, the command line without the program
+// name. It returns flag.ErrHelp when -h was asked for.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	cfg := Default()
+	opts, err := configload.Load("broker", &cfg, args, lookupEnv)
+	if err != nil {
+		return cfg, opts, err
+	}
+	return cfg, opts, cfg.Validate()
+}
+
+// Validate reports every invalid value together with where it can be fixed.
+func (c *Config) Validate() error {
+	var problems ValidationError
+	check := func(ok bool, path, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s, set it with %s", path, fmt.Sprintf(format, args...), configload.Describe(c, path)))
+		}
+	}
+	positive := func(d time.Duration, path string) {
+		check(d > 0, path, "must be a positive duration, got %s", d)
+	}
+
+	check(c.HTTP.Port > 0 && c.HTTP.Port < 65536, "http.port", "must be between 1 and 65535, got %d", c.HTTP.Port)
+	positive(c.HTTP.ReadTimeout, "http.read_timeout")
+	positive(c.HTTP.ReadHeaderTimeout, "http.read_header_timeout")
+	check(c.HTTP.ReadHeaderTimeout <= c.HTTP.ReadTimeout, "http.read_header_timeout", "must not be longer than http.read_timeout (%s)", c.HTTP.ReadTimeout)
+	positive(c.HTTP.WriteTimeout, "http.write_timeout")
+	positive(c.HTTP.IdleTimeout, "http.idle_timeout")
+	positive(c.HTTP.ShutdownTimeout, "http.shutdown_timeout")
+	hostPort := func(addr string) bool {
+		_, port, err := net.SplitHostPort(addr)
+		return err == nil && port != ""
+	}
+	switch c.UserService.Resolver {
+	case "dns":
+		check(hostPort(c.UserService.Addr), "user_service.addr", "must be host:port, got %q", c.UserService.Addr)
+	case "static":
+		check(len(c.UserService.Addrs) > 0, "user_service.addrs", "must list at least one replica for the static resolver")
+		for _, addr := range c.UserService.Addrs {
+			check(hostPort(addr), "user_service.addrs", "must only hold host:port, got %q", addr)
+		}
+	default:
+		check(false, "user_service.resolver", "must be dns or static, got %q", c.UserService.Resolver)
+	}
+	check(c.UserService.Balancer == "round_robin" || c.UserService.Balancer == "least_request",
+		"user_service.balancer", "must be round_robin or least_request, got %q", c.UserService.Balancer)
+	positive(c.HTTP.RequestTimeout, "http.request_timeout")
+	check(c.HTTP.RequestTimeout < c.HTTP.WriteTimeout, "http.request_timeout", "must be shorter than http.write_timeout (%s) so errors reach the client", c.HTTP.WriteTimeout)
+	routes := make([]string, 0, len(c.HTTP.RouteTimeouts))
+	for route := range c.HTTP.RouteTimeouts {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		timeout := c.HTTP.RouteTimeouts[route]
+		check(len(strings.Fields(route)) == 2, "http.route_timeouts", "key %q must be a method and a path, like \"GET /user/:username\"", route)
+		check(timeout > 0 && timeout < c.HTTP.WriteTimeout, "http.route_timeouts", "%q must be positive and shorter than http.write_timeout (%s), got %s", route, c.HTTP.WriteTimeout, timeout)
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "http.trusted_proxies", "must only hold addresses or CIDRs, got %q", proxy)
+	}
+	check(c.HTTP.MetricsPath == "" || strings.HasPrefix(c.HTTP.MetricsPath, "/"), "http.metrics_path", "must start with /, got %q", c.HTTP.MetricsPath)
+	positive(c.UserService.ReconnectMaxBackoff, "user_service.reconnect_max_backoff")
+	check(c.UserService.Retry.MaxAttempts > 0, "user_service.retry.max_attempts", "must be at least 1, got %d", c.UserService.Retry.MaxAttempts)
+	positive(c.UserService.Retry.InitialBackoff, "user_service.retry.initial_backoff")
+	check(c.UserService.Retry.MaxBackoff >= c.UserService.Retry.InitialBackoff, "user_service.retry.max_backoff", "must not be shorter than user_service.retry.initial_backoff (%s)", c.UserService.Retry.InitialBackoff)
+	check(c.UserService.Breaker.FailureThreshold > 0, "user_service.breaker.failure_threshold", "must be at least 1, got %d", c.UserService.Breaker.FailureThreshold)
+	positive(c.UserService.Breaker.OpenTimeout, "user_service.breaker.open_timeout")
+	switch tls := c.UserService.TLS; tls.Mode {
+	case "off":
+	case "tls", "mtls":
+		positive(tls.ReloadInterval, "user_service.tls.reload_interval")
+		if tls.DevDir == "" {
+			check(tls.Mode != "mtls" || tls.CertFile != "", "user_service.tls.cert_file", "is required with user_service.tls.mode mtls")
+			check(tls.Mode != "mtls" || tls.KeyFile != "", "user_service.tls.key_file", "is required with user_service.tls.mode mtls")
+			check((tls.CertFile == "") == (tls.KeyFile == ""), "user_service.tls.key_file", "must be set together with user_service.tls.cert_file")
+		}
+	default:
+		check(false, "user_service.tls.mode", "must be off, tls or mtls, got %q", tls.Mode)
+	}
+	check(c.Changes.History > 0, "changes.history", "must be at least 1, got %d", c.Changes.History)
+	check(c.Changes.Buffer > 0, "changes.buffer", "must be at least 1, got %d", c.Changes.Buffer)
+	positive(c.Changes.Heartbeat, "changes.heartbeat")
+	for _, origin := range c.Changes.Origins {
+		u, err := url.Parse(origin)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "", "changes.origins", "must only hold origins like https://admin.example.com, got %q", origin)
+	}
+	if c.RateLimit.Enabled {
+		for _, kind := range c.RateLimit.KeyBy {
+			check(kind == "ip" || kind == "user" || kind == "api_key", "rate_limit.key_by", "must only hold ip, user or api_key, got %q", kind)
+			check(kind != "user" || len(c.HTTP.TrustedProxies) > 0, "rate_limit.key_by", "must not hold user without http.trusted_proxies to vouch for it")
+			check(kind != "api_key" || len(c.HTTP.APIKeys) > 0, "rate_limit.key_by", "must not hold api_key without http.api_keys to check it against")
+		}
+		if c.RateLimit.Default != "" {
+			_, err := ratelimit.ParseLimit(c.RateLimit.Default)
+			check(err == nil, "rate_limit.default", "%v", err)
+		}
+		routes := make([]string, 0, len(c.RateLimit.Routes))
+		for route := range c.RateLimit.Routes {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+		for _, route := range routes {
+			check(len(strings.Fields(route)) == 2, "rate_limit.routes", "key %q must be a method and a path, like \"GET /user/:username\"", route)
+			_, err := ratelimit.ParseLimit(c.RateLimit.Routes[route])
+			check(err == nil, "rate_limit.routes", "%q %v", route, err)
+		}
+		switch c.RateLimit.Backend {
+		case "memory":
+		case "redis":
+			check(hostPort(c.RateLimit.Redis.Addr), "rate_limit.redis.addr", "must be host:port, got %q", c.RateLimit.Redis.Addr)
+			check(c.RateLimit.Redis.PoolSize > 0, "rate_limit.redis.pool_size", "must be at least 1, got %d", c.RateLimit.Redis.PoolSize)
+			positive(c.RateLimit.Redis.Timeout, "rate_limit.redis.timeout")
+		default:
+			check(false, "rate_limit.backend", "must be memory or redis, got %q", c.RateLimit.Backend)
+		}
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		u, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing.endpoint", "must look like http://otel-collector:4318, got %q", c.Tracing.Endpoint)
+	default:
+		check(false, "tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "must be json or text, got %q", c.Log.Format)
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	return configload.Write(w, c)
+}
@@ -0,0 +1,219 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spriigan/broker/config"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "broker.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+http:
+  port: 9000
+  write_timeout: 30s
+  route_timeouts:
+    GET /audit: 5s
+user_service:
+  addr: localhost:4000
+`), 0o600))
+
+	testTable := map[string]struct {
+		args   []string
+		env    map[string]string
+		assert func(t *testing.T, cfg config.Config)
+	}{
+		"defaults": {
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, config.Default(), cfg)
+			},
+		},
+		"file": {
+			args: []string{"--config", file},
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, 9000, cfg.HTTP.Port)
+				require.Equal(t, 30*time.Second, cfg.HTTP.WriteTimeout)
+				require.Equal(t, 5*time.Second, cfg.HTTP.ReadTimeout)
+				require.Equal(t, "localhost:4000", cfg.UserService.Addr)
+				require.Equal(t, 5*time.Second, cfg.HTTP.RouteTimeouts["GET /audit"])
+				require.Equal(t, 3*time.Second, cfg.HTTP.RouteTimeouts["POST /user"])
+			},
+		},
+		"env over file": {
+			env: map[string]string{"CONFIG_FILE": file, "REQUEST_TIMEOUT": "3s", "PORT": "9001"},
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, 3*time.Second, cfg.HTTP.RequestTimeout)
+				require.Equal(t, 9001, cfg.HTTP.Port)
+			},
+		},
+		"flags over env": {
+			args: []string{"--config", file, "--request-timeout", "4s", "--user-service-addr=user-service:8000"},
+			env:  map[string]string{"REQUEST_TIMEOUT": "3s", "ROUTE_TIMEOUTS": "GET /audit=2s"},
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, 4*time.Second, cfg.HTTP.RequestTimeout)
+				require.Equal(t, map[string]time.Duration{"GET /audit": 2 * time.Second}, cfg.HTTP.RouteTimeouts)
+				require.Equal(t, "user-service:8000", cfg.UserService.Addr)
+			},
+		},
+		"static replicas": {
+			env: map[string]string{"USER_SERVICE_RESOLVER": "static", "USER_SERVICE_ADDRS": "user-service-1:8000, user-service-2:8000", "USER_SERVICE_HEALTH_CHECK": "false"},
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, []string{"user-service-1:8000", "user-service-2:8000"}, cfg.UserService.Addrs)
+				require.False(t, cfg.UserService.HealthCheck)
+			},
+		},
+		"rate limits": {
+			env: map[string]string{"RATE_LIMIT_ROUTES": "POST /user=3/1m,GET /user=100/s", "RATE_LIMIT_KEY_BY": "api_key,ip", "TRUSTED_PROXIES": "10.0.0.0/8", "API_KEYS": "key-a, key-b"},
+			assert: func(t *testing.T, cfg config.Config) {
+				require.Equal(t, map[string]string{"POST /user": "3/1m", "GET /user": "100/s"}, cfg.RateLimit.Routes)
+				require.Equal(t, []string{"api_key", "ip"}, cfg.RateLimit.KeyBy)
+				require.Equal(t, []string{"10.0.0.0/8"}, cfg.HTTP.TrustedProxies)
+				require.Equal(t, []string{"key-a", "key-b"}, cfg.HTTP.APIKeys)
+			},
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			cfg, _, err := config.Load(tc.args, env(tc.env))
+			require.NoError(t, err)
+			tc.assert(t, cfg)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testTable := map[string]struct {
+		env    map[string]string
+		expect []string
+	}{
+		"bad address": {
+			env:    map[string]string{"USER_SERVICE_ADDR": "user-service"},
+			expect: []string{`user_service.addr must be host:port, got "user-service", set it with USER_SERVICE_ADDR, --user-service-addr`},
+		},
+		"static resolver without replicas": {
+			env:    map[string]string{"USER_SERVICE_RESOLVER": "static", "USER_SERVICE_ADDRS": "user-service-1:8000,user-service-2"},
+			expect: []string{`user_service.addrs must only hold host:port, got "user-service-2", set it with USER_SERVICE_ADDRS`},
+		},
+		"unknown balancer": {
+			env:    map[string]string{"USER_SERVICE_BALANCER": "random"},
+			expect: []string{`user_service.balancer must be round_robin or least_request, got "random"`},
+		},
+		"request timeout outlives the response": {
+			env:    map[string]string{"REQUEST_TIMEOUT": "10s"},
+			expect: []string{"http.request_timeout must be shorter than http.write_timeout (10s)"},
+		},
+		"bad route timeouts": {
+			env: map[string]string{"ROUTE_TIMEOUTS": "/user=2s,GET /audit=1m"},
+			expect: []string{
+				`http.route_timeouts key "/user" must be a method and a path`,
+				`http.route_timeouts "GET /audit" must be positive and shorter than http.write_timeout (10s), got 1m0s`,
+			},
+		},
+		"unparsable route timeouts": {
+			env:    map[string]string{"ROUTE_TIMEOUTS": "GET /audit"},
+			expect: []string{`"GET /audit" is not a key=value pair`},
+		},
+		"bad rate limits": {
+			env: map[string]string{"RATE_LIMIT_DEFAULT": "lots", "RATE_LIMIT_ROUTES": "POST /user=0/1m", "RATE_LIMIT_KEY_BY": "ip,session"},
+			expect: []string{
+				`rate_limit.key_by must only hold ip, user or api_key, got "session"`,
+				`rate_limit.default "lots" is not a limit, use requests/period like 10/1m`,
+				`rate_limit.routes "POST /user" "0/1m" does not allow a positive number of requests`,
+			},
+		},
+		"unverifiable rate limit keys": {
+			env: map[string]string{"RATE_LIMIT_KEY_BY": "api_key,user,ip"},
+			expect: []string{
+				"rate_limit.key_by must not hold api_key without http.api_keys to check it against",
+				"rate_limit.key_by must not hold user without http.trusted_proxies to vouch for it",
+			},
+		},
+		"redis backend": {
+			env:    map[string]string{"RATE_LIMIT_BACKEND": "redis", "RATE_LIMIT_REDIS_ADDR": "redis", "RATE_LIMIT_REDIS_TIMEOUT": "0s"},
+			expect: []string{`rate_limit.redis.addr must be host:port, got "redis"`, "rate_limit.redis.timeout must be a positive duration, got 0s"},
+		},
+		"untrusted proxies": {
+			env:    map[string]string{"TRUSTED_PROXIES": "10.0.0.0/33"},
+			expect: []string{`http.trusted_proxies must only hold addresses or CIDRs, got "10.0.0.0/33"`},
+		},
+		"dashboard origin with a path": {
+			env:    map[string]string{"CHANGES_ORIGINS": "https://admin.example.com,https://admin.example.com/changes"},
+			expect: []string{`changes.origins must only hold origins like https://admin.example.com, got "https://admin.example.com/changes"`},
+		},
+		"relative metrics path": {
+			env:    map[string]string{"METRICS_PATH": "metrics"},
+			expect: []string{`http.metrics_path must start with /, got "metrics"`},
+		},
+		"incomplete mtls": {
+			env: map[string]string{"TLS_MODE": "mtls", "TLS_CERT_FILE": "broker.pem"},
+			expect: []string{
+				"user_service.tls.key_file is required with user_service.tls.mode mtls, set it with TLS_KEY_FILE",
+			},
+		},
+		"unknown tls mode": {
+			env:    map[string]string{"TLS_MODE": "on"},
+			expect: []string{`user_service.tls.mode must be off, tls or mtls, got "on"`},
+		},
+		"invalid log": {
+			env: map[string]string{"LOG_LEVEL": "verbose", "LOG_FORMAT": "xml"},
+			expect: []string{
+				`log.level must be debug, info, warn or error, got "verbose"`,
+				`log.format must be json or text, got "xml"`,
+			},
+		},
+		"invalid tracing": {
+			env: map[string]string{"TRACING_EXPORTER": "jaeger", "TRACING_SAMPLE_RATIO": "-0.5"},
+			expect: []string{
+				`tracing.exporter must be none, stdout or otlp, got "jaeger"`,
+				"tracing.sample_ratio must be between 0 and 1, got -0.5",
+			},
+		},
+		"several problems": {
+			env: map[string]string{"PORT": "0", "HTTP_IDLE_TIMEOUT": "-1s"},
+			expect: []string{
+				"http.port must be between 1 and 65535, got 0",
+				"http.idle_timeout must be a positive duration, got -1s",
+			},
+		},
+		"unparsable": {
+			env:    map[string]string{"CHANGES_BUFFER": "lots"},
+			expect: []string{`CHANGES_BUFFER: changes.buffer: "lots" is not a whole number`},
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := config.Load(nil, env(tc.env))
+			require.Error(t, err)
+			for _, expect := range tc.expect {
+				require.Contains(t, err.Error(), expect)
+			}
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	cfg, opts, err := config.Load([]string{"--print-config"}, env(map[string]string{"ADMIN_TOKEN": "dev-admin-token"}))
+	require.NoError(t, err)
+	require.True(t, opts.Print)
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+	require.Contains(t, out.String(), "admin_token: REDACTED")
+	require.Contains(t, out.String(), "request_timeout: 1s")
+	require.NotContains(t, out.String(), "dev-admin-token")
+	require.Equal(t, "dev-admin-token", cfg.HTTP.AdminToken)
+}
@@ -1,34 +1,89 @@
 package infrastructure
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"time"
+
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/spriigan/RPApp/platform/tracing"
+	"github.com/spriigan/broker/config"
+	"github.com/spriigan/broker/logging"
+	"github.com/spriigan/broker/metrics"
+	"github.com/spriigan/broker/user/stream"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
 )
 
 type application struct {
-	Cfg config
+	Cfg     config.Config
+	Metrics *metrics.Metrics
 }
 
-func Application() application {
-	return application{
-		Cfg: config{
-			Port: os.Getenv("PORT"),
-		},
+func Application(cfg config.Config) application {
+	return application{Cfg: cfg, Metrics: metrics.New(metrics.Namespace)}
+}
+
+// SetupLogging makes the structured logger the default, what is written with
+// the log package included.
+func (app *application) SetupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(app.Cfg.Log.Level)); err == nil {
+		logs.Level.Set(level)
+	}
+	slog.SetDefault(logging.New(os.Stderr, app.Cfg.Log.Format))
+}
+
+// SetupTracing installs the tracer provider, shutdown flushes the spans still
+// buffered.
+func (app *application) SetupTracing() (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+	if app.Cfg.Tracing.Exporter != tracing.None {
+		exporter, err = tracing.NewExporter(app.Cfg.Tracing.Exporter, app.Cfg.Tracing.Endpoint, os.Stdout)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return tracing.Setup("broker", exporter, app.Cfg.Tracing.SampleRatio), nil
 }
 
-func (app *application) Serve(mux http.Handler) error {
+// Serve handles requests until ctx is done, then stops accepting connections
+// and waits up to the shutdown timeout for in-flight requests to complete.
+func (app *application) Serve(ctx context.Context, mux http.Handler) error {
 
 	srv := http.Server{
-		Addr:              fmt.Sprintf(":%s", app.Cfg.Port),
+		Addr:              fmt.Sprintf(":%d", app.Cfg.HTTP.Port),
 		Handler:           mux,
-		ReadTimeout:       5 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       20 * time.Second,
-		ReadHeaderTimeout: 3 * time.Second,
+		ReadTimeout:       app.Cfg.HTTP.ReadTimeout,
+		WriteTimeout:      app.Cfg.HTTP.WriteTimeout,
+		IdleTimeout:       app.Cfg.HTTP.IdleTimeout,
+		ReadHeaderTimeout: app.Cfg.HTTP.ReadHeaderTimeout,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return stream.WithConn(ctx, c)
+		},
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
 	}
 
-	return srv.ListenAndServe()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("shutdown timeout reached, closing remaining connections")
+		_ = srv.Close()
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
@@ -1,5 +0,0 @@
-package infrastructure
-
-type config struct {
-	Port string
-}
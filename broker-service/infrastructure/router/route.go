@@ -1,18 +1,65 @@
 package router
 
 import (
+	"expvar"
+	"log"
+
 	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/platform/logs"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
 	"github.com/spriigan/broker/adapters"
+	"github.com/spriigan/broker/config"
+	"github.com/spriigan/broker/metrics"
+	"github.com/spriigan/broker/middleware"
+	"golang.org/x/exp/slog"
 )
 
-func Route(cont *adapters.AppController) *gin.Engine {
-	mux := gin.Default()
+// Route mounts the controllers. Every request is counted in m and
+// authenticated, every route but the probes and the metrics is traced and goes
+// through rateLimit.
+func Route(cont *adapters.AppController, cfg config.HTTP, rateLimit gin.HandlerFunc, m *metrics.Metrics) *gin.Engine {
+	mux := gin.New()
+	if err := mux.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
+	mux.Use(middleware.RequestID(), middleware.Logger(slog.Default()), middleware.Metrics(m.HTTP), middleware.Recovery(slog.Default()), middleware.Authenticate(cfg.AdminToken, cfg.TrustedProxies, cfg.APIKeys))
+	if cfg.MetricsPath != "" {
+		mux.GET(cfg.MetricsPath, gin.WrapH(platformmetrics.Handler(m.Registry)))
+	}
+
+	deadline := middleware.Deadline(cfg.RequestTimeout, cfg.RouteTimeouts)
+	probes := mux.Group("/", deadline)
+	probes.GET("/healthz", cont.Health.Live)
+	probes.GET("/readyz", cont.Health.Ready)
+
+	limited := mux.Group("/", middleware.Trace("broker"), rateLimit)
+	requireAdmin := middleware.RequireAdmin(cfg.AdminToken)
+	// Change streams stay open for as long as the client listens, they carry
+	// the email of every user and are for the admin dashboard only.
+	streams := limited.Group("/", requireAdmin)
+	streams.GET("/user/changes", cont.Change.Events)
+	streams.GET("/user/changes/ws", cont.Change.Socket)
+
+	api := limited.Group("/", deadline)
+
+	api.POST("/user", cont.User.Create)
+	api.GET("/user", cont.User.FindUsers)
+	api.GET("/user/suggest", cont.User.Suggest)
+	api.GET("/user/:username", cont.User.FindByUsername)
+	api.DELETE("/user/:username", cont.User.DeleteByUsername)
+	api.PATCH("/user", cont.User.Update)
 
-	mux.POST("/user", cont.User.Create)
-	mux.GET("/user", cont.User.FindUsers)
-	mux.GET("/user/:username", cont.User.FindByUsername)
-	mux.DELETE("/user/:username", cont.User.DeleteByUsername)
-	mux.PATCH("/user", cont.User.Update)
+	admin := api.Group("/", requireAdmin)
+	admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	admin.GET("/debug/log-level", gin.WrapH(logs.LevelHandler()))
+	admin.PUT("/debug/log-level", gin.WrapH(logs.LevelHandler()))
+	admin.GET("/audit", cont.Audit.FindAuditEvents)
+	admin.POST("/webhooks", cont.Webhook.Create)
+	admin.GET("/webhooks", cont.Webhook.FindWebhooks)
+	admin.GET("/webhooks/:id", cont.Webhook.FindWebhook)
+	admin.PATCH("/webhooks/:id", cont.Webhook.Update)
+	admin.DELETE("/webhooks/:id", cont.Webhook.Delete)
+	admin.GET("/webhooks/:id/deliveries", cont.Webhook.FindDeliveries)
 
 	return mux
 }
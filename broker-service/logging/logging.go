@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"context"
+	"io"
+
+	"github.com/spriigan/RPApp/platform/logs"
+	"golang.org/x/exp/slog"
+)
+
+// New builds a logger writing format to w whose records carry the request id
+// of WithRequestID.
+func New(w io.Writer, format string) *slog.Logger {
+	return logs.New(w, format, RequestIDFrom)
+}
+
+type contextKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
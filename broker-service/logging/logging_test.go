@@ -0,0 +1,55 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/spriigan/broker/logging"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, logs.JSON)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = logging.WithRequestID(ctx, "req-1")
+
+	logger.InfoCtx(ctx, "request", "status", 200)
+	logger.Debug("hidden")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	require.Equal(t, "request", record["msg"])
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, float64(200), record["status"])
+	require.Equal(t, "req-1", record["request_id"])
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(logging.RequestIDKey)
+		return nil
+	}
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+
+	require.NoError(t, logging.UnaryClientInterceptor(ctx, "/user.UserService/FindUsers", nil, nil, nil, invoker))
+	require.Equal(t, []string{"req-1"}, sent)
+
+	forwarded := metadata.AppendToOutgoingContext(ctx, logging.RequestIDKey, "req-1")
+	require.NoError(t, logging.UnaryClientInterceptor(forwarded, "/user.UserService/Update", nil, nil, nil, invoker))
+	require.Equal(t, []string{"req-1"}, sent)
+
+	require.NoError(t, logging.UnaryClientInterceptor(context.Background(), "/user.UserService/FindUsers", nil, nil, nil, invoker))
+	require.Empty(t, sent)
+}
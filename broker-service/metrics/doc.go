@@ -0,0 +1,19 @@
+// Package metrics exposes the broker to Prometheus, under the broker namespace
+// and the naming of platform/metrics. Routes are labeled as registered, like
+// /user/:username, and "unmatched" for requests no route took.
+//
+// The metrics are
+//
+//	broker_http_requests_total{method,route,code}
+//	broker_http_request_duration_seconds{method,route}
+//	broker_http_requests_in_flight
+//	broker_grpc_client_handled_total{grpc_service,grpc_method,grpc_code}
+//	broker_grpc_client_handling_seconds{grpc_service,grpc_method}
+//	broker_grpc_client_breaker_rejected_total{grpc_service,grpc_method}
+//	broker_grpc_client_breaker_transitions_total{grpc_service,grpc_method,state}
+//	broker_grpc_client_breaker_state{grpc_service,grpc_method,state}
+//	broker_grpc_client_connection_state{target,state}
+//	broker_build_info{version,revision,go_version}
+//
+// gRPC calls are measured per attempt, the ones userclient retries included.
+package metrics
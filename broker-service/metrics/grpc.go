@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+	"google.golang.org/grpc"
+)
+
+// GRPCClient records the rate, errors and duration of calls to user-service
+// per method.
+type GRPCClient struct {
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+}
+
+func NewGRPCClient(reg prometheus.Registerer, namespace string) *GRPCClient {
+	m := &GRPCClient{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "handled_total",
+			Help:      "Calls completed, by method and status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "handling_seconds",
+			Help:      "Time taken by calls, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+	reg.MustRegister(m.handled, m.handling)
+	return m
+}
+
+func (m *GRPCClient) UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	service, name := platformmetrics.SplitMethod(method)
+	m.handled.WithLabelValues(service, name, platformmetrics.Code(err).String()).Inc()
+	m.handling.WithLabelValues(service, name).Observe(time.Since(start).Seconds())
+	return err
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+)
+
+const Namespace = "broker"
+
+// Metrics holds every collector of the service in its own registry.
+type Metrics struct {
+	Registry *prometheus.Registry
+	HTTP     *HTTP
+	GRPC     *GRPCClient
+}
+
+// New registers the runtime, process and build info collectors along with the
+// service metrics under namespace.
+func New(namespace string) *Metrics {
+	reg := platformmetrics.NewRegistry(namespace)
+	return &Metrics{
+		Registry: reg,
+		HTTP:     NewHTTP(reg, namespace),
+		GRPC:     NewGRPCClient(reg, namespace),
+	}
+}
@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spriigan/broker/metrics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCClient(t *testing.T) {
+	m := metrics.New("test")
+	call := func(err error) error {
+		return m.GRPC.UnaryClientInterceptor(context.Background(), "/user.UserService/FindByUsername", nil, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return err
+			})
+	}
+	require.NoError(t, call(nil))
+	require.Error(t, call(status.Error(codes.Unavailable, "connection refused")))
+	require.Error(t, call(context.DeadlineExceeded))
+
+	expected := `
+# HELP test_grpc_client_handled_total Calls completed, by method and status code.
+# TYPE test_grpc_client_handled_total counter
+test_grpc_client_handled_total{grpc_code="DeadlineExceeded",grpc_method="FindByUsername",grpc_service="user.UserService"} 1
+test_grpc_client_handled_total{grpc_code="OK",grpc_method="FindByUsername",grpc_service="user.UserService"} 1
+test_grpc_client_handled_total{grpc_code="Unavailable",grpc_method="FindByUsername",grpc_service="user.UserService"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(m.Registry, strings.NewReader(expected), "test_grpc_client_handled_total"))
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/response"
+)
+
+// RequireAdmin only lets through requests carrying "Authorization: Bearer
+// <token>". Routes behind it are disabled when no token is configured.
+func RequireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.JsonResponse{
+				Error:   true,
+				Message: "admin api is disabled",
+			})
+			return
+		}
+		if !adminToken(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, response.JsonResponse{
+				Error:   true,
+				Message: "admin token is missing or invalid",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anonymous is the actor of requests nobody the broker trusts vouched for.
+const Anonymous = "anonymous"
+
+const identityKey = "identity"
+
+var validActor = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// Identity is who a request was authenticated as.
+type Identity struct {
+	// Actor is what user-service records changes in the name of: "admin"
+	// with the admin token, the X-Actor header when a trusted proxy sent it
+	// and Anonymous otherwise.
+	Actor string
+	// Proxied tells whether Actor was vouched for by a trusted proxy.
+	Proxied bool
+	// Key names the api key the request carried once it was found among the
+	// configured ones, empty otherwise.
+	Key string
+}
+
+// adminToken tells whether the request carries "Authorization: Bearer token".
+func adminToken(c *gin.Context, token string) bool {
+	given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// trusted parses the addresses and CIDRs of the trusted proxies, config
+// validation rejected anything else.
+func trusted(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if _, n, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * len(ip)
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return nets
+}
+
+// apiKey names the key of the request if it is one of keys. Keys are secrets,
+// the name is a digest of the key that can be kept around.
+func apiKey(c *gin.Context, keys []string) string {
+	given := c.GetHeader("X-API-Key")
+	if given == "" {
+		return ""
+	}
+	found := false
+	for _, key := range keys {
+		// Every key is compared, how long it takes tells nothing.
+		found = subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 || found
+	}
+	if !found {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(given))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Authenticate works out who a request comes from for the handlers after it,
+// which read it with IdentityOf. X-Actor only counts when the peer sending it
+// is one of the trusted proxies and X-API-Key when it is one of apiKeys,
+// anyone could make them up.
+func Authenticate(token string, trustedProxies, apiKeys []string) gin.HandlerFunc {
+	proxies := trusted(trustedProxies)
+	return func(c *gin.Context) {
+		identity := Identity{Actor: Anonymous, Key: apiKey(c, apiKeys)}
+		switch actor := c.GetHeader("X-Actor"); {
+		case adminToken(c, token):
+			identity.Actor = "admin"
+		case actor != "" && validActor.MatchString(actor) && fromAny(c.RemoteIP(), proxies):
+			identity.Actor, identity.Proxied = actor, true
+		}
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+func fromAny(addr string, nets []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityOf is who Authenticate found the request to come from, Anonymous
+// when it did not run.
+func IdentityOf(c *gin.Context) Identity {
+	if identity, ok := c.Get(identityKey); ok {
+		return identity.(Identity)
+	}
+	return Identity{Actor: Anonymous}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/metrics"
+)
+
+// Metrics records every request, including the ones no route took, against
+// the route they matched.
+func Metrics(m *metrics.HTTP) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.InFlight.Inc()
+		defer m.InFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.Observe(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
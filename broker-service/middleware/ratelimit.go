@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/ratelimit"
+	"github.com/spriigan/broker/response"
+	"golang.org/x/exp/slog"
+)
+
+// Identify who a request is limited as by the first of keyBy it carries:
+// "api_key" a configured X-API-Key, "user" the X-Actor header set by a trusted
+// proxy and "ip" the client address. Requests carrying none fall back to their
+// address.
+func Identify(c *gin.Context, keyBy []string) string {
+	identity := IdentityOf(c)
+	for _, kind := range keyBy {
+		switch kind {
+		case "api_key":
+			if identity.Key != "" {
+				return "key:" + identity.Key
+			}
+		case "user":
+			if identity.Proxied {
+				return "user:" + identity.Actor
+			}
+		case "ip":
+			return "ip:" + c.ClientIP()
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit spends a token of the bucket the caller has for the matched route,
+// keyed like Deadline, and answers 429 once it is empty. Responses carry the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, rejections
+// Retry-After too. Requests go through when the store fails, an outage of it
+// should not take the api down with it.
+func RateLimit(limiter *ratelimit.Limiter, keyBy []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		res, ok, err := limiter.Take(c.Request.Context(), route, Identify(c, keyBy))
+		if err != nil {
+			slog.ErrorCtx(c.Request.Context(), "rate limit not enforced", "route", route, "error", err)
+		}
+		if !ok || err != nil {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", seconds(res.Reset))
+		header.Set("RateLimit-Policy", strconv.Itoa(res.Limit.Requests)+";w="+seconds(res.Limit.Per))
+		if !res.Allowed {
+			header.Set("Retry-After", seconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response.JsonResponse{
+				Error:   true,
+				Message: "too many requests, retry in " + seconds(res.RetryAfter) + "s",
+			})
+			return
+		}
+		c.Next()
+	}
+}
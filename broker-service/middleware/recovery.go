@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/logging"
+	"github.com/spriigan/broker/response"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc/codes"
+)
+
+// Recovery turns a panicking handler into a 500 carrying the request id, the
+// panic is logged with its stack. A response already on its way is cut short
+// instead.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			ctx := c.Request.Context()
+			logger.ErrorCtx(ctx, "panic handling request",
+				"route", c.FullPath(),
+				"panic", fmt.Sprint(p),
+				"stack", string(debug.Stack()),
+			)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, response.JsonResponse{
+				Error:   true,
+				Message: "internal error, request id " + logging.RequestIDFrom(ctx),
+				Code:    codes.Internal,
+			})
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/spriigan/broker/logging"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID keeps the X-Request-ID of the caller when it is well formed and
+// makes one up otherwise. The id is echoed in the response, put in the request
+// context for logs and calls to user-service, and in the request header for
+// handlers reading it from there.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !logs.ValidRequestID(id) {
+			id = logs.NewRequestID()
+			c.Request.Header.Set(RequestIDHeader, id)
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
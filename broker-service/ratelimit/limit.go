@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Per on average, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads limits written like "10/1m" or "5/s".
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%q is not a limit, use requests/period like 10/1m", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("%q does not allow a positive number of requests", s)
+	}
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%q does not have a positive period", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// rate is how many tokens come back every second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type Result struct {
+	Limit     Limit
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed, Reset
+	// until the bucket is full again.
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// result describes a bucket left with tokens after the take.
+func (l Limit) result(allowed bool, tokens float64) Result {
+	rate := l.rate()
+	res := Result{
+		Limit:     l,
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(l.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+// Store keeps token buckets. Take spends a token of the bucket at key, which
+// starts full.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter holds the limits of every route. Routes without a limit of their own
+// share a bucket limited by the fallback, none when it is zero.
+type Limiter struct {
+	store    Store
+	fallback Limit
+	routes   map[string]Limit
+}
+
+func NewLimiter(store Store, fallback Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{store: store, fallback: fallback, routes: routes}
+}
+
+// Take spends a token from the bucket who has for route, ok is false when the
+// route is not limited.
+func (l *Limiter) Take(ctx context.Context, route, who string) (res Result, ok bool, err error) {
+	limit, own := l.routes[route]
+	bucket := route
+	if !own {
+		if l.fallback.Requests == 0 {
+			return Result{}, false, nil
+		}
+		limit, bucket = l.fallback, "*"
+	}
+	res, err = l.store.Take(ctx, bucket+"|"+who, limit)
+	return res, true, err
+}
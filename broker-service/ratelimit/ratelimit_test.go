@@ -0,0 +1,278 @@
+package ratelimit_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/broker/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestParseLimit(t *testing.T) {
+	testTable := map[string]struct {
+		expect ratelimit.Limit
+		err    string
+	}{
+		"10/1m":  {expect: ratelimit.Limit{Requests: 10, Per: time.Minute}},
+		"5/s":    {expect: ratelimit.Limit{Requests: 5, Per: time.Second}},
+		" 2/h ":  {expect: ratelimit.Limit{Requests: 2, Per: time.Hour}},
+		"10":     {err: "is not a limit"},
+		"0/1m":   {err: "positive number of requests"},
+		"10/":    {err: "positive period"},
+		"10/-1m": {err: "positive period"},
+	}
+
+	for spec, tc := range testTable {
+		t.Run(spec, func(t *testing.T) {
+			limit, err := ratelimit.ParseLimit(spec)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, limit)
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	store := ratelimit.NewMemoryStore(c.Now)
+	limit := ratelimit.Limit{Requests: 2, Per: 10 * time.Second}
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 1, res.Remaining)
+	require.Equal(t, 5*time.Second, res.Reset)
+
+	res, _ = store.Take(ctx, "a", limit)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+
+	res, _ = store.Take(ctx, "a", limit)
+	require.False(t, res.Allowed)
+	require.Equal(t, 5*time.Second, res.RetryAfter)
+
+	res, _ = store.Take(ctx, "b", limit)
+	require.True(t, res.Allowed, "buckets are kept per key")
+
+	c.advance(5 * time.Second)
+	res, _ = store.Take(ctx, "a", limit)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+
+	c.advance(time.Hour)
+	res, _ = store.Take(ctx, "a", limit)
+	require.Equal(t, 1, res.Remaining, "a bucket never holds more than the limit")
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	store := ratelimit.NewMemoryStore(c.Now)
+	limit := ratelimit.Limit{Requests: 1, Per: time.Second}
+	for i := 0; i < 1000; i++ {
+		_, _ = store.Take(context.Background(), strconv.Itoa(i), limit)
+	}
+	require.Equal(t, 1000, store.Len())
+
+	c.advance(time.Second)
+	for i := 0; i < 24; i++ {
+		_, _ = store.Take(context.Background(), "busy", limit)
+	}
+	require.Equal(t, 1, store.Len())
+}
+
+func TestLimiter(t *testing.T) {
+	store := ratelimit.NewMemoryStore(time.Now)
+	limiter := ratelimit.NewLimiter(store, ratelimit.Limit{}, map[string]ratelimit.Limit{
+		"POST /user": {Requests: 1, Per: time.Minute},
+	})
+
+	_, ok, err := limiter.Take(context.Background(), "GET /user", "ip:127.0.0.1")
+	require.NoError(t, err)
+	require.False(t, ok, "routes are not limited without a fallback")
+
+	res, ok, err := limiter.Take(context.Background(), "POST /user", "ip:127.0.0.1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, res.Allowed)
+}
+
+// fakeRedis answers the commands the store sends with canned replies and
+// records them.
+type fakeRedis struct {
+	net.Listener
+	mu       sync.Mutex
+	commands [][]string
+	reply    func(args []string) string
+}
+
+func newFakeRedis(t *testing.T, reply func(args []string) string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{Listener: l, reply: reply}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, f.reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, len(f.commands))
+	for i, args := range f.commands {
+		names[i] = args[0]
+	}
+	return names
+}
+
+func TestRedisStore(t *testing.T) {
+	loaded := false
+	var mu sync.Mutex
+	server := newFakeRedis(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "AUTH":
+			if args[1] != "secret" {
+				return "-WRONGPASS invalid password\r\n"
+			}
+			return "+OK\r\n"
+		case "EVALSHA":
+			if !loaded {
+				return "-NOSCRIPT No matching script.\r\n"
+			}
+		case "EVAL":
+			loaded = true
+		}
+		if args[3] == "test:empty" {
+			return "*2\r\n:0\r\n$4\r\n0.25\r\n"
+		}
+		return "*2\r\n:1\r\n$3\r\n1.5\r\n"
+	})
+	now := func() time.Time { return time.UnixMilli(1000) }
+	store := ratelimit.NewRedisStore(ratelimit.RedisOptions{
+		Addr:     server.Addr().String(),
+		Password: "secret",
+		PoolSize: 1,
+		Timeout:  time.Second,
+		Prefix:   "test:",
+	}, now)
+	defer store.Close()
+	limit := ratelimit.Limit{Requests: 2, Per: time.Second}
+
+	res, err := store.Take(context.Background(), "full", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 1, res.Remaining)
+	require.Equal(t, 250*time.Millisecond, res.Reset)
+
+	res, err = store.Take(context.Background(), "empty", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 375*time.Millisecond, res.RetryAfter)
+
+	require.Equal(t, []string{"AUTH", "EVALSHA", "EVAL", "EVALSHA"}, server.names(), "the connection is reused and the script loaded once")
+	server.mu.Lock()
+	eval := server.commands[2]
+	server.mu.Unlock()
+	require.Equal(t, []string{"1", "test:full", "2", "0.002", "1000", "1001"}, eval[2:])
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	server := newFakeRedis(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	})
+	store := ratelimit.NewRedisStore(ratelimit.RedisOptions{Addr: server.Addr().String(), Password: "wrong", Timeout: time.Second}, time.Now)
+	_, err := store.Take(context.Background(), "a", ratelimit.Limit{Requests: 1, Per: time.Second})
+	require.ErrorContains(t, err, "WRONGPASS")
+
+	store = ratelimit.NewRedisStore(ratelimit.RedisOptions{Addr: server.Addr().String(), Timeout: time.Second}, time.Now)
+	_, err = store.Take(context.Background(), "a", ratelimit.Limit{Requests: 1, Per: time.Second})
+	require.ErrorContains(t, err, "unexpected reply")
+	require.NoError(t, store.Ping(context.Background()))
+
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer silent.Close()
+	go func() {
+		conn, err := silent.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = io.Copy(io.Discard, conn)
+		}
+	}()
+	store = ratelimit.NewRedisStore(ratelimit.RedisOptions{Addr: silent.Addr().String(), Timeout: 50 * time.Millisecond}, time.Now)
+	start := time.Now()
+	_, err = store.Take(context.Background(), "a", ratelimit.Limit{Requests: 1, Per: time.Second})
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "timeout"), err.Error())
+	require.Less(t, time.Since(start), time.Second)
+}
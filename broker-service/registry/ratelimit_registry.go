@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/middleware"
+	"github.com/spriigan/broker/ratelimit"
+)
+
+// NewRateLimit builds the rate limiting middleware, limits were checked when
+// the config was loaded.
+func (r registry) NewRateLimit() gin.HandlerFunc {
+	cfg := r.Config.RateLimit
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	var fallback ratelimit.Limit
+	if cfg.Default != "" {
+		fallback, _ = ratelimit.ParseLimit(cfg.Default)
+	}
+	routes := make(map[string]ratelimit.Limit, len(cfg.Routes))
+	for route, limit := range cfg.Routes {
+		routes[route], _ = ratelimit.ParseLimit(limit)
+	}
+	var store ratelimit.Store = ratelimit.NewMemoryStore(time.Now)
+	if cfg.Backend == "redis" {
+		store = ratelimit.NewRedisStore(ratelimit.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			PoolSize: cfg.Redis.PoolSize,
+			Timeout:  cfg.Redis.Timeout,
+			Prefix:   "broker:ratelimit:",
+		}, time.Now)
+	}
+	return middleware.RateLimit(ratelimit.NewLimiter(store, fallback, routes), cfg.KeyBy)
+}
@@ -1,24 +1,49 @@
 package registry
 
 import (
+	"context"
+
 	"github.com/spriigan/broker/adapters"
+	"github.com/spriigan/broker/config"
+	"github.com/spriigan/broker/metrics"
 	"github.com/spriigan/broker/user/grpc/client"
 )
 
 type Registry interface {
-	NewAppController() (*adapters.AppController, client.Close)
+	NewAppController(ctx context.Context) (*adapters.AppController, client.Close)
 }
 
 type registry struct {
+	Config  config.Config
+	Metrics *metrics.Metrics
 }
 
-func New() *registry {
-	return &registry{}
+func New(cfg config.Config, m *metrics.Metrics) *registry {
+	return &registry{Config: cfg, Metrics: m}
 }
 
-func (r registry) NewAppController() (*adapters.AppController, client.Close) {
-	user, close := r.NewUserController()
-	return &adapters.AppController{User: user}, func() {
-		close()
+// NewAppController wires the controllers on a shared connection to
+// user-service. The change streams end when ctx is done so they do not hold up
+// the server shutdown, close releases the connection once requests are drained.
+func (r registry) NewAppController(ctx context.Context) (*adapters.AppController, client.Close) {
+	stats := client.NewMetrics(r.Metrics.Registry, metrics.Namespace)
+	conn, closeConn := r.GrpcUserConn(ctx, stats)
+	go client.WatchState(ctx, conn, "user-service", stats)
+	hub := r.NewChangeHub(conn)
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+	app := &adapters.AppController{
+		User:    r.NewUserController(conn),
+		Audit:   r.NewAuditController(conn),
+		Webhook: r.NewWebhookController(conn),
+		Change:  r.NewChangeController(hub),
+		Health:  r.NewHealthController(conn),
+	}
+	return app, func() {
+		<-stopped
+		closeConn()
 	}
 }
@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"context"
+	"log"
+
+	"github.com/spriigan/RPApp/platform/certs"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TransportCredentials secures the connection to user-service as configured,
+// certificate changes are picked up until ctx is done.
+func (r registry) TransportCredentials(ctx context.Context) credentials.TransportCredentials {
+	cfg := r.Config.UserService.TLS
+	if cfg.Mode == "off" {
+		return insecure.NewCredentials()
+	}
+	files := certs.Files{CA: cfg.CAFile}
+	if cfg.Mode == "mtls" {
+		files.Cert, files.Key = cfg.CertFile, cfg.KeyFile
+	}
+	if cfg.DevDir != "" {
+		dev, err := certs.Dev(cfg.DevDir, "broker", nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files.CA = dev.CA
+		if cfg.Mode == "mtls" {
+			files.Cert, files.Key = dev.Cert, dev.Key
+		}
+		slog.Warn("calling user-service with certificates of a throwaway CA", "dir", cfg.DevDir)
+	}
+	reloader, err := certs.NewReloader(files.Cert, files.Key, files.CA)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go reloader.Run(ctx, cfg.ReloadInterval)
+	return credentials.NewTLS(reloader.ClientConfig(cfg.ServerName))
+}
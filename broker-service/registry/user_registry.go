@@ -1,24 +1,93 @@
 package registry
 
 import (
+	"context"
 	"log"
+	"time"
 
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/user-proto/userclient"
+	"github.com/spriigan/broker/logging"
 	"github.com/spriigan/broker/user/grpc/client"
 	"github.com/spriigan/broker/user/interface/controller"
-	"github.com/spriigan/broker/user/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/stream"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/backoff"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-func (r registry) NewUserController() (controller.UserController, client.Close) {
-	c, close := r.GrpcUserClient()
-	return controller.NewUserController(c), close
+// NewUserController calls user-service through userclient, which sends the
+// idempotent calls again while user-service is unavailable.
+func (r registry) NewUserController(conn grpc.ClientConnInterface) controller.UserController {
+	retry := r.Config.UserService.Retry
+	return controller.NewUserController(userclient.New(conn, userclient.Options{
+		Attempts:       retry.MaxAttempts,
+		InitialBackoff: retry.InitialBackoff,
+		MaxBackoff:     retry.MaxBackoff,
+	}))
 }
 
-func (r registry) GrpcUserClient() (models.UserServiceClient, client.Close) {
-	c, close, err := client.GrpcClient("user-service:8000", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+func (r registry) NewAuditController(conn grpc.ClientConnInterface) controller.AuditController {
+	return controller.NewAuditController(models.NewAuditServiceClient(conn))
+}
+
+func (r registry) NewWebhookController(conn grpc.ClientConnInterface) controller.WebhookController {
+	return controller.NewWebhookController(models.NewWebhookServiceClient(conn))
+}
+
+func (r registry) NewChangeHub(conn grpc.ClientConnInterface) *stream.Hub {
+	return stream.NewHub(models.NewUserWatchServiceClient(conn), r.Config.Changes.History, r.Config.Changes.Buffer)
+}
+
+func (r registry) NewChangeController(hub *stream.Hub) controller.ChangeController {
+	return controller.NewChangeController(hub, r.Config.Changes.Heartbeat, r.Config.Changes.Origins)
+}
+
+func (r registry) NewHealthController(conn *grpc.ClientConn) controller.HealthController {
+	return controller.NewHealthController(conn, healthpb.NewHealthClient(conn))
+}
+
+// GrpcUserConn does not wait for user-service, calls fail until it is up and
+// /readyz reports the broker as not ready meanwhile. grpc keeps reconnecting in
+// the background, calls are balanced over the replicas and every method has
+// its own circuit breaker. Every attempt is measured, the ones userclient
+// makes again included, and every one that reaches the wire gets its own span
+// carrying the trace context and the request id.
+func (r registry) GrpcUserConn(ctx context.Context, metrics *client.Metrics) (*grpc.ClientConn, client.Close) {
+	cfg := r.Config.UserService
+	connectParams := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 5 * time.Second}
+	connectParams.Backoff.MaxDelay = cfg.ReconnectMaxBackoff
+	breakers := client.NewBreakers(client.BreakerPolicy{
+		FailureThreshold: cfg.Breaker.FailureThreshold,
+		OpenTimeout:      cfg.Breaker.OpenTimeout,
+	}, metrics)
+
+	balancing := client.Balancing{
+		Resolver:    cfg.Resolver,
+		Addr:        cfg.Addr,
+		Addrs:       cfg.Addrs,
+		Policy:      cfg.Balancer,
+		HealthCheck: cfg.HealthCheck,
+	}
+
+	traced := otelgrpc.WithInterceptorFilter(filters.Not(filters.HealthCheck()))
+	opts := append(balancing.DialOptions(),
+		grpc.WithTransportCredentials(r.TransportCredentials(ctx)),
+		grpc.WithConnectParams(connectParams),
+		grpc.WithChainUnaryInterceptor(
+			r.Metrics.GRPC.UnaryClientInterceptor,
+			breakers.UnaryClientInterceptor,
+			otelgrpc.UnaryClientInterceptor(traced),
+			logging.UnaryClientInterceptor,
+		),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor(traced), logging.StreamClientInterceptor),
+	)
+	conn, close, err := client.GrpcConn(balancing.Target(), opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return c, close
+	conn.Connect()
+	return conn, close
 }
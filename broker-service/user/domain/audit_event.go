@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type FieldChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type AuditEvent struct {
+	Id             int64                  `json:"id"`
+	Actor          string                 `json:"actor"`
+	Action         string                 `json:"action"`
+	TargetId       int64                  `json:"target_id"`
+	TargetUsername string                 `json:"target_username"`
+	RequestId      string                 `json:"request_id"`
+	Diff           map[string]FieldChange `json:"diff"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
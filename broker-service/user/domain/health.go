@@ -0,0 +1,6 @@
+package domain
+
+type Readiness struct {
+	Connection  string `json:"connection"`
+	UserService string `json:"user_service"`
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+type UserChange struct {
+	Id         int64     `json:"id"`
+	Type       string    `json:"type"`
+	User       User      `json:"user"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/balancer/roundrobin"
+	_ "google.golang.org/grpc/health"
+)
+
+const (
+	RoundRobin   = roundrobin.Name
+	LeastRequest = "least_request"
+)
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(LeastRequest, leastRequestPickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+type leastRequestPickerBuilder struct{}
+
+func (leastRequestPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	picker := &leastRequestPicker{}
+	for sc := range info.ReadySCs {
+		picker.subConns = append(picker.subConns, &counted{SubConn: sc})
+	}
+	return picker
+}
+
+type counted struct {
+	balancer.SubConn
+	inflight int64
+}
+
+// leastRequestPicker compares two backends picked at random and sends the call
+// to the one with fewer calls in flight, which avoids herding onto a single
+// idle backend the way always picking the minimum would.
+type leastRequestPicker struct {
+	subConns []*counted
+}
+
+func (p *leastRequestPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	chosen := p.subConns[0]
+	if n := len(p.subConns); n > 1 {
+		i := rand.Intn(n)
+		j := rand.Intn(n - 1)
+		if j >= i {
+			j++
+		}
+		chosen = p.subConns[i]
+		if other := p.subConns[j]; atomic.LoadInt64(&other.inflight) < atomic.LoadInt64(&chosen.inflight) {
+			chosen = other
+		}
+	}
+	atomic.AddInt64(&chosen.inflight, 1)
+	return balancer.PickResult{
+		SubConn: chosen.SubConn,
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(&chosen.inflight, -1)
+		},
+	}, nil
+}
+
+// Balancing spreads calls over every user-service replica.
+type Balancing struct {
+	// Resolver is "dns", which resolves Addr to all its records, or
+	// StaticScheme, which uses Addrs as they are.
+	Resolver string
+	Addr     string
+	Addrs    []string
+	// Policy is RoundRobin or LeastRequest.
+	Policy string
+	// HealthCheck takes replicas that report NOT_SERVING through
+	// grpc.health.v1 out of rotation until they recover.
+	HealthCheck bool
+}
+
+func (b Balancing) Target() string {
+	if b.Resolver == StaticScheme {
+		return StaticScheme + ":///" + strings.Join(b.Addrs, ",")
+	}
+	return "dns:///" + b.Addr
+}
+
+func (b Balancing) DialOptions() []grpc.DialOption {
+	serviceConfig := fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]`, b.Policy)
+	if b.HealthCheck {
+		serviceConfig += `, "healthCheckConfig": {"serviceName": ""}`
+	}
+	return []grpc.DialOption{
+		grpc.WithResolvers(staticBuilder{}),
+		grpc.WithDefaultServiceConfig(serviceConfig + "}"),
+	}
+}
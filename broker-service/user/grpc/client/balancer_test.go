@@ -0,0 +1,136 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/grpc/client"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type replica struct {
+	models.UnimplementedUserServiceServer
+	addr   string
+	health *health.Server
+	block  chan struct{}
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *replica) FindUsers(ctx context.Context, in *emptypb.Empty) (*models.Users, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	if r.block != nil {
+		<-r.block
+	}
+	return &models.Users{}, nil
+}
+
+func (r *replica) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func startReplicas(t *testing.T, n int) []*replica {
+	replicas := make([]*replica, n)
+	for i := range replicas {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		r := &replica{addr: lis.Addr().String(), health: health.NewServer()}
+		s := grpc.NewServer()
+		models.RegisterUserServiceServer(s, r)
+		healthpb.RegisterHealthServer(s, r.health)
+		go s.Serve(lis)
+		t.Cleanup(s.Stop)
+		replicas[i] = r
+	}
+	return replicas
+}
+
+func dial(t *testing.T, policy string, replicas []*replica) models.UserServiceClient {
+	balancing := client.Balancing{Resolver: client.StaticScheme, Policy: policy, HealthCheck: true}
+	for _, r := range replicas {
+		balancing.Addrs = append(balancing.Addrs, r.addr)
+	}
+	opts := append(balancing.DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(balancing.Target(), opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return models.NewUserServiceClient(conn)
+}
+
+func TestRoundRobin(t *testing.T) {
+	replicas := startReplicas(t, 3)
+	users := dial(t, client.RoundRobin, replicas)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// wait until every replica is in rotation
+	require.Eventually(t, func() bool {
+		_, err := users.FindUsers(ctx, &emptypb.Empty{}, grpc.WaitForReady(true))
+		require.NoError(t, err)
+		return replicas[0].Calls() > 0 && replicas[1].Calls() > 0 && replicas[2].Calls() > 0
+	}, 3*time.Second, time.Millisecond)
+
+	replicas[1].health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	require.Eventually(t, func() bool {
+		before := replicas[1].Calls()
+		for i := 0; i < 6; i++ {
+			_, err := users.FindUsers(ctx, &emptypb.Empty{})
+			require.NoError(t, err)
+		}
+		return replicas[1].Calls() == before
+	}, 3*time.Second, 10*time.Millisecond)
+
+	before := replicas[0].Calls() + replicas[2].Calls()
+	for i := 0; i < 6; i++ {
+		_, err := users.FindUsers(ctx, &emptypb.Empty{})
+		require.NoError(t, err)
+	}
+	require.Equal(t, before+6, replicas[0].Calls()+replicas[2].Calls())
+}
+
+func TestLeastRequest(t *testing.T) {
+	replicas := startReplicas(t, 2)
+	users := dial(t, client.LeastRequest, replicas)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		_, err := users.FindUsers(ctx, &emptypb.Empty{}, grpc.WaitForReady(true))
+		require.NoError(t, err)
+		return replicas[0].Calls() > 0 && replicas[1].Calls() > 0
+	}, 3*time.Second, time.Millisecond)
+
+	block := make(chan struct{})
+	before := []int{replicas[0].Calls(), replicas[1].Calls()}
+	replicas[0].block, replicas[1].block = block, block
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := users.FindUsers(ctx, &emptypb.Empty{})
+			require.NoError(t, err)
+		}()
+		// the second call must see the first one in flight
+		require.Eventually(t, func() bool {
+			return replicas[0].Calls()+replicas[1].Calls() == before[0]+before[1]+i+1
+		}, time.Second, time.Millisecond)
+	}
+	close(block)
+	wg.Wait()
+	require.Equal(t, before[0]+1, replicas[0].Calls())
+	require.Equal(t, before[1]+1, replicas[1].Calls())
+}
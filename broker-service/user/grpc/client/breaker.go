@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerPolicy struct {
+	// FailureThreshold consecutive failures open the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long calls fail fast before one is let through to
+	// probe whether user-service recovered.
+	OpenTimeout time.Duration
+}
+
+type breaker struct {
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Breakers keeps a circuit breaker per method so one failing method does not
+// cut off the others.
+type Breakers struct {
+	policy  BreakerPolicy
+	metrics *Metrics
+	now     func() time.Time
+
+	mu       sync.Mutex
+	byMethod map[string]*breaker
+}
+
+func NewBreakers(policy BreakerPolicy, metrics *Metrics) *Breakers {
+	return &Breakers{
+		policy:   policy,
+		metrics:  metrics,
+		now:      time.Now,
+		byMethod: make(map[string]*breaker),
+	}
+}
+
+func (b *Breakers) State(method string) State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if br, ok := b.byMethod[method]; ok {
+		return br.state
+	}
+	return Closed
+}
+
+func (b *Breakers) transition(method string, br *breaker, to State) {
+	slog.Warn("circuit breaker changed state", "method", method, "state", to.String())
+	br.state = to
+	b.metrics.breakerChanged(method, to)
+}
+
+func (b *Breakers) allow(method string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br, ok := b.byMethod[method]
+	if !ok {
+		br = &breaker{}
+		b.byMethod[method] = br
+	}
+	switch br.state {
+	case Open:
+		if b.now().Sub(br.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.transition(method, br, HalfOpen)
+		br.probing = true
+		return true
+	case HalfOpen:
+		if br.probing {
+			return false
+		}
+		br.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breakers) record(method string, err error) {
+	failed := unreachable(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br := b.byMethod[method]
+	switch br.state {
+	case Closed:
+		if !failed {
+			br.failures = 0
+			return
+		}
+		if br.failures++; br.failures >= b.policy.FailureThreshold {
+			br.openedAt = b.now()
+			b.transition(method, br, Open)
+		}
+	case HalfOpen:
+		br.probing = false
+		if failed {
+			br.openedAt = b.now()
+			b.transition(method, br, Open)
+			return
+		}
+		br.failures = 0
+		b.transition(method, br, Closed)
+	}
+}
+
+// unreachable tells whether err says user-service could not be reached or did
+// not answer in time, the failures that open a circuit.
+func unreachable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// UnaryClientInterceptor fails calls fast with Unavailable while the circuit
+// of their method is open.
+func (b *Breakers) UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !b.allow(method) {
+		b.metrics.rejected(method)
+		return status.Errorf(codes.Unavailable, "%s: %s", method, ErrCircuitOpen)
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	b.record(method, err)
+	return err
+}
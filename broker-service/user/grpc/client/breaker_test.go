@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spriigan/broker/user/grpc/client"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const findUsers = "/user.UserService/FindUsers"
+
+func TestBreaker(t *testing.T) {
+	metrics := client.NewMetrics(prometheus.NewRegistry(), "test")
+	breakers := client.NewBreakers(client.BreakerPolicy{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}, metrics)
+	down := true
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		if down {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		return nil
+	}
+	call := func(method string) error {
+		return breakers.UnaryClientInterceptor(context.Background(), method, nil, nil, nil, invoker)
+	}
+
+	require.Error(t, call(findUsers))
+	require.Equal(t, client.Closed, breakers.State(findUsers))
+	require.Error(t, call(findUsers))
+	require.Equal(t, client.Open, breakers.State(findUsers))
+
+	err := call(findUsers)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Contains(t, err.Error(), client.ErrCircuitOpen.Error())
+	require.Equal(t, 2, calls)
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.Rejected.WithLabelValues("user.UserService", "FindUsers")))
+
+	// other methods keep their own circuit
+	require.Error(t, call("/user.UserService/FindByUsername"))
+	require.Equal(t, 3, calls)
+
+	time.Sleep(60 * time.Millisecond)
+	require.Error(t, call(findUsers))
+	require.Equal(t, 4, calls)
+	require.Equal(t, client.Open, breakers.State(findUsers))
+
+	time.Sleep(60 * time.Millisecond)
+	down = false
+	require.NoError(t, call(findUsers))
+	require.Equal(t, client.Closed, breakers.State(findUsers))
+	require.NoError(t, call(findUsers))
+
+	expected := map[string]float64{"open": 2, "half-open": 2, "closed": 1}
+	for state, transitions := range expected {
+		require.Equal(t, transitions, testutil.ToFloat64(metrics.Transitions.WithLabelValues("user.UserService", "FindUsers", state)), state)
+	}
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.States.WithLabelValues("user.UserService", "FindUsers", "closed")))
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.States.WithLabelValues("user.UserService", "FindUsers", "open")))
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	breakers := client.NewBreakers(client.BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}, client.NewMetrics(prometheus.NewRegistry(), "test"))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "user does not exist")
+	}
+	for i := 0; i < 3; i++ {
+		err := breakers.UnaryClientInterceptor(context.Background(), findUsers, nil, nil, nil, invoker)
+		require.Equal(t, codes.NotFound, status.Code(err))
+	}
+	require.Equal(t, client.Closed, breakers.State(findUsers))
+}
@@ -1,20 +1,17 @@
 package client
 
 import (
-	"github.com/spriigan/broker/user/user-proto/grpc/models"
 	"google.golang.org/grpc"
 )
 
 type Close func()
 
-func GrpcClient(addr string, opts ...grpc.DialOption) (models.UserServiceClient, Close, error) {
+func GrpcConn(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, Close, error) {
 	conn, err := grpc.Dial(addr, opts...)
 	if err != nil {
-		return nil, func() {
-			conn.Close()
-		}, err
+		return nil, func() {}, err
 	}
-	return models.NewUserServiceClient(conn), func() {
+	return conn, func() {
 		conn.Close()
 	}, nil
 
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ActorKey     = "x-actor"
+	RequestIDKey = "x-request-id"
+	// ReadPrimaryUntilKey is the header user-service answers writes with, the
+	// unix milliseconds until which its replicas may lag behind. Sent back, it
+	// has the reads go to the primary.
+	ReadPrimaryUntilKey = "x-read-primary-until"
+)
+
+// OutgoingContext forwards who is acting and the request id to user-service,
+// which records them in its audit log.
+func OutgoingContext(ctx context.Context, actor, requestID string) context.Context {
+	if actor != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, ActorKey, actor)
+	}
+	if requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, requestID)
+	}
+	return ctx
+}
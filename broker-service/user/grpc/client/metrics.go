@@ -0,0 +1,72 @@
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spriigan/RPApp/platform/metrics"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Metrics counts what the resilience layer does, per method.
+type Metrics struct {
+	Rejected    *prometheus.CounterVec
+	Transitions *prometheus.CounterVec
+	States      *prometheus.GaugeVec
+	Connection  *prometheus.GaugeVec
+}
+
+func NewMetrics(reg prometheus.Registerer, namespace string) *Metrics {
+	m := &Metrics{
+		Rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "breaker_rejected_total",
+			Help:      "Calls failed fast by an open circuit breaker, by method.",
+		}, []string{"grpc_service", "grpc_method"}),
+		Transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "breaker_transitions_total",
+			Help:      "Circuit breaker state changes, by method and the state entered.",
+		}, []string{"grpc_service", "grpc_method", "state"}),
+		States: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "breaker_state",
+			Help:      "1 for the state the circuit breaker of a method is in, 0 for the others.",
+		}, []string{"grpc_service", "grpc_method", "state"}),
+		Connection: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "connection_state",
+			Help:      "1 for the state the connection to a target is in, 0 for the others.",
+		}, []string{"target", "state"}),
+	}
+	reg.MustRegister(m.Rejected, m.Transitions, m.States, m.Connection)
+	return m
+}
+
+func (m *Metrics) rejected(method string) {
+	service, name := metrics.SplitMethod(method)
+	m.Rejected.WithLabelValues(service, name).Inc()
+}
+
+func (m *Metrics) breakerChanged(method string, to State) {
+	service, name := metrics.SplitMethod(method)
+	m.Transitions.WithLabelValues(service, name, to.String()).Inc()
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		m.States.WithLabelValues(service, name, state.String()).Set(is(state == to))
+	}
+}
+
+func (m *Metrics) connectionChanged(target string, to connectivity.State) {
+	for state := connectivity.Idle; state <= connectivity.Shutdown; state++ {
+		m.Connection.WithLabelValues(target, state.String()).Set(is(state == to))
+	}
+}
+
+func is(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// WatchState logs and counts the state changes of conn until ctx is done. grpc
+// reconnects on its own, this only makes it visible.
+func WatchState(ctx context.Context, conn *grpc.ClientConn, name string, metrics *Metrics) {
+	state := conn.GetState()
+	metrics.connectionChanged(name, state)
+	for conn.WaitForStateChange(ctx, state) {
+		next := conn.GetState()
+		slog.Info("connection changed state", "target", name, "from", state.String(), "to", next.String())
+		state = next
+		metrics.connectionChanged(name, state)
+		if state == connectivity.Shutdown {
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/response"
+	"github.com/spriigan/broker/user/domain"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type AuditController interface {
+	FindAuditEvents(ctx *gin.Context)
+}
+
+type auditController struct {
+	client models.AuditServiceClient
+}
+
+type AuditQuery struct {
+	Actor    string    `form:"actor"`
+	Action   string    `form:"action"`
+	Target   string    `form:"target"`
+	Since    time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until    time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit    int32     `form:"limit" binding:"omitempty,min=1,max=500"`
+	BeforeId int64     `form:"before_id" binding:"omitempty,min=1"`
+}
+
+func NewAuditController(client models.AuditServiceClient) *auditController {
+	return &auditController{client: client}
+}
+
+func (ac *auditController) FindAuditEvents(c *gin.Context) {
+	var res response.JsonResponse
+	var query AuditQuery
+	err := c.ShouldBindQuery(&query)
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+		c.JSON(http.StatusBadRequest, res)
+		return
+	}
+
+	filter := models.AuditFilter{
+		Actor:          query.Actor,
+		Action:         query.Action,
+		TargetUsername: query.Target,
+		Limit:          query.Limit,
+		BeforeId:       query.BeforeId,
+	}
+	if !query.Since.IsZero() {
+		filter.Since = timestamppb.New(query.Since)
+	}
+	if !query.Until.IsZero() {
+		filter.Until = timestamppb.New(query.Until)
+	}
+
+	ctx := c.Request.Context()
+	result, err := ac.client.ListAuditEvents(ctx, &filter)
+	if err != nil {
+		st, _ := status.FromError(err)
+		res.Error = true
+		res.Message = st.Message()
+		res.Code = st.Code()
+		c.JSON(rpcStatus(err, http.StatusBadRequest), res)
+		return
+	}
+
+	events := make([]domain.AuditEvent, 0, len(result.GetEvents()))
+	for _, e := range result.GetEvents() {
+		diff := make(map[string]domain.FieldChange, len(e.GetDiff()))
+		for field, change := range e.GetDiff() {
+			diff[field] = domain.FieldChange{Before: change.GetBefore(), After: change.GetAfter()}
+		}
+		events = append(events, domain.AuditEvent{
+			Id:             e.GetId(),
+			Actor:          e.GetActor(),
+			Action:         e.GetAction(),
+			TargetId:       e.GetTargetId(),
+			TargetUsername: e.GetTargetUsername(),
+			RequestId:      e.GetRequestId(),
+			Diff:           diff,
+			CreatedAt:      e.GetCreatedAt().AsTime(),
+		})
+	}
+	res.Error = false
+	res.Data = events
+	c.JSON(http.StatusOK, res)
+}
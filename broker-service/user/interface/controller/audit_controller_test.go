@@ -0,0 +1,129 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/response"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type mockAuditClient struct {
+	mock.Mock
+}
+
+func (mc *mockAuditClient) ListAuditEvents(ctx context.Context, in *models.AuditFilter, opts ...grpc.CallOption) (*models.AuditEvents, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditEvents), args.Error(1)
+}
+
+var auditClient *mockAuditClient
+
+func TestFindAuditEvents(t *testing.T) {
+	events := &models.AuditEvents{
+		Events: []*models.AuditEvent{
+			{
+				Id:             2,
+				Actor:          "admin",
+				Action:         "user.update",
+				TargetUsername: "ryanpujo",
+				Diff: map[string]*models.FieldChange{
+					"email": {Before: "a@gmail.com", After: "b@gmail.com"},
+				},
+				CreatedAt: timestamppb.Now(),
+			},
+		},
+	}
+	testTable := map[string]struct {
+		uri     string
+		token   string
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"success api call": {
+			uri:   "/audit?actor=admin&since=2023-01-02T15:04:05Z&limit=10",
+			token: adminToken,
+			arrange: func(t *testing.T) {
+				auditClient.On("ListAuditEvents", mock.Anything, mock.MatchedBy(func(in *models.AuditFilter) bool {
+					return in.Actor == "admin" && in.Limit == 10 && in.Since.AsTime().Year() == 2023 && in.Until == nil
+				})).Return(events, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, res.Error)
+				data := res.Data.([]interface{})
+				require.Len(t, data, 1)
+				event := data[0].(map[string]interface{})
+				require.Equal(t, "ryanpujo", event["target_username"])
+				require.NotNil(t, event["diff"].(map[string]interface{})["email"])
+			},
+		},
+		"failed call": {
+			uri:   "/audit",
+			token: adminToken,
+			arrange: func(t *testing.T) {
+				auditClient.On("ListAuditEvents", mock.Anything, mock.Anything).Return(nil, status.Error(codes.InvalidArgument, "since must be before until")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+				require.Equal(t, codes.InvalidArgument, res.Code)
+			},
+		},
+		"bad query": {
+			uri:     "/audit?since=yesterday",
+			token:   adminToken,
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+		"missing token": {
+			uri:     "/audit",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusUnauthorized, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+		"wrong token": {
+			uri:     "/audit",
+			token:   "guess",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusUnauthorized, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			req, _ := http.NewRequest(http.MethodGet, v.uri, nil)
+			if v.token != "" {
+				req.Header.Set("Authorization", "Bearer "+v.token)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			var res response.JsonResponse
+			_ = json.NewDecoder(rr.Body).Decode(&res)
+
+			v.assert(t, rr.Code, res)
+		})
+	}
+}
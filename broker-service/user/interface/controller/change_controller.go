@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/spriigan/broker/response"
+	"github.com/spriigan/broker/user/stream"
+)
+
+type ChangeController interface {
+	Events(ctx *gin.Context)
+	Socket(ctx *gin.Context)
+}
+
+type ChangeFeed interface {
+	Subscribe(ctx context.Context, lastID int64) (*stream.Subscription, error)
+}
+
+type changeController struct {
+	feed      ChangeFeed
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+}
+
+const writeWait = 10 * time.Second
+
+// NewChangeController lets browsers open WebSockets from origins and from
+// the broker's own origin only.
+func NewChangeController(feed ChangeFeed, heartbeat time.Duration, origins []string) *changeController {
+	return &changeController{
+		feed:      feed,
+		heartbeat: heartbeat,
+		upgrader:  websocket.Upgrader{CheckOrigin: allowOrigin(origins)},
+	}
+}
+
+// allowOrigin checks the Origin browsers send with a WebSocket handshake,
+// clients sending none are not browsers and are let through.
+func allowOrigin(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range origins {
+			if strings.EqualFold(origin, allowed) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// lastEventID reads the resume cursor from the Last-Event-ID header that
+// EventSource sends when it reconnects, or from the last_event_id query
+// parameter for clients that cannot set headers.
+func lastEventID(c *gin.Context) (int64, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid last event id %q", value)
+	}
+	return id, nil
+}
+
+func (cc *changeController) subscribe(c *gin.Context) (*stream.Subscription, bool) {
+	var res response.JsonResponse
+	lastID, err := lastEventID(c)
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+		c.JSON(http.StatusBadRequest, res)
+		return nil, false
+	}
+	sub, err := cc.feed.Subscribe(c.Request.Context(), lastID)
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+		c.JSON(http.StatusServiceUnavailable, res)
+		return nil, false
+	}
+	return sub, true
+}
+
+// Events streams user changes as Server-Sent Events. The stream ends when the
+// client falls behind, EventSource then reconnects with the last id it saw.
+func (cc *changeController) Events(c *gin.Context) {
+	sub, ok := cc.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	ctx := c.Request.Context()
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	stream.ExtendWriteDeadline(ctx, cc.heartbeat+writeWait)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(cc.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			stream.ExtendWriteDeadline(ctx, cc.heartbeat+writeWait)
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case change, open := <-sub.C:
+			if !open {
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				return
+			}
+			stream.ExtendWriteDeadline(ctx, cc.heartbeat+writeWait)
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", change.Id, change.Type, data)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Socket streams user changes as JSON text messages over a WebSocket. When the
+// client falls behind the socket is closed with 1013 (try again later) and it
+// should reconnect with ?last_event_id= set to the last id it received.
+func (cc *changeController) Socket(c *gin.Context) {
+	sub, ok := cc.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := cc.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(cc.heartbeat + writeWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cc.heartbeat + writeWait))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(cc.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case change, open := <-sub.C:
+			if !open {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "resume with last_event_id")
+				_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err = conn.WriteJSON(change); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package controller_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/domain"
+	"github.com/spriigan/broker/user/stream"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fakeWatchStream struct {
+	grpc.ClientStream
+	ctx     context.Context
+	changes chan *models.UserChange
+}
+
+func (s *fakeWatchStream) Recv() (*models.UserChange, error) {
+	select {
+	case change := <-s.changes:
+		return change, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+type mockWatchClient struct {
+	mock.Mock
+}
+
+func (mc *mockWatchClient) WatchUsers(ctx context.Context, in *models.WatchRequest, opts ...grpc.CallOption) (models.UserWatchService_WatchUsersClient, error) {
+	args := mc.Called(in.AfterId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return &fakeWatchStream{ctx: ctx, changes: args.Get(0).(chan *models.UserChange)}, args.Error(1)
+}
+
+var watchClient *mockWatchClient
+var upstream chan *models.UserChange
+var changeHub *stream.Hub
+var changeSeq int64
+
+// userChange numbers changes in sequence since the hub is shared by every
+// test and ignores ids it has already seen.
+func userChange(changeType, username string) *models.UserChange {
+	changeSeq++
+	return &models.UserChange{
+		Id:         changeSeq,
+		Type:       changeType,
+		User:       &models.UserBio{Id: 1, Username: username},
+		OccurredAt: timestamppb.Now(),
+	}
+}
+
+// readEvent returns the id, event and data fields of the next SSE event,
+// skipping comments.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string, string) {
+	fields := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if _, ok := fields["data"]; ok {
+				return fields["id"], fields["event"], fields["data"]
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		fields[parts[0]] = parts[1]
+	}
+}
+
+// asAdmin carries the admin token the change streams require.
+func asAdmin() http.Header {
+	return http.Header{"Authorization": {"Bearer " + adminToken}}
+}
+
+// getChanges opens the SSE stream at url as the admin.
+func getChanges(t *testing.T, url string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header = asAdmin()
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return res
+}
+
+// waitIdle waits for the subscribers left by previous tests to be gone.
+func waitIdle(t *testing.T) {
+	require.Eventually(t, func() bool { return changeHub.Len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestChangeEvents(t *testing.T) {
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	waitIdle(t)
+
+	res := getChanges(t, server.URL+"/user/changes")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return changeHub.Len() == 1 }, time.Second, time.Millisecond)
+
+	created, updated := userChange("user.created", "ryanpujo"), userChange("user.updated", "ryanpujo")
+	upstream <- created
+	upstream <- updated
+
+	reader := bufio.NewReader(res.Body)
+	id, event, data := readEvent(t, reader)
+	require.Equal(t, fmt.Sprint(created.Id), id)
+	require.Equal(t, "user.created", event)
+	var change domain.UserChange
+	require.NoError(t, json.Unmarshal([]byte(data), &change))
+	require.Equal(t, "ryanpujo", change.User.Username)
+	id, _, _ = readEvent(t, reader)
+	require.Equal(t, fmt.Sprint(updated.Id), id)
+
+	t.Run("resume from memory", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/user/changes", nil)
+		req.Header = asAdmin()
+		req.Header.Set("Last-Event-ID", fmt.Sprint(created.Id))
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		id, event, _ := readEvent(t, bufio.NewReader(res.Body))
+		require.Equal(t, fmt.Sprint(updated.Id), id)
+		require.Equal(t, "user.updated", event)
+	})
+
+	t.Run("resume from user-service", func(t *testing.T) {
+		ahead := updated.Id + 100
+		older := make(chan *models.UserChange, 1)
+		older <- &models.UserChange{Id: ahead + 1, Type: "user.deleted", User: &models.UserBio{Username: "dabi"}}
+		watchClient.On("WatchUsers", ahead).Return(older, nil).Once()
+
+		res := getChanges(t, server.URL+fmt.Sprintf("/user/changes?last_event_id=%d", ahead))
+		defer res.Body.Close()
+
+		id, event, _ := readEvent(t, bufio.NewReader(res.Body))
+		require.Equal(t, fmt.Sprint(ahead+1), id)
+		require.Equal(t, "user.deleted", event)
+	})
+
+	t.Run("bad last event id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user/changes", nil)
+		req.Header = asAdmin()
+		req.Header.Set("Last-Event-ID", "latest")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("without the admin token", func(t *testing.T) {
+		for _, path := range []string{"/user/changes", "/user/changes/ws"} {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			require.Equal(t, http.StatusUnauthorized, rr.Code, path)
+		}
+	})
+}
+
+func TestChangeSocket(t *testing.T) {
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/user/changes/ws"
+	waitIdle(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, asAdmin())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return changeHub.Len() == 1 }, time.Second, time.Millisecond)
+
+	created := userChange("user.created", "shoto")
+	upstream <- created
+
+	var change domain.UserChange
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&change))
+	require.Equal(t, created.Id, change.Id)
+	require.Equal(t, "shoto", change.User.Username)
+
+	resumed, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?last_event_id=%d", url, created.Id-1), asAdmin())
+	require.NoError(t, err)
+	defer resumed.Close()
+	require.NoError(t, resumed.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, resumed.ReadJSON(&change))
+	require.Equal(t, created.Id, change.Id)
+}
+
+func TestChangeSocketOrigin(t *testing.T) {
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/user/changes/ws"
+	testTable := map[string]struct {
+		origin string
+		expect int
+	}{
+		"dashboard":     {origin: "https://admin.example.com", expect: http.StatusSwitchingProtocols},
+		"same origin":   {origin: server.URL, expect: http.StatusSwitchingProtocols},
+		"not a browser": {expect: http.StatusSwitchingProtocols},
+		"other site":    {origin: "https://evil.example.com", expect: http.StatusForbidden},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			header := asAdmin()
+			if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+			conn, res, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+			require.Equal(t, tc.expect == http.StatusSwitchingProtocols, err == nil)
+			require.Equal(t, tc.expect, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/broker/response"
+	"github.com/spriigan/broker/user/domain"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type HealthController interface {
+	Live(ctx *gin.Context)
+	Ready(ctx *gin.Context)
+}
+
+// Conn is the part of a grpc.ClientConn readiness looks at.
+type Conn interface {
+	GetState() connectivity.State
+	Connect()
+}
+
+type healthController struct {
+	conn   Conn
+	client healthpb.HealthClient
+}
+
+func NewHealthController(conn Conn, client healthpb.HealthClient) *healthController {
+	return &healthController{conn: conn, client: client}
+}
+
+// Live only tells the process is able to serve requests.
+func (hc *healthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, response.JsonResponse{Message: "ok"})
+}
+
+// Ready checks the connection to user-service and asks it for its own health,
+// which covers postgres.
+func (hc *healthController) Ready(c *gin.Context) {
+	var res response.JsonResponse
+	state := hc.conn.GetState()
+	if state == connectivity.Idle {
+		hc.conn.Connect()
+	}
+	readiness := domain.Readiness{
+		Connection:  state.String(),
+		UserService: healthpb.HealthCheckResponse_UNKNOWN.String(),
+	}
+	res.Data = readiness
+
+	if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+		res.Error = true
+		res.Message = "user-service is unreachable"
+		c.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+
+	ctx := c.Request.Context()
+	check, err := hc.client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+		res.Code = status.Code(err)
+		c.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+	readiness.UserService = check.GetStatus().String()
+	res.Data = readiness
+	if check.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		res.Error = true
+		res.Message = "user-service is not serving"
+		c.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+	res.Message = "ready"
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,138 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/broker/response"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type mockConn struct {
+	mock.Mock
+}
+
+func (mc *mockConn) GetState() connectivity.State {
+	return mc.Called().Get(0).(connectivity.State)
+}
+
+func (mc *mockConn) Connect() {
+	mc.Called()
+}
+
+type mockHealthClient struct {
+	mock.Mock
+}
+
+func (mc *mockHealthClient) Check(ctx context.Context, in *healthpb.HealthCheckRequest, opts ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*healthpb.HealthCheckResponse), args.Error(1)
+}
+
+func (mc *mockHealthClient) Watch(ctx context.Context, in *healthpb.HealthCheckRequest, opts ...grpc.CallOption) (healthpb.Health_WatchClient, error) {
+	args := mc.Called(ctx, in)
+	return nil, args.Error(1)
+}
+
+var conn *mockConn
+var healthClient *mockHealthClient
+
+func serving(status healthpb.HealthCheckResponse_ServingStatus) *healthpb.HealthCheckResponse {
+	return &healthpb.HealthCheckResponse{Status: status}
+}
+
+func TestLive(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReady(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"ready": {
+			arrange: func(t *testing.T) {
+				conn.On("GetState").Return(connectivity.Ready).Once()
+				healthClient.On("Check", mock.Anything, mock.Anything).Return(serving(healthpb.HealthCheckResponse_SERVING), nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, res.Error)
+				data := res.Data.(map[string]interface{})
+				require.Equal(t, "READY", data["connection"])
+				require.Equal(t, "SERVING", data["user_service"])
+			},
+		},
+		"idle connection is woken up": {
+			arrange: func(t *testing.T) {
+				conn.On("GetState").Return(connectivity.Idle).Once()
+				conn.On("Connect").Once()
+				healthClient.On("Check", mock.Anything, mock.Anything).Return(serving(healthpb.HealthCheckResponse_SERVING), nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				conn.AssertCalled(t, "Connect")
+			},
+		},
+		"unreachable": {
+			arrange: func(t *testing.T) {
+				conn.On("GetState").Return(connectivity.TransientFailure).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusServiceUnavailable, statusCode)
+				require.True(t, res.Error)
+				require.Equal(t, "TRANSIENT_FAILURE", res.Data.(map[string]interface{})["connection"])
+			},
+		},
+		"postgres is down": {
+			arrange: func(t *testing.T) {
+				conn.On("GetState").Return(connectivity.Ready).Once()
+				healthClient.On("Check", mock.Anything, mock.Anything).Return(serving(healthpb.HealthCheckResponse_NOT_SERVING), nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusServiceUnavailable, statusCode)
+				require.True(t, res.Error)
+				require.Equal(t, "NOT_SERVING", res.Data.(map[string]interface{})["user_service"])
+			},
+		},
+		"check failed": {
+			arrange: func(t *testing.T) {
+				conn.On("GetState").Return(connectivity.Connecting).Once()
+				healthClient.On("Check", mock.Anything, mock.Anything).Return(nil, status.Error(codes.DeadlineExceeded, "context deadline exceeded")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusServiceUnavailable, statusCode)
+				require.Equal(t, codes.DeadlineExceeded, res.Code)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			var res response.JsonResponse
+			_ = json.NewDecoder(rr.Body).Decode(&res)
+
+			v.assert(t, rr.Code, res)
+		})
+	}
+}
@@ -2,15 +2,21 @@ package controller
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/middleware"
 	"github.com/spriigan/broker/response"
 	"github.com/spriigan/broker/user/domain"
-	"github.com/spriigan/broker/user/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/grpc/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type UserController interface {
@@ -19,17 +25,86 @@ type UserController interface {
 	FindByUsername(ctx *gin.Context)
 	DeleteByUsername(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	Suggest(ctx *gin.Context)
+}
+
+// Users is what the controller needs of user-service, *userclient.Client
+// being the implementation. Call options apply to every attempt.
+type Users interface {
+	Register(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) (*models.UserBio, error)
+	FindUsers(ctx context.Context, opts ...grpc.CallOption) ([]*models.UserBio, error)
+	FindByUsername(ctx context.Context, username string, opts ...grpc.CallOption) (*models.UserBio, error)
+	DeleteByUsername(ctx context.Context, username string, opts ...grpc.CallOption) error
+	Update(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) error
+	SuggestUsernames(ctx context.Context, prefix string, limit int32, opts ...grpc.CallOption) ([]*models.UserBio, error)
 }
 
 type userController struct {
-	client models.UserServiceClient
+	users Users
 }
 type Uri struct {
 	Username string `uri:"username" binding:"required"`
 }
 
-func NewUserController(client models.UserServiceClient) *userController {
-	return &userController{client: client}
+type SuggestQuery struct {
+	Prefix string `form:"prefix" binding:"required"`
+	Limit  int32  `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+func NewUserController(users Users) *userController {
+	return &userController{users: users}
+}
+
+// outgoing attaches who the request was authenticated as and the request id
+// so user-service can attribute the change in its audit log.
+func outgoing(ctx context.Context, c *gin.Context) context.Context {
+	return client.OutgoingContext(ctx, middleware.IdentityOf(c).Actor, c.GetHeader("X-Request-ID"))
+}
+
+// readPrimaryCookie keeps the x-read-primary-until header of a write so the
+// reads of the caller after it see the write, whichever process of
+// user-service they land on.
+const readPrimaryCookie = "read_primary_until"
+
+// reading sends the reads of a caller that just wrote to the primary.
+func reading(ctx context.Context, c *gin.Context) context.Context {
+	until, err := c.Cookie(readPrimaryCookie)
+	if err != nil {
+		return ctx
+	}
+	if _, err := strconv.ParseInt(until, 10, 64); err != nil {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, client.ReadPrimaryUntilKey, until)
+}
+
+// wrote keeps the x-read-primary-until header of a write in a cookie expiring
+// along with it.
+func wrote(c *gin.Context, header metadata.MD) {
+	values := header.Get(client.ReadPrimaryUntilKey)
+	if len(values) == 0 {
+		return
+	}
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return
+	}
+	if maxAge := int(math.Ceil(time.Until(time.UnixMilli(ms)).Seconds())); maxAge > 0 {
+		c.SetCookie(readPrimaryCookie, values[0], maxAge, "/", "", false, true)
+	}
+}
+
+// rpcStatus answers 504 when the request ran out of its budget on the way to
+// user-service and 503 when user-service is unreachable or its circuit is
+// open, fallback otherwise.
+func rpcStatus(err error, fallback int) int {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return fallback
 }
 
 func (uc *userController) Create(c *gin.Context) {
@@ -42,8 +117,7 @@ func (uc *userController) Create(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+	ctx := c.Request.Context()
 
 	payloadPB := models.UserPayload{
 		Bio: &models.UserBio{
@@ -55,17 +129,16 @@ func (uc *userController) Create(c *gin.Context) {
 		Password: payload.Password,
 	}
 
-	result, err := uc.client.RegisterUser(ctx, &payloadPB)
+	var header metadata.MD
+	result, err := uc.users.Register(outgoing(ctx, c), &payloadPB, grpc.Header(&header))
 	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			panic(err)
-		}
-		c.JSON(http.StatusBadRequest, gin.H{
+		st := status.Convert(err)
+		c.JSON(rpcStatus(err, http.StatusBadRequest), gin.H{
 			"error": st.Message(),
 		})
 		return
 	}
+	wrote(c, header)
 	c.JSON(http.StatusCreated, gin.H{
 		"data": result,
 	})
@@ -73,17 +146,16 @@ func (uc *userController) Create(c *gin.Context) {
 
 func (uc *userController) FindUsers(c *gin.Context) {
 	var res response.JsonResponse
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-	users, err := uc.client.FindUsers(ctx, &emptypb.Empty{})
+	ctx := c.Request.Context()
+	users, err := uc.users.FindUsers(reading(ctx, c))
 	if err != nil {
 		res.Error = true
 		res.Message = err.Error()
-		c.JSON(http.StatusBadRequest, res)
+		c.JSON(rpcStatus(err, http.StatusBadRequest), res)
 		return
 	}
 	res.Error = false
-	res.Data = users.User
+	res.Data = users
 	c.JSON(http.StatusOK, res)
 }
 
@@ -95,15 +167,11 @@ func (uc *userController) FindByUsername(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-	user, err := uc.client.FindByUsername(ctx, &models.Username{Username: uri.Username})
+	ctx := c.Request.Context()
+	user, err := uc.users.FindByUsername(reading(ctx, c), uri.Username)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			panic(err)
-		}
-		c.JSON(http.StatusBadRequest, gin.H{
+		st := status.Convert(err)
+		c.JSON(rpcStatus(err, http.StatusBadRequest), gin.H{
 			"error": st.Message(),
 			"code":  st.Code(),
 		})
@@ -124,15 +192,16 @@ func (uc *userController) DeleteByUsername(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-	_, err = uc.client.DeleteByUsername(ctx, &models.Username{Username: uri.Username})
+	ctx := c.Request.Context()
+	var header metadata.MD
+	err = uc.users.DeleteByUsername(outgoing(ctx, c), uri.Username, grpc.Header(&header))
 	if err != nil {
 		res.Error = true
 		res.Message = err.Error()
-		c.JSON(http.StatusBadRequest, res)
+		c.JSON(rpcStatus(err, http.StatusBadRequest), res)
 		return
 	}
+	wrote(c, header)
 	res.Error = false
 	res.Message = "user has been deleted"
 	c.JSON(http.StatusOK, res)
@@ -150,8 +219,7 @@ func (uc *userController) Update(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+	ctx := c.Request.Context()
 
 	payloadPB := models.UserPayload{
 		Bio: &models.UserBio{
@@ -164,15 +232,43 @@ func (uc *userController) Update(c *gin.Context) {
 		Password: payload.Password,
 	}
 
-	_, err = uc.client.Update(ctx, &payloadPB)
+	var header metadata.MD
+	err = uc.users.Update(outgoing(ctx, c), &payloadPB, grpc.Header(&header))
 	if err != nil {
 		res.Error = true
 		res.Message = err.Error()
-		c.JSON(http.StatusBadRequest, res)
+		c.JSON(rpcStatus(err, http.StatusBadRequest), res)
 		return
 	}
+	wrote(c, header)
 
 	res.Error = false
 	res.Message = "succesfully updated"
 	c.JSON(http.StatusOK, res)
 }
+
+func (uc *userController) Suggest(c *gin.Context) {
+	var res response.JsonResponse
+	var query SuggestQuery
+	err := c.ShouldBindQuery(&query)
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+		c.JSON(http.StatusBadRequest, res)
+		return
+	}
+
+	ctx := c.Request.Context()
+	users, err := uc.users.SuggestUsernames(ctx, query.Prefix, query.Limit)
+	if err != nil {
+		st, _ := status.FromError(err)
+		res.Error = true
+		res.Message = st.Message()
+		res.Code = st.Code()
+		c.JSON(rpcStatus(err, http.StatusBadRequest), res)
+		return
+	}
+	res.Error = false
+	res.Data = users
+	c.JSON(http.StatusOK, res)
+}
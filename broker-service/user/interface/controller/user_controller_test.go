@@ -8,71 +8,128 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
 	"github.com/spriigan/broker/adapters"
+	"github.com/spriigan/broker/config"
 	"github.com/spriigan/broker/infrastructure/router"
+	"github.com/spriigan/broker/metrics"
+	"github.com/spriigan/broker/middleware"
+	"github.com/spriigan/broker/ratelimit"
 	"github.com/spriigan/broker/response"
 	"github.com/spriigan/broker/user/interface/controller"
-	"github.com/spriigan/broker/user/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/stream"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type mockClient struct {
 	mock.Mock
+	// header is what user-service answers writes with.
+	header metadata.MD
 }
 
-func (mc mockClient) RegisterUser(ctx context.Context, in *models.UserPayload, opts ...grpc.CallOption) (*models.UserBio, error) {
-	args := mc.Called(ctx, in)
+func (mc *mockClient) sendHeader(opts []grpc.CallOption) {
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = mc.header
+		}
+	}
+}
+
+func (mc *mockClient) Register(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) (*models.UserBio, error) {
+	mc.sendHeader(opts)
+	args := mc.Called(ctx, user)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.UserBio), args.Error(1)
 }
 
-func (mc mockClient) FindUsers(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*models.Users, error) {
-	args := mc.Called(ctx, in)
+func (mc *mockClient) FindUsers(ctx context.Context, opts ...grpc.CallOption) ([]*models.UserBio, error) {
+	args := mc.Called(ctx)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*models.Users), args.Error(1)
+	return args.Get(0).([]*models.UserBio), args.Error(1)
 }
 
-func (mc mockClient) FindByUsername(ctx context.Context, in *models.Username, opts ...grpc.CallOption) (*models.UserBio, error) {
-	args := mc.Called(ctx, in)
+func (mc *mockClient) FindByUsername(ctx context.Context, username string, opts ...grpc.CallOption) (*models.UserBio, error) {
+	args := mc.Called(ctx, username)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.UserBio), args.Error(1)
 }
 
-func (mc mockClient) DeleteByUsername(ctx context.Context, in *models.Username, opts ...grpc.CallOption) (*emptypb.Empty, error) {
-	args := mc.Called(ctx, in)
-	return nil, args.Error(1)
+func (mc *mockClient) DeleteByUsername(ctx context.Context, username string, opts ...grpc.CallOption) error {
+	mc.sendHeader(opts)
+	return mc.Called(ctx, username).Error(0)
 }
 
-func (mc mockClient) Update(ctx context.Context, in *models.UserPayload, opts ...grpc.CallOption) (*emptypb.Empty, error) {
-	args := mc.Called(ctx, in)
-	return nil, args.Error(1)
+func (mc *mockClient) Update(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) error {
+	mc.sendHeader(opts)
+	return mc.Called(ctx, user).Error(0)
+}
+
+func (mc *mockClient) SuggestUsernames(ctx context.Context, prefix string, limit int32, opts ...grpc.CallOption) ([]*models.UserBio, error) {
+	args := mc.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.UserBio), args.Error(1)
 }
 
 var ac *adapters.AppController
 var client *mockClient
 var mux *gin.Engine
 
+const adminToken = "secret"
+
 func TestMain(m *testing.M) {
 	client = new(mockClient)
+	auditClient = new(mockAuditClient)
+	webhookClient = new(mockWebhookClient)
+	watchClient = new(mockWatchClient)
+	conn = new(mockConn)
+	healthClient = new(mockHealthClient)
+	upstream = make(chan *models.UserChange)
+	watchClient.On("WatchUsers", int64(0)).Return(upstream, nil)
+	changeHub = stream.NewHub(watchClient, 16, 4)
+	ctx, stop := context.WithCancel(context.Background())
+	go changeHub.Run(ctx)
 	ac = &adapters.AppController{
-		User: controller.NewUserController(client),
+		User:    controller.NewUserController(client),
+		Audit:   controller.NewAuditController(auditClient),
+		Webhook: controller.NewWebhookController(webhookClient),
+		Change:  controller.NewChangeController(changeHub, 50*time.Millisecond, []string{"https://admin.example.com"}),
+		Health:  controller.NewHealthController(conn, healthClient),
 	}
-	mux = router.Route(ac)
-	os.Exit(m.Run())
+	mux = router.Route(ac, config.HTTP{
+		AdminToken:     adminToken,
+		RequestTimeout: time.Second,
+		RouteTimeouts:  map[string]time.Duration{"POST /user": 3 * time.Second},
+		MetricsPath:    "/metrics",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}, middleware.RateLimit(ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Now), ratelimit.Limit{}, nil), nil), metrics.New(metrics.Namespace))
+	code := m.Run()
+	stop()
+	os.Exit(code)
 }
 
 func TestRegisterUser(t *testing.T) {
@@ -101,7 +158,7 @@ func TestRegisterUser(t *testing.T) {
 		"success api call": {
 			json: jsonReq,
 			arrange: func(t *testing.T) {
-				client.On("RegisterUser", mock.Anything, mock.Anything).Return(&models.UserBio{}, nil).Once()
+				client.On("Register", mock.Anything, mock.Anything).Return(&models.UserBio{}, nil).Once()
 			},
 			assert: func(t *testing.T, statusCode int, data gin.H) {
 				require.Equal(t, http.StatusCreated, statusCode)
@@ -111,7 +168,7 @@ func TestRegisterUser(t *testing.T) {
 		"failed call": {
 			json: jsonReq,
 			arrange: func(t *testing.T) {
-				client.On("RegisterUser", mock.Anything, mock.Anything).Return(nil, status.Error(codes.FailedPrecondition, "got an error")).Once()
+				client.On("Register", mock.Anything, mock.Anything).Return(nil, status.Error(codes.FailedPrecondition, "got an error")).Once()
 			},
 			assert: func(t *testing.T, statusCode int, data gin.H) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
@@ -144,20 +201,14 @@ func TestRegisterUser(t *testing.T) {
 }
 
 func TestFindUsers(t *testing.T) {
-	users := &models.Users{
-		User: []*models.UserBio{
-			{},
-			{},
-			{},
-		},
-	}
+	users := []*models.UserBio{{}, {}, {}}
 	testTabel := map[string]struct {
 		arrange func(t *testing.T)
 		assert  func(t *testing.T, statusCode int, data interface{}, isError bool)
 	}{
 		"success api call": {
 			arrange: func(t *testing.T) {
-				client.On("FindUsers", mock.Anything, mock.Anything).Return(users, nil).Once()
+				client.On("FindUsers", mock.Anything).Return(users, nil).Once()
 			},
 			assert: func(t *testing.T, statusCode int, data interface{}, isError bool) {
 				require.Equal(t, http.StatusOK, statusCode)
@@ -167,7 +218,7 @@ func TestFindUsers(t *testing.T) {
 		},
 		"failed call": {
 			arrange: func(t *testing.T) {
-				client.On("FindUsers", mock.Anything, mock.Anything).Return(nil, errors.New("got an error")).Once()
+				client.On("FindUsers", mock.Anything).Return(nil, errors.New("got an error")).Once()
 			},
 			assert: func(t *testing.T, statusCode int, data interface{}, isError bool) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
@@ -175,6 +226,15 @@ func TestFindUsers(t *testing.T) {
 				require.True(t, isError)
 			},
 		},
+		"user-service unavailable": {
+			arrange: func(t *testing.T) {
+				client.On("FindUsers", mock.Anything).Return(nil, status.Error(codes.Unavailable, "circuit breaker is open")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, data interface{}, isError bool) {
+				require.Equal(t, http.StatusServiceUnavailable, statusCode)
+				require.True(t, isError)
+			},
+		},
 	}
 
 	for k, v := range testTabel {
@@ -221,9 +281,21 @@ func TestFindByUsername(t *testing.T) {
 				require.NotNil(t, data["error"])
 			},
 		},
-		"bad uri": {
-			uri:     "/user/rt",
-			arrange: func(t *testing.T) {},
+		"error without status": {
+			uri: "/user/ryanpujo",
+			arrange: func(t *testing.T) {
+				client.On("FindByUsername", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, data gin.H) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.Equal(t, "connection reset", data["error"])
+			},
+		},
+		"username rejected by user-service": {
+			uri: "/user/rt",
+			arrange: func(t *testing.T) {
+				client.On("FindByUsername", mock.Anything, "rt").Return(nil, status.Error(codes.InvalidArgument, "username is too short")).Once()
+			},
 			assert: func(t *testing.T, statusCode int, data gin.H) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
 				require.Nil(t, data["data"])
@@ -247,16 +319,27 @@ func TestFindByUsername(t *testing.T) {
 	}
 }
 
+// forwarded matches the contexts carrying actor and requestID to user-service.
+func forwarded(actor, requestID string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return len(md.Get("x-actor")) == 1 && md.Get("x-actor")[0] == actor &&
+			len(md.Get("x-request-id")) == 1 && md.Get("x-request-id")[0] == requestID
+	})
+}
+
 func TestDeleteByUsername(t *testing.T) {
 	testTable := map[string]struct {
 		uri     string
+		remote  string
+		header  http.Header
 		arrange func(t *testing.T)
 		assert  func(t *testing.T, statusCode int, message string, isError bool)
 	}{
 		"success api call": {
 			uri: "/user/ryanpuj0",
 			arrange: func(t *testing.T) {
-				client.On("DeleteByUsername", mock.Anything, mock.Anything).Return(nil, nil).Once()
+				client.On("DeleteByUsername", mock.Anything, mock.Anything).Return(nil).Once()
 			},
 			assert: func(t *testing.T, statusCode int, message string, isError bool) {
 				require.Equal(t, http.StatusOK, statusCode)
@@ -265,10 +348,56 @@ func TestDeleteByUsername(t *testing.T) {
 				require.Equal(t, "user has been deleted", message)
 			},
 		},
+		"forwards caller": {
+			uri:    "/user/ryanpujo",
+			remote: "10.0.0.7:4312",
+			header: http.Header{
+				"X-Actor":      {"ryanpujo"},
+				"X-Request-Id": {"req-1"},
+			},
+			arrange: func(t *testing.T) {
+				client.On("DeleteByUsername", forwarded("ryanpujo", "req-1"), mock.Anything).Return(nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, message string, isError bool) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, isError)
+			},
+		},
+		"caller from an untrusted peer": {
+			uri:    "/user/ryanpujo",
+			remote: "203.0.113.9:4312",
+			header: http.Header{
+				"X-Actor":      {"admin"},
+				"X-Request-Id": {"req-2"},
+			},
+			arrange: func(t *testing.T) {
+				client.On("DeleteByUsername", forwarded(middleware.Anonymous, "req-2"), mock.Anything).Return(nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, message string, isError bool) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, isError)
+			},
+		},
+		"caller with the admin token": {
+			uri:    "/user/ryanpujo",
+			remote: "203.0.113.9:4312",
+			header: http.Header{
+				"Authorization": {"Bearer " + adminToken},
+				"X-Actor":       {"ryanpujo"},
+				"X-Request-Id":  {"req-3"},
+			},
+			arrange: func(t *testing.T) {
+				client.On("DeleteByUsername", forwarded("admin", "req-3"), mock.Anything).Return(nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, message string, isError bool) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, isError)
+			},
+		},
 		"failed call": {
 			uri: "/user/ryanpujo",
 			arrange: func(t *testing.T) {
-				client.On("DeleteByUsername", mock.Anything, mock.Anything).Return(nil, errors.New("got an error")).Once()
+				client.On("DeleteByUsername", mock.Anything, mock.Anything).Return(errors.New("got an error")).Once()
 			},
 			assert: func(t *testing.T, statusCode int, message string, isError bool) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
@@ -277,9 +406,11 @@ func TestDeleteByUsername(t *testing.T) {
 				require.True(t, isError)
 			},
 		},
-		"bad uri": {
-			uri:     "/user/rt",
-			arrange: func(t *testing.T) {},
+		"username rejected by user-service": {
+			uri: "/user/rt",
+			arrange: func(t *testing.T) {
+				client.On("DeleteByUsername", mock.Anything, "rt").Return(status.Error(codes.InvalidArgument, "username is too short")).Once()
+			},
 			assert: func(t *testing.T, statusCode int, message string, isError bool) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
 				require.NotEmpty(t, message)
@@ -293,6 +424,10 @@ func TestDeleteByUsername(t *testing.T) {
 			v.arrange(t)
 
 			req, _ := http.NewRequest(http.MethodDelete, v.uri, nil)
+			req.RemoteAddr = v.remote
+			if v.header != nil {
+				req.Header = v.header
+			}
 			rr := httptest.NewRecorder()
 			mux.ServeHTTP(rr, req)
 			var res response.JsonResponse
@@ -329,7 +464,7 @@ func TestUpdate(t *testing.T) {
 		"succes api call": {
 			json: jsonReq,
 			arrange: func(t *testing.T) {
-				client.On("Update", mock.Anything, mock.Anything).Return(nil, nil).Once()
+				client.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
 			},
 			assert: func(t *testing.T, statusCode int, message string, isError bool) {
 				require.Equal(t, http.StatusOK, statusCode)
@@ -341,7 +476,7 @@ func TestUpdate(t *testing.T) {
 		"fail api call": {
 			json: jsonReq,
 			arrange: func(t *testing.T) {
-				client.On("Update", mock.Anything, mock.Anything).Return(nil, errors.New("got an error")).Once()
+				client.On("Update", mock.Anything, mock.Anything).Return(errors.New("got an error")).Once()
 			},
 			assert: func(t *testing.T, statusCode int, message string, isError bool) {
 				require.Equal(t, http.StatusBadRequest, statusCode)
@@ -375,3 +510,365 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestSuggest(t *testing.T) {
+	users := []*models.UserBio{
+		{Username: "ryan"},
+		{Username: "ryanpujo"},
+	}
+	testTable := map[string]struct {
+		uri     string
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"success api call": {
+			uri: "/user/suggest?prefix=ry&limit=5",
+			arrange: func(t *testing.T) {
+				client.On("SuggestUsernames", mock.Anything, "ry", int32(5)).Return(users, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.False(t, res.Error)
+				require.Len(t, res.Data, 2)
+			},
+		},
+		"failed call": {
+			uri: "/user/suggest?prefix=ry",
+			arrange: func(t *testing.T) {
+				client.On("SuggestUsernames", mock.Anything, mock.Anything, mock.Anything).Return(nil, status.Error(codes.InvalidArgument, "got an error")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+				require.Equal(t, codes.InvalidArgument, res.Code)
+				require.Nil(t, res.Data)
+			},
+		},
+		"missing prefix": {
+			uri:     "/user/suggest",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+		"limit out of range": {
+			uri:     "/user/suggest?prefix=ry&limit=500",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			req, _ := http.NewRequest(http.MethodGet, v.uri, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			var res response.JsonResponse
+			_ = json.NewDecoder(rr.Body).Decode(&res)
+
+			v.assert(t, rr.Code, res)
+		})
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	until := strconv.FormatInt(time.Now().Add(5*time.Second).UnixMilli(), 10)
+	client.header = metadata.Pairs("x-read-primary-until", until)
+	defer func() { client.header = nil }()
+	client.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
+
+	req := httptest.NewRequest(http.MethodPatch, "/user", bytes.NewReader([]byte(`{"fname": "ryan", "lname": "pujo", "username": "ryanpujo", "email": "ryanpuj@ogmail.com", "password": "kjrkjnrjnrntkn"}`)))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, until, cookies[0].Value)
+	require.InDelta(t, 5, cookies[0].MaxAge, 1)
+	require.True(t, cookies[0].HttpOnly)
+
+	testTable := map[string]struct {
+		cookie *http.Cookie
+		expect []string
+	}{
+		"after the write":    {cookie: cookies[0], expect: []string{until}},
+		"without the cookie": {},
+		"forged cookie":      {cookie: &http.Cookie{Name: cookies[0].Name, Value: "soon"}},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			var sent []string
+			client.On("FindByUsername", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				md, _ := metadata.FromOutgoingContext(args.Get(0).(context.Context))
+				sent = md.Get("x-read-primary-until")
+			}).Return(&models.UserBio{Username: "ryanpujo"}, nil).Once()
+			req := httptest.NewRequest(http.MethodGet, "/user/ryanpujo", nil)
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, tc.expect, sent)
+		})
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	budget := func(min, max time.Duration) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			deadline, ok := ctx.Deadline()
+			left := time.Until(deadline)
+			return ok && left > min && left <= max
+		})
+	}
+	testTable := map[string]struct {
+		method  string
+		uri     string
+		body    string
+		ctx     func() (context.Context, context.CancelFunc)
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int)
+	}{
+		"default budget": {
+			method: http.MethodGet,
+			uri:    "/user",
+			arrange: func(t *testing.T) {
+				client.On("FindUsers", budget(0, time.Second)).Return([]*models.UserBio{}, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int) {
+				require.Equal(t, http.StatusOK, statusCode)
+			},
+		},
+		"route budget": {
+			method: http.MethodPost,
+			uri:    "/user",
+			body:   `{"fname":"ryan","lname":"pujo","username":"ryanpujo","email":"ryanpujo@gmail.com","password":"kjrkjnrjnrntkn"}`,
+			arrange: func(t *testing.T) {
+				client.On("Register", budget(2*time.Second, 3*time.Second), mock.Anything).Return(&models.UserBio{}, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int) {
+				require.Equal(t, http.StatusCreated, statusCode)
+			},
+		},
+		"caller deadline is shorter": {
+			method: http.MethodGet,
+			uri:    "/user",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+			arrange: func(t *testing.T) {
+				client.On("FindUsers", budget(0, 100*time.Millisecond)).Return([]*models.UserBio{}, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int) {
+				require.Equal(t, http.StatusOK, statusCode)
+			},
+		},
+		"client went away": {
+			method: http.MethodGet,
+			uri:    "/user",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			arrange: func(t *testing.T) {
+				client.On("FindUsers", mock.MatchedBy(func(ctx context.Context) bool {
+					return errors.Is(ctx.Err(), context.Canceled)
+				})).Return(nil, status.Error(codes.Canceled, "context canceled")).Once()
+			},
+			assert: func(t *testing.T, statusCode int) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+			},
+		},
+		"budget exhausted": {
+			method: http.MethodGet,
+			uri:    "/user/ryanpujo",
+			arrange: func(t *testing.T) {
+				client.On("FindByUsername", mock.Anything, mock.Anything).Return(nil, status.Error(codes.DeadlineExceeded, "context deadline exceeded")).Once()
+			},
+			assert: func(t *testing.T, statusCode int) {
+				require.Equal(t, http.StatusGatewayTimeout, statusCode)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if v.ctx != nil {
+				ctx, cancel = v.ctx()
+			}
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, v.method, v.uri, bytes.NewReader([]byte(v.body)))
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			v.assert(t, rr.Code)
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Now), ratelimit.Limit{Requests: 3, Per: time.Minute}, map[string]ratelimit.Limit{
+		"POST /user": {Requests: 1, Per: time.Minute},
+	})
+	limited := router.Route(ac, config.HTTP{
+		RequestTimeout: time.Second,
+		TrustedProxies: []string{"10.0.0.0/8"},
+		APIKeys:        []string{"key"},
+	}, middleware.RateLimit(limiter, []string{"api_key", "user", "ip"}), metrics.New(metrics.Namespace))
+	client.On("FindUsers", mock.Anything).Return([]*models.UserBio{}, nil).Times(5)
+	client.On("Register", mock.Anything, mock.Anything).Return(&models.UserBio{}, nil).Once()
+
+	send := func(method, uri string, header map[string]string) *httptest.ResponseRecorder {
+		var body *bytes.Reader
+		if method == http.MethodPost {
+			body = bytes.NewReader([]byte(`{"fname":"ryan","lname":"pujo","username":"ryanpujo","email":"ryanpujo@gmail.com","password":"kjrkjnrjnrntkn"}`))
+		} else {
+			body = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, uri, body)
+		req.RemoteAddr = "203.0.113.9:4312"
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if req.Header.Get("X-Forwarded-For") != "" {
+			req.RemoteAddr = "10.0.0.7:4312"
+		}
+		rr := httptest.NewRecorder()
+		limited.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 3; i > 0; i-- {
+		rr := send(http.MethodGet, "/user", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "3", rr.Header().Get("RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(i-1), rr.Header().Get("RateLimit-Remaining"))
+	}
+	rr := send(http.MethodGet, "/user", nil)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "20", rr.Header().Get("Retry-After"))
+	require.Equal(t, "60", rr.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "3;w=60", rr.Header().Get("RateLimit-Policy"))
+	var res response.JsonResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	require.True(t, res.Error)
+
+	// Routes with a limit of their own keep a separate bucket.
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/user", nil).Code)
+	require.Equal(t, http.StatusTooManyRequests, send(http.MethodPost, "/user", nil).Code)
+
+	// Callers are told apart by their user or api key before their address,
+	// once they are verified.
+	require.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/user", map[string]string{"X-Actor": "ryanpujo"}).Code)
+	require.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/user", map[string]string{"X-API-Key": "forged"}).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodGet, "/user", map[string]string{"X-API-Key": "key", "X-Actor": "ryanpujo"}).Code)
+	proxied := map[string]string{"X-Forwarded-For": "203.0.113.9"}
+	require.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/user", proxied).Code, "the proxy forwards the same client")
+	proxied["X-Actor"] = "ryanpujo"
+	require.Equal(t, http.StatusOK, send(http.MethodGet, "/user", proxied).Code)
+
+	// Probes are never limited.
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, send(http.MethodGet, "/healthz", nil).Code)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	client.On("FindByUsername", mock.MatchedBy(func(ctx context.Context) bool {
+		return trace.SpanContextFromContext(ctx).TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736"
+	}), mock.Anything).Return(nil, status.Error(codes.Unavailable, "connection refused")).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/user/ryanpujo", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /user/:username", spans[0].Name)
+	require.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	require.Equal(t, otelcodes.Error, spans[0].Status.Code)
+	require.Contains(t, spans[0].Attributes, attribute.Int("http.status_code", http.StatusServiceUnavailable))
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New(metrics.Namespace)
+	measured := router.Route(ac, config.HTTP{RequestTimeout: time.Second, MetricsPath: "/metrics"}, func(c *gin.Context) { c.Next() }, m)
+	client.On("FindByUsername", mock.Anything, mock.Anything).Return(&models.UserBio{Username: "ryanpujo"}, nil).Twice()
+
+	send := func(uri string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, uri, nil)
+		rr := httptest.NewRecorder()
+		measured.ServeHTTP(rr, req)
+		return rr
+	}
+	require.Equal(t, http.StatusOK, send("/user/ryanpujo").Code)
+	require.Equal(t, http.StatusOK, send("/user/someone").Code)
+	require.Equal(t, http.StatusNotFound, send("/nowhere").Code)
+
+	rr := send("/metrics")
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	require.Contains(t, body, `broker_http_requests_total{code="200",method="GET",route="/user/:username"} 2`)
+	require.Contains(t, body, `broker_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	require.Contains(t, body, `broker_http_request_duration_seconds_count{method="GET",route="/user/:username"} 2`)
+	require.Contains(t, body, "broker_build_info{")
+	client.AssertExpectations(t)
+}
+
+func TestRequestID(t *testing.T) {
+	send := func(id string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, "req-1", send("req-1").Header().Get("X-Request-ID"))
+	generated := send("").Header().Get("X-Request-ID")
+	require.Len(t, generated, 32)
+	require.NotEqual(t, generated, send("").Header().Get("X-Request-ID"))
+	require.Len(t, send("req 1\nforged").Header().Get("X-Request-ID"), 32)
+}
+
+func TestRecovery(t *testing.T) {
+	client.On("FindUsers", mock.Anything).Run(func(args mock.Arguments) {
+		panic("nil map")
+	}).Return(nil, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("X-Request-ID", "req-panic")
+	rr := httptest.NewRecorder()
+	require.NotPanics(t, func() { mux.ServeHTTP(rr, req) })
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	var res response.JsonResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	require.True(t, res.Error)
+	require.Equal(t, codes.Internal, res.Code)
+	require.Equal(t, "internal error, request id req-panic", res.Message)
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/response"
+	"github.com/spriigan/broker/user/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type WebhookController interface {
+	Create(ctx *gin.Context)
+	FindWebhooks(ctx *gin.Context)
+	FindWebhook(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	FindDeliveries(ctx *gin.Context)
+}
+
+type webhookController struct {
+	client models.WebhookServiceClient
+}
+
+type WebhookUri struct {
+	Id int64 `uri:"id" binding:"required,min=1"`
+}
+
+type DeliveryQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending delivering succeeded dead"`
+	Limit    int32  `form:"limit" binding:"omitempty,min=1,max=200"`
+	BeforeId int64  `form:"before_id" binding:"omitempty,min=1"`
+}
+
+func NewWebhookController(client models.WebhookServiceClient) *webhookController {
+	return &webhookController{client: client}
+}
+
+func toWebhook(hook *models.Webhook) domain.Webhook {
+	eventTypes := hook.GetEventTypes()
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return domain.Webhook{
+		Id:         hook.GetId(),
+		Url:        hook.GetUrl(),
+		EventTypes: eventTypes,
+		Active:     hook.GetActive(),
+		Secret:     hook.GetSecret(),
+		CreatedAt:  hook.GetCreatedAt().AsTime(),
+		UpdatedAt:  hook.GetUpdatedAt().AsTime(),
+	}
+}
+
+func optionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func abortWithStatus(c *gin.Context, err error) {
+	var res response.JsonResponse
+	st, _ := status.FromError(err)
+	res.Error = true
+	res.Message = st.Message()
+	res.Code = st.Code()
+	if st.Code() == codes.NotFound {
+		c.JSON(http.StatusNotFound, res)
+		return
+	}
+	c.JSON(rpcStatus(err, http.StatusBadRequest), res)
+}
+
+func badRequest(c *gin.Context, err error) {
+	var res response.JsonResponse
+	res.Error = true
+	res.Message = err.Error()
+	c.JSON(http.StatusBadRequest, res)
+}
+
+func (wc *webhookController) Create(c *gin.Context) {
+	var res response.JsonResponse
+	var payload domain.WebhookPayload
+	err := c.ShouldBindJSON(&payload)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	ctx := c.Request.Context()
+	hook, err := wc.client.CreateWebhook(ctx, &models.WebhookPayload{
+		Url:        payload.Url,
+		EventTypes: payload.EventTypes,
+		Active:     active,
+	})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	res.Error = false
+	res.Data = toWebhook(hook)
+	c.JSON(http.StatusCreated, res)
+}
+
+func (wc *webhookController) FindWebhooks(c *gin.Context) {
+	var res response.JsonResponse
+	ctx := c.Request.Context()
+	result, err := wc.client.FindWebhooks(ctx, &emptypb.Empty{})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	hooks := make([]domain.Webhook, 0, len(result.GetWebhooks()))
+	for _, hook := range result.GetWebhooks() {
+		hooks = append(hooks, toWebhook(hook))
+	}
+	res.Error = false
+	res.Data = hooks
+	c.JSON(http.StatusOK, res)
+}
+
+func (wc *webhookController) FindWebhook(c *gin.Context) {
+	var res response.JsonResponse
+	var uri WebhookUri
+	err := c.ShouldBindUri(&uri)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	hook, err := wc.client.FindWebhook(ctx, &models.WebhookId{Id: uri.Id})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	res.Error = false
+	res.Data = toWebhook(hook)
+	c.JSON(http.StatusOK, res)
+}
+
+// Update only changes the fields present in the body, the others keep the
+// value currently stored by user-service.
+func (wc *webhookController) Update(c *gin.Context) {
+	var res response.JsonResponse
+	var uri WebhookUri
+	err := c.ShouldBindUri(&uri)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	var patch domain.WebhookPatch
+	err = c.ShouldBindJSON(&patch)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	current, err := wc.client.FindWebhook(ctx, &models.WebhookId{Id: uri.Id})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	payload := models.WebhookPayload{
+		Id:         current.GetId(),
+		Url:        current.GetUrl(),
+		EventTypes: current.GetEventTypes(),
+		Active:     current.GetActive(),
+	}
+	if patch.Url != nil {
+		payload.Url = *patch.Url
+	}
+	if patch.EventTypes != nil {
+		payload.EventTypes = *patch.EventTypes
+	}
+	if patch.Active != nil {
+		payload.Active = *patch.Active
+	}
+
+	hook, err := wc.client.UpdateWebhook(ctx, &payload)
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	res.Error = false
+	res.Data = toWebhook(hook)
+	c.JSON(http.StatusOK, res)
+}
+
+func (wc *webhookController) Delete(c *gin.Context) {
+	var uri WebhookUri
+	err := c.ShouldBindUri(&uri)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	_, err = wc.client.DeleteWebhook(ctx, &models.WebhookId{Id: uri.Id})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (wc *webhookController) FindDeliveries(c *gin.Context) {
+	var res response.JsonResponse
+	var uri WebhookUri
+	err := c.ShouldBindUri(&uri)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	var query DeliveryQuery
+	err = c.ShouldBindQuery(&query)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	result, err := wc.client.FindDeliveries(ctx, &models.DeliveryFilter{
+		WebhookId: uri.Id,
+		Status:    query.Status,
+		Limit:     query.Limit,
+		BeforeId:  query.BeforeId,
+	})
+	if err != nil {
+		abortWithStatus(c, err)
+		return
+	}
+
+	deliveries := make([]domain.Delivery, 0, len(result.GetDeliveries()))
+	for _, d := range result.GetDeliveries() {
+		history := make([]domain.DeliveryAttempt, 0, len(d.GetHistory()))
+		for _, attempt := range d.GetHistory() {
+			history = append(history, domain.DeliveryAttempt{
+				StatusCode:  attempt.GetStatusCode(),
+				Error:       attempt.GetError(),
+				DurationMs:  attempt.GetDurationMs(),
+				AttemptedAt: attempt.GetAttemptedAt().AsTime(),
+			})
+		}
+		deliveries = append(deliveries, domain.Delivery{
+			Id:            d.GetId(),
+			WebhookId:     d.GetWebhookId(),
+			EventId:       d.GetEventId(),
+			EventType:     d.GetEventType(),
+			Status:        d.GetStatus(),
+			Attempts:      d.GetAttempts(),
+			NextAttemptAt: optionalTime(d.GetNextAttemptAt()),
+			DeliveredAt:   optionalTime(d.GetDeliveredAt()),
+			CreatedAt:     d.GetCreatedAt().AsTime(),
+			History:       history,
+		})
+	}
+	res.Error = false
+	res.Data = deliveries
+	c.JSON(http.StatusOK, res)
+}
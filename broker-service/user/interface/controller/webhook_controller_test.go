@@ -0,0 +1,269 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/response"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type mockWebhookClient struct {
+	mock.Mock
+}
+
+func (mc *mockWebhookClient) CreateWebhook(ctx context.Context, in *models.WebhookPayload, opts ...grpc.CallOption) (*models.Webhook, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (mc *mockWebhookClient) FindWebhooks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*models.Webhooks, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhooks), args.Error(1)
+}
+
+func (mc *mockWebhookClient) FindWebhook(ctx context.Context, in *models.WebhookId, opts ...grpc.CallOption) (*models.Webhook, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (mc *mockWebhookClient) UpdateWebhook(ctx context.Context, in *models.WebhookPayload, opts ...grpc.CallOption) (*models.Webhook, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (mc *mockWebhookClient) DeleteWebhook(ctx context.Context, in *models.WebhookId, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := mc.Called(ctx, in)
+	return &emptypb.Empty{}, args.Error(0)
+}
+
+func (mc *mockWebhookClient) FindDeliveries(ctx context.Context, in *models.DeliveryFilter, opts ...grpc.CallOption) (*models.Deliveries, error) {
+	args := mc.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Deliveries), args.Error(1)
+}
+
+var webhookClient *mockWebhookClient
+
+func serveAdmin(method, uri string, body []byte) (int, response.JsonResponse) {
+	req, _ := http.NewRequest(method, uri, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	var res response.JsonResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+	return rr.Code, res
+}
+
+func TestCreateWebhook(t *testing.T) {
+	hook := &models.Webhook{Id: 1, Url: "https://example.com/hook", Active: true, Secret: "whsec_test", CreatedAt: timestamppb.Now()}
+	testTable := map[string]struct {
+		body    []byte
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"success api call": {
+			body: []byte(`{"url": "https://example.com/hook", "event_types": ["user.created"]}`),
+			arrange: func(t *testing.T) {
+				webhookClient.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(in *models.WebhookPayload) bool {
+					return in.Url == hook.Url && in.Active && in.EventTypes[0] == "user.created"
+				})).Return(hook, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusCreated, statusCode)
+				require.False(t, res.Error)
+				data := res.Data.(map[string]interface{})
+				require.Equal(t, "whsec_test", data["secret"])
+			},
+		},
+		"created inactive": {
+			body: []byte(`{"url": "https://example.com/hook", "active": false}`),
+			arrange: func(t *testing.T) {
+				webhookClient.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(in *models.WebhookPayload) bool {
+					return !in.Active
+				})).Return(hook, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusCreated, statusCode)
+			},
+		},
+		"bad url": {
+			body:    []byte(`{"url": "not a url"}`),
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+		"rejected by user-service": {
+			body: []byte(`{"url": "https://example.com/hook", "event_types": ["user.renamed"]}`),
+			arrange: func(t *testing.T) {
+				webhookClient.On("CreateWebhook", mock.Anything, mock.Anything).Return(nil, status.Error(codes.InvalidArgument, "unknown event type")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+				require.Equal(t, codes.InvalidArgument, res.Code)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			statusCode, res := serveAdmin(http.MethodPost, "/webhooks", v.body)
+
+			v.assert(t, statusCode, res)
+		})
+	}
+}
+
+func TestFindWebhooks(t *testing.T) {
+	webhookClient.On("FindWebhooks", mock.Anything, mock.Anything).Return(&models.Webhooks{
+		Webhooks: []*models.Webhook{{Id: 1, CreatedAt: timestamppb.Now()}, {Id: 2, CreatedAt: timestamppb.Now()}},
+	}, nil).Once()
+
+	statusCode, res := serveAdmin(http.MethodGet, "/webhooks", nil)
+
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Len(t, res.Data.([]interface{}), 2)
+}
+
+func TestFindWebhook(t *testing.T) {
+	testTable := map[string]struct {
+		uri     string
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"success api call": {
+			uri: "/webhooks/1",
+			arrange: func(t *testing.T) {
+				webhookClient.On("FindWebhook", mock.Anything, mock.MatchedBy(func(in *models.WebhookId) bool {
+					return in.Id == 1
+				})).Return(&models.Webhook{Id: 1, Url: "https://example.com/hook"}, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				require.Equal(t, "https://example.com/hook", res.Data.(map[string]interface{})["url"])
+			},
+		},
+		"not found": {
+			uri: "/webhooks/2",
+			arrange: func(t *testing.T) {
+				webhookClient.On("FindWebhook", mock.Anything, mock.Anything).Return(nil, status.Error(codes.NotFound, "webhook does not exist")).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusNotFound, statusCode)
+				require.True(t, res.Error)
+			},
+		},
+		"bad id": {
+			uri:     "/webhooks/abc",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			statusCode, res := serveAdmin(http.MethodGet, v.uri, nil)
+
+			v.assert(t, statusCode, res)
+		})
+	}
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	current := &models.Webhook{Id: 1, Url: "https://example.com/hook", EventTypes: []string{"user.created"}, Active: true}
+	webhookClient.On("FindWebhook", mock.Anything, mock.Anything).Return(current, nil).Once()
+	webhookClient.On("UpdateWebhook", mock.Anything, mock.MatchedBy(func(in *models.WebhookPayload) bool {
+		return in.Id == 1 && in.Url == current.Url && in.EventTypes[0] == "user.created" && !in.Active
+	})).Return(&models.Webhook{Id: 1, Url: current.Url}, nil).Once()
+
+	statusCode, res := serveAdmin(http.MethodPatch, "/webhooks/1", []byte(`{"active": false}`))
+
+	require.Equal(t, http.StatusOK, statusCode)
+	require.False(t, res.Error)
+	require.False(t, res.Data.(map[string]interface{})["active"].(bool))
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	webhookClient.On("DeleteWebhook", mock.Anything, mock.Anything).Return(nil).Once()
+
+	statusCode, _ := serveAdmin(http.MethodDelete, "/webhooks/1", nil)
+
+	require.Equal(t, http.StatusNoContent, statusCode)
+}
+
+func TestFindDeliveries(t *testing.T) {
+	testTable := map[string]struct {
+		uri     string
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, statusCode int, res response.JsonResponse)
+	}{
+		"success api call": {
+			uri: "/webhooks/1/deliveries?status=dead&limit=5",
+			arrange: func(t *testing.T) {
+				webhookClient.On("FindDeliveries", mock.Anything, mock.MatchedBy(func(in *models.DeliveryFilter) bool {
+					return in.WebhookId == 1 && in.Status == "dead" && in.Limit == 5
+				})).Return(&models.Deliveries{Deliveries: []*models.Delivery{{
+					Id:      3,
+					Status:  "dead",
+					History: []*models.DeliveryAttempt{{StatusCode: 502, Error: "got an error"}},
+				}}}, nil).Once()
+			},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusOK, statusCode)
+				delivery := res.Data.([]interface{})[0].(map[string]interface{})
+				require.Nil(t, delivery["next_attempt_at"])
+				require.Len(t, delivery["history"], 1)
+			},
+		},
+		"bad status": {
+			uri:     "/webhooks/1/deliveries?status=lost",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, statusCode int, res response.JsonResponse) {
+				require.Equal(t, http.StatusBadRequest, statusCode)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			statusCode, res := serveAdmin(http.MethodGet, v.uri, nil)
+
+			v.assert(t, statusCode, res)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package stream
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type connKey struct{}
+
+// WithConn is meant for http.Server.ConnContext, it lets streaming handlers
+// reach the connection to push back the server WriteTimeout.
+func WithConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// ExtendWriteDeadline allows the response of the request carrying ctx to be
+// written for d more. It does nothing when the connection is unknown.
+func ExtendWriteDeadline(ctx context.Context, d time.Duration) {
+	if conn, ok := ctx.Value(connKey{}).(net.Conn); ok {
+		_ = conn.SetWriteDeadline(time.Now().Add(d))
+	}
+}
@@ -0,0 +1,253 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/domain"
+	"golang.org/x/exp/slog"
+)
+
+var ErrSlowSubscriber = errors.New("subscriber could not keep up with the change stream")
+
+type Subscription struct {
+	C      <-chan domain.UserChange
+	ch     chan domain.UserChange
+	mu     sync.Mutex
+	err    error
+	cancel func()
+}
+
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Hub keeps a single WatchUsers stream open to user-service and fans the
+// changes out to every subscriber. The most recent changes are kept so that
+// clients reconnecting with a Last-Event-ID resume from memory, older cursors
+// get their own upstream stream.
+type Hub struct {
+	client models.UserWatchServiceClient
+	size   int
+	buffer int
+
+	mu     sync.Mutex
+	recent []domain.UserChange
+	last   int64
+	subs   map[*Subscription]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+func NewHub(client models.UserWatchServiceClient, size, buffer int) *Hub {
+	return &Hub{
+		client: client,
+		size:   size,
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func toChange(change *models.UserChange) domain.UserChange {
+	user := change.GetUser()
+	return domain.UserChange{
+		Id:   change.GetId(),
+		Type: change.GetType(),
+		User: domain.User{
+			Id:       int(user.GetId()),
+			Fname:    user.GetFname(),
+			Lname:    user.GetLname(),
+			Username: user.GetUsername(),
+			Email:    user.GetEmail(),
+		},
+		OccurredAt: change.GetOccurredAt().AsTime(),
+	}
+}
+
+// covers reports whether every change after lastID is in memory or will be
+// broadcast, it must be called with the lock held.
+func (h *Hub) covers(lastID int64) bool {
+	if lastID == 0 {
+		return true
+	}
+	if h.last == 0 || lastID > h.last {
+		return false
+	}
+	return lastID == h.last || (len(h.recent) > 0 && lastID >= h.recent[0].Id-1)
+}
+
+// Subscribe streams the changes after lastID, or only the upcoming ones when
+// lastID is 0.
+func (h *Hub) Subscribe(ctx context.Context, lastID int64) (*Subscription, error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, context.Canceled
+	}
+	if h.covers(lastID) {
+		defer h.mu.Unlock()
+		backlog := make([]domain.UserChange, 0)
+		if lastID > 0 {
+			for _, change := range h.recent {
+				if change.Id > lastID {
+					backlog = append(backlog, change)
+				}
+			}
+		}
+		ch := make(chan domain.UserChange, h.buffer+len(backlog))
+		for _, change := range backlog {
+			ch <- change
+		}
+		sub := &Subscription{C: ch, ch: ch}
+		sub.cancel = func() { h.unsubscribe(sub) }
+		h.subs[sub] = struct{}{}
+		return sub, nil
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	upstream, err := h.client.WatchUsers(ctx, &models.WatchRequest{AfterId: lastID})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	ch := make(chan domain.UserChange, h.buffer)
+	sub := &Subscription{C: ch, ch: ch, cancel: cancel}
+	go func() {
+		select {
+		case <-h.done:
+			sub.fail(context.Canceled)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer close(ch)
+		for {
+			change, err := upstream.Recv()
+			if err != nil {
+				sub.fail(err)
+				return
+			}
+			select {
+			case ch <- toChange(change):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Len is the number of subscribers served from the shared stream.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) publish(change domain.UserChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if change.Id <= h.last {
+		return
+	}
+	h.last = change.Id
+	h.recent = append(h.recent, change)
+	if len(h.recent) > h.size {
+		h.recent = append(h.recent[:0], h.recent[len(h.recent)-h.size:]...)
+	}
+	for sub := range h.subs {
+		select {
+		case sub.ch <- change:
+		default:
+			sub.fail(ErrSlowSubscriber)
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (h *Hub) cursor() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	close(h.done)
+	for sub := range h.subs {
+		sub.fail(context.Canceled)
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Run follows user-service until ctx is done, reconnecting after the last
+// change received so none is missed across restarts of either side.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.closeAll()
+	delay := time.Second
+	for {
+		received, err := h.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			delay = time.Second
+		}
+		slog.Warn("user change stream interrupted, reconnecting", "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (h *Hub) follow(ctx context.Context) (bool, error) {
+	upstream, err := h.client.WatchUsers(ctx, &models.WatchRequest{AfterId: h.cursor()})
+	if err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		change, err := upstream.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		h.publish(toChange(change))
+	}
+}
@@ -0,0 +1,155 @@
+package stream_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/broker/user/stream"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type fakeStream struct {
+	grpc.ClientStream
+	ctx     context.Context
+	changes chan *models.UserChange
+}
+
+func (s *fakeStream) Recv() (*models.UserChange, error) {
+	select {
+	case change, ok := <-s.changes:
+		if !ok {
+			return nil, errors.New("stream closed")
+		}
+		return change, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+// fakeClient serves the shared stream from live and records the cursors of
+// the dedicated ones.
+type fakeClient struct {
+	live    chan *models.UserChange
+	cursors chan int64
+}
+
+func (c *fakeClient) WatchUsers(ctx context.Context, in *models.WatchRequest, opts ...grpc.CallOption) (models.UserWatchService_WatchUsersClient, error) {
+	c.cursors <- in.AfterId
+	if in.AfterId == 0 {
+		return &fakeStream{ctx: ctx, changes: c.live}, nil
+	}
+	return &fakeStream{ctx: ctx, changes: make(chan *models.UserChange)}, nil
+}
+
+func runHub(t *testing.T, size, buffer int) (*stream.Hub, *fakeClient) {
+	client := &fakeClient{live: make(chan *models.UserChange), cursors: make(chan int64, 10)}
+	hub := stream.NewHub(client, size, buffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
+	require.Equal(t, int64(0), <-client.cursors)
+	return hub, client
+}
+
+func TestSubscribe(t *testing.T) {
+	hub, client := runHub(t, 1, 10)
+	live, err := hub.Subscribe(context.Background(), 0)
+	require.NoError(t, err)
+	defer live.Close()
+	for id := int64(1); id <= 3; id++ {
+		client.live <- &models.UserChange{Id: id}
+	}
+	for id := int64(1); id <= 3; id++ {
+		require.Equal(t, id, (<-live.C).Id)
+	}
+
+	resumed, err := hub.Subscribe(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), (<-resumed.C).Id)
+	resumed.Close()
+	require.Empty(t, client.cursors, "resumed from memory")
+
+	dedicated, err := hub.Subscribe(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), <-client.cursors, "older than memory")
+	dedicated.Close()
+	_, open := <-dedicated.C
+	require.False(t, open)
+
+	ahead, err := hub.Subscribe(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), <-client.cursors, "ahead of the hub")
+	ahead.Close()
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	hub, client := runHub(t, 10, 1)
+	slow, err := hub.Subscribe(context.Background(), 0)
+	require.NoError(t, err)
+	fast, err := hub.Subscribe(context.Background(), 0)
+	require.NoError(t, err)
+	defer fast.Close()
+
+	client.live <- &models.UserChange{Id: 1}
+	require.Equal(t, int64(1), (<-fast.C).Id)
+	client.live <- &models.UserChange{Id: 2}
+	require.Equal(t, int64(2), (<-fast.C).Id)
+
+	require.Equal(t, int64(1), (<-slow.C).Id)
+	_, open := <-slow.C
+	require.False(t, open)
+	require.ErrorIs(t, slow.Err(), stream.ErrSlowSubscriber)
+	require.Equal(t, 1, hub.Len())
+}
+
+func TestReconnect(t *testing.T) {
+	hub, client := runHub(t, 10, 10)
+	sub, err := hub.Subscribe(context.Background(), 0)
+	require.NoError(t, err)
+	defer sub.Close()
+	client.live <- &models.UserChange{Id: 5}
+	require.Equal(t, int64(5), (<-sub.C).Id)
+
+	close(client.live)
+	select {
+	case cursor := <-client.cursors:
+		require.Equal(t, int64(5), cursor)
+	case <-time.After(3 * time.Second):
+		t.Fatal("hub did not reconnect")
+	}
+}
+
+func TestStopEndsSubscriptions(t *testing.T) {
+	client := &fakeClient{live: make(chan *models.UserChange), cursors: make(chan int64, 10)}
+	hub := stream.NewHub(client, 10, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+	require.Equal(t, int64(0), <-client.cursors)
+	shared, err := hub.Subscribe(context.Background(), 0)
+	require.NoError(t, err)
+	dedicated, err := hub.Subscribe(context.Background(), 42)
+	require.NoError(t, err)
+
+	cancel()
+	<-stopped
+
+	for _, sub := range []*stream.Subscription{shared, dedicated} {
+		select {
+		case _, open := <-sub.C:
+			require.False(t, open)
+		case <-time.After(time.Second):
+			t.Fatal("subscription outlived the hub")
+		}
+		require.ErrorIs(t, sub.Err(), context.Canceled)
+	}
+	_, err = hub.Subscribe(context.Background(), 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ActorKey     = "x-actor"
+	RequestIDKey = "x-request-id"
+
+	Anonymous = "anonymous"
+)
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+	passwordKey
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func ActorFrom(ctx context.Context) string {
+	actor, ok := ctx.Value(actorKey).(string)
+	if !ok || actor == "" {
+		return Anonymous
+	}
+	return actor
+}
+
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithPasswordCheck tells Changes how to find out whether a stored password
+// hash is of the password an update was given. Hashes of the same password
+// differ from one another, bcrypt salts each one.
+func WithPasswordCheck(ctx context.Context, same func(hash string) bool) context.Context {
+	return context.WithValue(ctx, passwordKey, same)
+}
+
+// UnaryServerInterceptor copies the actor and request id sent by the caller as
+// grpc metadata into the request context.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		if values := md.Get(ActorKey); len(values) > 0 {
+			ctx = WithActor(ctx, values[0])
+		}
+		if values := md.Get(RequestIDKey); len(values) > 0 {
+			ctx = WithRequestID(ctx, values[0])
+		}
+	}
+	return handler(ctx, req)
+}
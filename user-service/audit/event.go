@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+const (
+	ActionCreate = "user.create"
+	ActionUpdate = "user.update"
+	ActionDelete = "user.delete"
+
+	Redacted = "[REDACTED]"
+)
+
+// NewEvent describes a mutation of a user, before is nil for creations and
+// after is nil for deletions.
+func NewEvent(ctx context.Context, action string, before, after *models.User) *models.AuditEvent {
+	target := after
+	if target == nil {
+		target = before
+	}
+	return &models.AuditEvent{
+		Actor:          ActorFrom(ctx),
+		Action:         action,
+		TargetId:       target.GetId(),
+		TargetUsername: target.GetUsername(),
+		RequestId:      RequestIDFrom(ctx),
+		Diff:           Changes(ctx, before, after),
+	}
+}
+
+// Diff lists the fields that differ between before and after. Passwords are
+// never exposed, only the fact that they changed.
+func Diff(before, after *models.User) map[string]*models.FieldChange {
+	diff := make(map[string]*models.FieldChange)
+	compare := func(field, old, new string) {
+		if old != new {
+			diff[field] = &models.FieldChange{Before: old, After: new}
+		}
+	}
+	compare("fname", before.GetFname(), after.GetFname())
+	compare("lname", before.GetLname(), after.GetLname())
+	compare("username", before.GetUsername(), after.GetUsername())
+	compare("email", before.GetEmail(), after.GetEmail())
+	if before.GetPassword() != after.GetPassword() {
+		change := &models.FieldChange{}
+		if before.GetPassword() != "" {
+			change.Before = Redacted
+		}
+		if after.GetPassword() != "" {
+			change.After = Redacted
+		}
+		diff["password"] = change
+	}
+	return diff
+}
+
+// Changes is the Diff of before and after, without the password when the
+// check WithPasswordCheck put in ctx finds it was set to what it already was.
+func Changes(ctx context.Context, before, after *models.User) map[string]*models.FieldChange {
+	diff := Diff(before, after)
+	if same, ok := ctx.Value(passwordKey).(func(hash string) bool); ok && before != nil && after != nil && same(before.GetPassword()) {
+		delete(diff, "password")
+	}
+	return diff
+}
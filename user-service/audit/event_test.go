@@ -0,0 +1,123 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDiff(t *testing.T) {
+	before := &models.User{Id: 1, Fname: "ryan", Lname: "pujo", Username: "ryanpujo", Email: "ryan@gmail.com", Password: "hash1"}
+	testTable := map[string]struct {
+		before *models.User
+		after  *models.User
+		expect map[string]*models.FieldChange
+	}{
+		"created": {
+			after: before,
+			expect: map[string]*models.FieldChange{
+				"fname":    {After: "ryan"},
+				"lname":    {After: "pujo"},
+				"username": {After: "ryanpujo"},
+				"email":    {After: "ryan@gmail.com"},
+				"password": {After: audit.Redacted},
+			},
+		},
+		"updated": {
+			before: before,
+			after:  &models.User{Id: 1, Fname: "ryan", Lname: "connor", Username: "ryanpujo", Email: "ryan@gmail.com", Password: "hash2"},
+			expect: map[string]*models.FieldChange{
+				"lname":    {Before: "pujo", After: "connor"},
+				"password": {Before: audit.Redacted, After: audit.Redacted},
+			},
+		},
+		"deleted": {
+			before: &models.User{Id: 1, Username: "ryanpujo"},
+			expect: map[string]*models.FieldChange{
+				"username": {Before: "ryanpujo"},
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			actual := audit.Diff(v.before, v.after)
+
+			require.Equal(t, len(v.expect), len(actual))
+			for field, change := range v.expect {
+				require.Contains(t, actual, field)
+				require.Equal(t, change.Before, actual[field].Before)
+				require.Equal(t, change.After, actual[field].After)
+			}
+		})
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "admin"), "req-1")
+	event := audit.NewEvent(ctx, audit.ActionDelete, &models.User{Id: 3, Username: "dabi", Password: "hash"}, nil)
+
+	require.Equal(t, "admin", event.Actor)
+	require.Equal(t, "req-1", event.RequestId)
+	require.Equal(t, audit.ActionDelete, event.Action)
+	require.Equal(t, int64(3), event.TargetId)
+	require.Equal(t, "dabi", event.TargetUsername)
+	require.Equal(t, audit.Redacted, event.Diff["password"].Before)
+
+	event = audit.NewEvent(context.Background(), audit.ActionCreate, nil, &models.User{Id: 4})
+	require.Equal(t, audit.Anonymous, event.Actor)
+	require.Empty(t, event.RequestId)
+}
+
+func TestChanges(t *testing.T) {
+	stored, err := bcrypt.GenerateFromPassword([]byte("kjrkjnrjnrntkn"), bcrypt.MinCost)
+	require.NoError(t, err)
+	before := &models.User{Id: 1, Username: "ryanpujo", Password: string(stored)}
+	testTable := map[string]struct {
+		password string
+		changed  bool
+	}{
+		"same password":  {password: "kjrkjnrjnrntkn"},
+		"new password":   {password: "rkjnrjnkjrntkn", changed: true},
+		"empty password": {changed: true},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			rehashed, err := bcrypt.GenerateFromPassword([]byte(v.password), bcrypt.MinCost)
+			require.NoError(t, err)
+			after := &models.User{Id: 1, Username: "ryanpujo", Password: string(rehashed)}
+			ctx := audit.WithPasswordCheck(context.Background(), func(hash string) bool {
+				return bcrypt.CompareHashAndPassword([]byte(hash), []byte(v.password)) == nil
+			})
+
+			require.Contains(t, audit.Diff(before, after), "password", "the hashes always differ")
+			if v.changed {
+				require.Contains(t, audit.Changes(ctx, before, after), "password")
+				return
+			}
+			require.Empty(t, audit.Changes(ctx, before, after))
+			require.Empty(t, audit.NewEvent(ctx, audit.ActionUpdate, before, after).Diff)
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(audit.ActorKey, "admin", audit.RequestIDKey, "req-1"))
+	var actor, requestID string
+	_, err := audit.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		actor = audit.ActorFrom(ctx)
+		requestID = audit.RequestIDFrom(ctx)
+		return nil, nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "admin", actor)
+	require.Equal(t, "req-1", requestID)
+}
@@ -0,0 +1,80 @@
+package certs_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/certs"
+	platformcerts "github.com/spriigan/RPApp/platform/certs"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func serve(t *testing.T, files platformcerts.Files, authorizer *certs.Authorizer) string {
+	reloader, err := platformcerts.NewReloader(files.Cert, files.Key, files.CA)
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.ServerConfig(true))),
+		grpc.UnaryInterceptor(authorizer.UnaryServerInterceptor),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func check(t *testing.T, addr string, files platformcerts.Files) error {
+	reloader, err := platformcerts.NewReloader(files.Cert, files.Key, files.CA)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientConfig("localhost"))))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	server, err := platformcerts.Dev(dir, "user-service", []string{"localhost"})
+	require.NoError(t, err)
+	broker, err := platformcerts.Dev(dir, "broker", nil)
+	require.NoError(t, err)
+	other, err := platformcerts.Dev(dir, "webapp", nil)
+	require.NoError(t, err)
+	strangers, err := platformcerts.Dev(t.TempDir(), "broker", nil)
+	require.NoError(t, err)
+
+	addr := serve(t, server, certs.NewAuthorizer([]string{"broker"}))
+	openAddr := serve(t, server, certs.NewAuthorizer([]string{"broker"}, healthpb.Health_ServiceDesc.ServiceName))
+
+	testTable := map[string]struct {
+		addr   string
+		client platformcerts.Files
+		code   codes.Code
+	}{
+		"allowed client":        {addr: addr, client: broker, code: codes.OK},
+		"other client":          {addr: addr, client: other, code: codes.PermissionDenied},
+		"no client certificate": {addr: addr, client: platformcerts.Files{CA: broker.CA}, code: codes.Unauthenticated},
+		"open service":          {addr: openAddr, client: platformcerts.Files{CA: broker.CA}, code: codes.OK},
+		"server of another CA":  {addr: addr, client: strangers, code: codes.Unavailable},
+		"client of another CA":  {addr: addr, client: platformcerts.Files{Cert: strangers.Cert, Key: strangers.Key, CA: broker.CA}, code: codes.Unavailable},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			err := check(t, tc.addr, tc.client)
+			require.Equal(t, tc.code, status.Code(err), "%v", err)
+		})
+	}
+}
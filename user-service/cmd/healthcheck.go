@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthcheck asks a running server for its grpc.health.v1 status and exits
+// non-zero unless it is SERVING, so it can be used as a container HEALTHCHECK.
+func healthcheck(args []string) int {
+	port, ok := os.LookupEnv("GRPC_PORT")
+	if !ok || port == "" {
+		port = "8000"
+	}
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:"+port, "address of the server to check")
+	service := fs.String("service", "", "service to check, empty for the server as a whole")
+	timeout := fs.Duration("timeout", 3*time.Second, "time allowed for the check")
+	mode := os.Getenv("TLS_MODE")
+	useTLS := fs.Bool("tls", mode == "tls" || mode == "mtls", "connect over TLS, on when TLS_MODE is tls or mtls")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	creds := insecure.NewCredentials()
+	if *useTLS {
+		// The probe only asks the local server whether it is up, the health
+		// service needs no client certificate and nothing secret is exchanged.
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	}
+	conn, err := grpc.DialContext(ctx, *addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		return 1
+	}
+	defer conn.Close()
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: *service})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		return 1
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		fmt.Fprintln(os.Stderr, "unhealthy:", res.GetStatus())
+		return 1
+	}
+	fmt.Println(res.GetStatus())
+	return 0
+}
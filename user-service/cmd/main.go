@@ -1,23 +1,144 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"github.com/spriigan/RPApp/config"
 	"github.com/spriigan/RPApp/infrastructure"
+	"github.com/spriigan/RPApp/outbox"
 	"github.com/spriigan/RPApp/registry"
+	"golang.org/x/exp/slog"
 )
 
 func main() {
-	app := infrastructure.Application()
-	db := app.ConnectToDB()
-	defer db.Close()
-	register := registry.New(db)
-	close, err := app.StartGrpcServer(register.NewUserServer())
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(healthcheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrateCommand(os.Args[2:]))
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.Print {
+		if err = cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	app := infrastructure.Application(cfg)
+	app.SetupLogging()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := app.SetupTracing()
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
+	db, err := app.ConnectToDB(ctx)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	if db != nil {
+		defer db.Close()
+	}
+	replicas, err := app.ConnectToReplicas(ctx)
 	if err != nil {
-		close()
-		log.Fatal("failed to start the server", err)
+		fatal("failed to connect to the read replicas", err)
 	}
-	fmt.Println("server started")
-	defer close()
+	for _, replica := range replicas {
+		defer replica.Close()
+	}
+	if cfg.Database.Migrate {
+		if err = app.Migrate(ctx, db); err != nil {
+			fatal("failed to migrate the database", err)
+		}
+	}
+	// The pool prepares its statements as it connects, the schema has to be
+	// there by then.
+	pool, err := app.ConnectPgxPool(ctx)
+	if err != nil {
+		fatal("failed to open the pgx pool", err)
+	}
+	if pool != nil {
+		defer pool.Close()
+	}
+	register := registry.New(db, cfg, app.Metrics)
+	register.Replicas = replicas
+	register.Pool = pool
+
+	// The change hub, the username index and the health monitor follow the
+	// signal so WatchUsers streams end and NOT_SERVING is reported as soon as
+	// shutdown starts, the workers keep going until in-flight calls are
+	// drained.
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	run := func(ctx context.Context, fn func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+		}()
+	}
+	run(ctx, register.HealthMonitor().Run)
+	run(ctx, register.RunSuggestIndex)
+	if register.Postgres() {
+		publisher, err := app.NewPublisher()
+		if err != nil {
+			fatal("failed to connect to nats", err)
+		}
+		publishers := outbox.Fanout{register.NewWebhookEnqueuer()}
+		if publisher != nil {
+			publishers = append(publishers, publisher)
+		} else {
+			slog.Warn("nats url is not set, user events are only delivered to webhooks")
+		}
+		defer publishers.Close()
+
+		run(ctx, register.WatchHub().Run)
+		run(workers, register.NewOutboxRelay(publishers).Run)
+		run(workers, register.NewWebhookWorker().Run)
+	} else {
+		slog.Warn("users are not stored in postgres, the audit, webhook and watch services are off", "backend", cfg.Database.Backend())
+	}
+	// Metrics are served until the calls are drained so the shutdown shows up.
+	run(workers, func(ctx context.Context) {
+		if err := app.ServeMetrics(ctx); err != nil {
+			slog.Error("metrics server failed", "error", err)
+		}
+	})
+
+	slog.Info("server started", "port", cfg.GRPC.Port)
+	err = app.StartGrpcServer(ctx, register.RegisterServices)
+	stop()
+	stopWorkers()
+	wg.Wait()
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.GRPC.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("spans were lost", "error", err)
+	}
+	if err != nil {
+		fatal("grpc server failed", err)
+	}
+	slog.Info("server stopped")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
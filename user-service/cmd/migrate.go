@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spriigan/RPApp/config"
+	"github.com/spriigan/RPApp/infrastructure"
+	"github.com/spriigan/RPApp/migrate"
+)
+
+const migrateUsage = `usage: user-service migrate up|down|status|to <version> [flags]
+
+  up       apply every pending migration
+  down     roll back the latest applied migration
+  status   list the migrations and when they were applied
+  to       apply or roll back migrations until <version> is the latest, 0 rolls back everything
+
+The database is configured like the server, run user-service -h for the flags.`
+
+// migrateCommand changes the schema of the configured database, it waits for
+// other instances migrating at the same time.
+func migrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	action, args := args[0], args[1:]
+	var version int64
+	switch action {
+	case "up", "down", "status":
+	case "to":
+		var err error
+		if len(args) > 0 {
+			version, err = strconv.ParseInt(args[0], 10, 64)
+		}
+		if len(args) == 0 || err != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		args = args[1:]
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg, _, err := config.Load(args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if backend := cfg.Database.Backend(); backend != config.Postgres {
+		fmt.Fprintf(os.Stderr, "migrations only apply to postgres, the %s backend creates its own schema\n", backend)
+		return 2
+	}
+	migrations, err := migrate.Embedded()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	app := infrastructure.Application(cfg)
+	app.SetupLogging()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	db, err := app.ConnectToDB(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	migrator := migrate.New(db, migrations)
+
+	if action == "status" {
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		printStatus(states)
+		return 0
+	}
+
+	var steps []migrate.Step
+	switch action {
+	case "up":
+		steps, err = migrator.Up(ctx)
+	case "down":
+		steps, err = migrator.Down(ctx)
+	case "to":
+		steps, err = migrator.To(ctx, version)
+	}
+	for _, step := range steps {
+		verb := "applied"
+		if step.Rollback {
+			verb = "rolled back"
+		}
+		fmt.Printf("%s %d_%s\n", verb, step.Version, step.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(steps) == 0 {
+		fmt.Println("nothing to do")
+	}
+	return 0
+}
+
+func printStatus(states []migrate.State) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range states {
+		applied := "pending"
+		if s.Applied {
+			applied = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		name := s.Name
+		if s.Unknown {
+			name += " (unknown to this binary)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, name, applied)
+	}
+	w.Flush()
+}
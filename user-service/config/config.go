@@ -0,0 +1,377 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spriigan/RPApp/platform/configload"
+	"golang.org/x/exp/slog"
+)
+
+// Options are the command line switches about loading the configuration,
+// ValidationError lists every problem found in it.
+type (
+	Options         = configload.Options
+	ValidationError = configload.ValidationError
+)
+
+// Config is everything user-service can be tuned with. Each value is taken
+// from, in increasing order of precedence, its default, the YAML config file,
+// the environment variable and the command line flag named in its tags.
+type Config struct {
+	GRPC     GRPC     `yaml:"grpc"`
+	Database Database `yaml:"database"`
+	NATS     NATS     `yaml:"nats"`
+	Outbox   Outbox   `yaml:"outbox"`
+	Webhooks Webhooks `yaml:"webhooks"`
+	Watch    Watch    `yaml:"watch"`
+	Health   Health   `yaml:"health"`
+	Cache    Cache    `yaml:"cache"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Metrics  Metrics  `yaml:"metrics"`
+	Log      Log      `yaml:"log"`
+	TLS      TLS      `yaml:"tls"`
+}
+
+type GRPC struct {
+	Port            int           `yaml:"port" env:"GRPC_PORT" flag:"grpc-port" usage:"port the gRPC server listens on"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long in-flight calls may run after a shutdown signal"`
+}
+
+type Database struct {
+	DSN string `yaml:"dsn" env:"DSN" flag:"dsn" secret:"dsn" usage:"postgres connection string, or sqlite:<path> or memory: to run without postgres"`
+	// ReplicaDSNs are read replicas of the postgres DSN points at. Users are
+	// looked up on them unless they were written within ReplicaStickiness,
+	// which is how far replicas may lag behind, by this process or by a
+	// caller sending back the x-read-primary-until header of its write.
+	ReplicaDSNs       []string      `yaml:"replica_dsns" env:"DB_REPLICA_DSNS" flag:"db-replica-dsns" secret:"dsn" usage:"comma separated read replicas of postgres, lookups go to them"`
+	ReplicaStickiness time.Duration `yaml:"replica_stickiness" env:"DB_REPLICA_STICKINESS" flag:"db-replica-stickiness" usage:"how long reads go to the primary after a write"`
+	// ConnectAttempts are spaced by ConnectInterval, doubling after every
+	// attempt up to ConnectMaxInterval.
+	ConnectAttempts    int           `yaml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS" flag:"db-connect-attempts" usage:"how many times to try reaching postgres at startup"`
+	ConnectInterval    time.Duration `yaml:"connect_interval" env:"DB_CONNECT_INTERVAL" flag:"db-connect-interval" usage:"pause after the first failed attempt to reach postgres"`
+	ConnectMaxInterval time.Duration `yaml:"connect_max_interval" env:"DB_CONNECT_MAX_INTERVAL" flag:"db-connect-max-interval" usage:"longest pause between attempts to reach postgres"`
+	// The pool settings apply to the primary and every replica alike, zero
+	// lifts a limit.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"connections open to a database at most"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"idle connections kept open to a database"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"how long a connection is used before it is replaced"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" usage:"how long a connection stays idle before it is closed"`
+	WarmTimeout     time.Duration `yaml:"warm_timeout" env:"DB_WARM_TIMEOUT" flag:"db-warm-timeout" usage:"time allowed to each attempt at loading usernames into the suggestion index"`
+	// Migrate applies pending migrations before serving, the migrate
+	// subcommand does it on demand.
+	Migrate bool `yaml:"migrate" env:"DB_MIGRATE" flag:"db-migrate" usage:"apply pending schema migrations at startup"`
+	// Isolation and TxRetries apply to the transactions writes run in,
+	// postgres aborts some under repeatable_read and serializable for them to
+	// be run again.
+	Isolation string `yaml:"isolation" env:"DB_ISOLATION" flag:"db-isolation" usage:"isolation level of write transactions, read_committed, repeatable_read or serializable"`
+	TxRetries int    `yaml:"tx_retries" env:"DB_TX_RETRIES" flag:"db-tx-retries" usage:"times a write transaction is run again after a serialization failure or deadlock"`
+	// Driver picks how postgres is talked to: stdlib through database/sql,
+	// pgxpool natively with statements prepared per connection. Reading from
+	// replicas needs stdlib.
+	Driver string `yaml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"postgres driver, stdlib or pgxpool"`
+}
+
+// IsolationLevel is Isolation as database/sql names it, LevelDefault when it
+// is not one of the levels.
+func (d Database) IsolationLevel() sql.IsolationLevel {
+	switch d.Isolation {
+	case "read_committed":
+		return sql.LevelReadCommitted
+	case "repeatable_read":
+		return sql.LevelRepeatableRead
+	case "serializable":
+		return sql.LevelSerializable
+	}
+	return sql.LevelDefault
+}
+
+// Backends users can be stored in, picked by the scheme of database.dsn. Only
+// postgres keeps the audit trail, the outbox, webhooks and the change feed,
+// the others are for running a single process without any server.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	Memory   = "memory"
+)
+
+// Backend tells where users are stored: memory: keeps them in the process,
+// sqlite:<path> in a SQLite file and anything else is a postgres DSN.
+func (d Database) Backend() string {
+	switch {
+	case d.DSN == "memory:" || d.DSN == "memory://":
+		return Memory
+	case strings.HasPrefix(d.DSN, "sqlite:"):
+		return SQLite
+	}
+	return Postgres
+}
+
+// SQLitePath is the file of a sqlite DSN, sqlite:users.db and
+// sqlite:///var/lib/users.db alike, sqlite::memory: for a database that
+// lives as long as the process.
+func (d Database) SQLitePath() string {
+	return strings.TrimPrefix(strings.TrimPrefix(d.DSN, "sqlite:"), "//")
+}
+
+type NATS struct {
+	URL           string `yaml:"url" env:"NATS_URL" flag:"nats-url" secret:"dsn" usage:"NATS server, events only go to webhooks when empty"`
+	SubjectPrefix string `yaml:"subject_prefix" env:"NATS_SUBJECT_PREFIX" flag:"nats-subject-prefix" usage:"prefix of the subjects events are published on"`
+}
+
+type Outbox struct {
+	Interval    time.Duration `yaml:"interval" env:"OUTBOX_INTERVAL" flag:"outbox-interval" usage:"how often the outbox is relayed"`
+	BatchSize   int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" flag:"outbox-batch-size" usage:"events relayed per round"`
+	MaxAttempts int           `yaml:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS" flag:"outbox-max-attempts" usage:"failed publishes after which an event is dead-lettered"`
+}
+
+type Webhooks struct {
+	Interval       time.Duration `yaml:"interval" env:"WEBHOOK_INTERVAL" flag:"webhook-interval" usage:"how often due deliveries are attempted"`
+	BatchSize      int           `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE" flag:"webhook-batch-size" usage:"deliveries attempted per round"`
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"WEBHOOK_REQUEST_TIMEOUT" flag:"webhook-request-timeout" usage:"timeout of a single delivery request"`
+}
+
+type Watch struct {
+	Buffer       int           `yaml:"buffer" env:"WATCH_BUFFER" flag:"watch-buffer" usage:"changes queued per subscriber before it is dropped"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WATCH_POLL_INTERVAL" flag:"watch-poll-interval" usage:"how often changes are polled when notifications are missed"`
+	Retention    time.Duration `yaml:"retention" env:"WATCH_RETENTION" flag:"watch-retention" usage:"how long changes are kept for resuming streams"`
+}
+
+type Health struct {
+	Interval time.Duration `yaml:"interval" env:"HEALTH_INTERVAL" flag:"health-interval" usage:"how often postgres is pinged for the health service"`
+	Timeout  time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" flag:"health-timeout" usage:"time a postgres ping may take before the service is reported NOT_SERVING"`
+}
+
+// Cache keeps FindByUsername results in memory. Other replicas only see a
+// write once the entries they cached expire.
+type Cache struct {
+	Enabled     bool          `yaml:"enabled" env:"USER_CACHE_ENABLED" flag:"user-cache-enabled" usage:"cache user lookups by username"`
+	Size        int           `yaml:"size" env:"USER_CACHE_SIZE" flag:"user-cache-size" usage:"users kept in the cache"`
+	TTL         time.Duration `yaml:"ttl" env:"USER_CACHE_TTL" flag:"user-cache-ttl" usage:"how long a cached user is served"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"USER_CACHE_NEGATIVE_TTL" flag:"user-cache-negative-ttl" usage:"how long a username is remembered as not registered"`
+}
+
+// Tracing records spans of calls and queries. Calls from a caller that
+// sampled its trace are always recorded, SampleRatio applies to the others.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"where spans go, none, stdout or otlp"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"base URL of the OTLP/HTTP collector, spans are posted to /v1/traces"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"share of new traces that are recorded, from 0 to 1"`
+}
+
+// Metrics are served on their own listener so they can be scraped without
+// going through gRPC, /debug/log-level is served there too behind AdminToken.
+type Metrics struct {
+	Addr       string `yaml:"addr" env:"METRICS_ADDR" flag:"metrics-addr" usage:"address /metrics is served on, empty disables it"`
+	AdminToken string `yaml:"admin_token" env:"METRICS_ADMIN_TOKEN" flag:"metrics-admin-token" secret:"true" usage:"bearer token of /debug/log-level, it is disabled when empty"`
+}
+
+// Log is where the level starts, it can be changed at runtime through
+// /debug/log-level on the metrics listener.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum level logged, debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"how records are written, json or text"`
+}
+
+// TLS secures the gRPC server. With mtls the client certificates sent are
+// verified against CAFile and every service but health only answers callers
+// whose certificate names one of AllowedClients. Certificate files are read
+// again when they change.
+type TLS struct {
+	Mode           string        `yaml:"mode" env:"TLS_MODE" flag:"tls-mode" usage:"off, tls or mtls"`
+	CertFile       string        `yaml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"PEM certificate of the server"`
+	KeyFile        string        `yaml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"PEM key of the server certificate"`
+	CAFile         string        `yaml:"ca_file" env:"TLS_CA_FILE" flag:"tls-ca-file" usage:"PEM bundle client certificates are verified against"`
+	AllowedClients []string      `yaml:"allowed_clients" env:"TLS_ALLOWED_CLIENTS" flag:"tls-allowed-clients" usage:"comma separated certificate names allowed to call with mtls"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" flag:"tls-reload-interval" usage:"how often the certificate files are checked for changes"`
+	// DevDir replaces the files with ones issued by a throwaway CA kept in
+	// the directory, shared with the broker in local setups.
+	DevDir string `yaml:"dev_dir" env:"TLS_DEV_DIR" flag:"tls-dev-dir" usage:"generate a throwaway CA and certificates in this directory, never in production"`
+}
+
+func Default() Config {
+	return Config{
+		GRPC: GRPC{
+			Port:            8000,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Database: Database{
+			ReplicaStickiness:  5 * time.Second,
+			ConnectAttempts:    10,
+			ConnectInterval:    500 * time.Millisecond,
+			ConnectMaxInterval: 15 * time.Second,
+			MaxOpenConns:       25,
+			MaxIdleConns:       10,
+			ConnMaxLifetime:    30 * time.Minute,
+			ConnMaxIdleTime:    5 * time.Minute,
+			WarmTimeout:        5 * time.Second,
+			Isolation:          "read_committed",
+			TxRetries:          3,
+			Driver:             "stdlib",
+		},
+		NATS: NATS{
+			SubjectPrefix: "events.v1",
+		},
+		Outbox: Outbox{
+			Interval:    time.Second,
+			BatchSize:   100,
+			MaxAttempts: 10,
+		},
+		Webhooks: Webhooks{
+			Interval:       time.Second,
+			BatchSize:      50,
+			RequestTimeout: 10 * time.Second,
+		},
+		Watch: Watch{
+			Buffer:       256,
+			PollInterval: 5 * time.Second,
+			Retention:    24 * time.Hour,
+		},
+		Health: Health{
+			Interval: 5 * time.Second,
+			Timeout:  time.Second,
+		},
+		Cache: Cache{
+			Enabled:     true,
+			Size:        10000,
+			TTL:         time.Minute,
+			NegativeTTL: 5 * time.Second,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://otel-collector:4318",
+			SampleRatio: 1,
+		},
+		Metrics: Metrics{
+			Addr: ":9090",
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		TLS: TLS{
+			Mode:           "off",
+			AllowedClients: []string{"broker"},
+			ReloadInterval: 30 * time.Second,
+		},
+	}
+}
+
+// Load reads the configuration for args, the command line without the program
+// name. It returns flag.ErrHelp when -h was asked for.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	cfg := Default()
+	opts, err := configload.Load("user-service", &cfg, args, lookupEnv)
+	if err != nil {
+		return cfg, opts, err
+	}
+	return cfg, opts, cfg.Validate()
+}
+
+// Validate reports every invalid value together with where it can be fixed.
+func (c *Config) Validate() error {
+	var problems ValidationError
+	check := func(ok bool, path, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s, set it with %s", path, fmt.Sprintf(format, args...), configload.Describe(c, path)))
+		}
+	}
+	positive := func(d time.Duration, path string) {
+		check(d > 0, path, "must be a positive duration, got %s", d)
+	}
+
+	check(c.GRPC.Port > 0 && c.GRPC.Port < 65536, "grpc.port", "must be between 1 and 65535, got %d", c.GRPC.Port)
+	positive(c.GRPC.ShutdownTimeout, "grpc.shutdown_timeout")
+	check(c.Database.DSN != "", "database.dsn", "is required")
+	check(c.Database.ConnectAttempts > 0, "database.connect_attempts", "must be at least 1, got %d", c.Database.ConnectAttempts)
+	positive(c.Database.ConnectInterval, "database.connect_interval")
+	check(c.Database.ConnectMaxInterval >= c.Database.ConnectInterval, "database.connect_max_interval", "must be at least database.connect_interval, got %s", c.Database.ConnectMaxInterval)
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative, got %d", c.Database.MaxOpenConns)
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative, got %d", c.Database.MaxIdleConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative, got %s", c.Database.ConnMaxLifetime)
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "must not be negative, got %s", c.Database.ConnMaxIdleTime)
+	if len(c.Database.ReplicaDSNs) > 0 {
+		check(c.Database.Backend() == Postgres, "database.replica_dsns", "only apply to postgres, got the %s backend", c.Database.Backend())
+		positive(c.Database.ReplicaStickiness, "database.replica_stickiness")
+	}
+	positive(c.Database.WarmTimeout, "database.warm_timeout")
+	if c.Database.Backend() == SQLite {
+		check(c.Database.SQLitePath() != "", "database.dsn", "must name a file after sqlite:")
+	}
+	check(c.Database.IsolationLevel() != sql.LevelDefault, "database.isolation", "must be read_committed, repeatable_read or serializable, got %q", c.Database.Isolation)
+	check(c.Database.TxRetries >= 0, "database.tx_retries", "must not be negative, got %d", c.Database.TxRetries)
+	switch c.Database.Driver {
+	case "stdlib":
+	case "pgxpool":
+		check(c.Database.Backend() == Postgres, "database.driver", "only applies to postgres, got the %s backend", c.Database.Backend())
+		check(len(c.Database.ReplicaDSNs) == 0, "database.driver", "must be stdlib when database.replica_dsns are set, pgxpool reads and writes through the primary only")
+	default:
+		check(false, "database.driver", "must be stdlib or pgxpool, got %q", c.Database.Driver)
+	}
+	check(!c.Database.Migrate || c.Database.Backend() == Postgres, "database.migrate", "only applies to postgres, the %s backend creates its own schema", c.Database.Backend())
+	if c.NATS.URL != "" {
+		u, err := url.Parse(c.NATS.URL)
+		check(err == nil && u.Scheme != "" && u.Host != "", "nats.url", "must look like nats://host:4222")
+	}
+	check(c.NATS.SubjectPrefix != "", "nats.subject_prefix", "must not be empty")
+	positive(c.Outbox.Interval, "outbox.interval")
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size", "must be at least 1, got %d", c.Outbox.BatchSize)
+	check(c.Outbox.MaxAttempts > 0, "outbox.max_attempts", "must be at least 1, got %d", c.Outbox.MaxAttempts)
+	positive(c.Webhooks.Interval, "webhooks.interval")
+	check(c.Webhooks.BatchSize > 0, "webhooks.batch_size", "must be at least 1, got %d", c.Webhooks.BatchSize)
+	positive(c.Webhooks.RequestTimeout, "webhooks.request_timeout")
+	check(c.Watch.Buffer > 0, "watch.buffer", "must be at least 1, got %d", c.Watch.Buffer)
+	positive(c.Watch.PollInterval, "watch.poll_interval")
+	positive(c.Watch.Retention, "watch.retention")
+	positive(c.Health.Interval, "health.interval")
+	positive(c.Health.Timeout, "health.timeout")
+	if c.Cache.Enabled {
+		check(c.Cache.Size > 0, "cache.size", "must be at least 1, got %d", c.Cache.Size)
+		positive(c.Cache.TTL, "cache.ttl")
+		positive(c.Cache.NegativeTTL, "cache.negative_ttl")
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		u, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing.endpoint", "must look like http://otel-collector:4318, got %q", c.Tracing.Endpoint)
+	default:
+		check(false, "tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	if c.Metrics.Addr != "" {
+		_, port, err := net.SplitHostPort(c.Metrics.Addr)
+		check(err == nil && port != "", "metrics.addr", "must look like :9090, got %q", c.Metrics.Addr)
+	}
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "must be json or text, got %q", c.Log.Format)
+	switch c.TLS.Mode {
+	case "off":
+	case "tls", "mtls":
+		positive(c.TLS.ReloadInterval, "tls.reload_interval")
+		if c.TLS.DevDir == "" {
+			check(c.TLS.CertFile != "", "tls.cert_file", "is required with tls.mode %s", c.TLS.Mode)
+			check(c.TLS.KeyFile != "", "tls.key_file", "is required with tls.mode %s", c.TLS.Mode)
+			check(c.TLS.Mode != "mtls" || c.TLS.CAFile != "", "tls.ca_file", "is required with tls.mode mtls")
+		}
+		check(c.TLS.Mode != "mtls" || len(c.TLS.AllowedClients) > 0, "tls.allowed_clients", "must name at least one client with tls.mode mtls")
+	default:
+		check(false, "tls.mode", "must be off, tls or mtls, got %q", c.TLS.Mode)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	return configload.Write(w, c)
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+
+	"github.com/spriigan/RPApp/audit"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	TypeUserCreated = "user.created"
+	TypeUserUpdated = "user.updated"
+	TypeUserDeleted = "user.deleted"
+)
+
+// Types lists every event type user-service publishes.
+var Types = []string{TypeUserCreated, TypeUserUpdated, TypeUserDeleted}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newEnvelope(ctx context.Context, eventType string) *eventsv1.Envelope {
+	return &eventsv1.Envelope{
+		Id:         newID(),
+		Type:       eventType,
+		OccurredAt: timestamppb.Now(),
+		Actor:      audit.ActorFrom(ctx),
+		RequestId:  audit.RequestIDFrom(ctx),
+	}
+}
+
+func snapshot(user *models.User) *eventsv1.User {
+	return &eventsv1.User{
+		Id:       user.GetId(),
+		Fname:    user.GetFname(),
+		Lname:    user.GetLname(),
+		Username: user.GetUsername(),
+		Email:    user.GetEmail(),
+	}
+}
+
+func UserCreated(ctx context.Context, user *models.User) *eventsv1.Envelope {
+	envelope := newEnvelope(ctx, TypeUserCreated)
+	envelope.Payload = &eventsv1.Envelope_UserCreated{
+		UserCreated: &eventsv1.UserCreated{User: snapshot(user)},
+	}
+	return envelope
+}
+
+func UserUpdated(ctx context.Context, before, after *models.User) *eventsv1.Envelope {
+	diff := audit.Changes(ctx, before, after)
+	changed := make([]string, 0, len(diff))
+	for field := range diff {
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+
+	envelope := newEnvelope(ctx, TypeUserUpdated)
+	envelope.Payload = &eventsv1.Envelope_UserUpdated{
+		UserUpdated: &eventsv1.UserUpdated{User: snapshot(after), ChangedFields: changed},
+	}
+	return envelope
+}
+
+func UserDeleted(ctx context.Context, user *models.User) *eventsv1.Envelope {
+	envelope := newEnvelope(ctx, TypeUserDeleted)
+	envelope.Payload = &eventsv1.Envelope_UserDeleted{
+		UserDeleted: &eventsv1.UserDeleted{Id: user.GetId(), Username: user.GetUsername()},
+	}
+	return envelope
+}
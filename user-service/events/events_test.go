@@ -0,0 +1,65 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/events"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEnvelopes(t *testing.T) {
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "admin"), "req-1")
+	before := &models.User{Id: 1, Fname: "ryan", Lname: "pujo", Username: "ryanpujo", Email: "ryan@gmail.com", Password: "hash1"}
+	after := &models.User{Id: 1, Fname: "ryan", Lname: "pujo", Username: "ryanpujo", Email: "pujo@gmail.com", Password: "hash2"}
+
+	testTable := map[string]struct {
+		envelope *eventsv1.Envelope
+		assert   func(t *testing.T, envelope *eventsv1.Envelope)
+	}{
+		"created": {
+			envelope: events.UserCreated(ctx, before),
+			assert: func(t *testing.T, envelope *eventsv1.Envelope) {
+				require.Equal(t, events.TypeUserCreated, envelope.Type)
+				require.Equal(t, "ryanpujo", envelope.GetUserCreated().GetUser().GetUsername())
+			},
+		},
+		"updated": {
+			envelope: events.UserUpdated(ctx, before, after),
+			assert: func(t *testing.T, envelope *eventsv1.Envelope) {
+				require.Equal(t, events.TypeUserUpdated, envelope.Type)
+				require.Equal(t, "pujo@gmail.com", envelope.GetUserUpdated().GetUser().GetEmail())
+				require.Equal(t, []string{"email", "password"}, envelope.GetUserUpdated().GetChangedFields())
+			},
+		},
+		"deleted": {
+			envelope: events.UserDeleted(ctx, before),
+			assert: func(t *testing.T, envelope *eventsv1.Envelope) {
+				require.Equal(t, events.TypeUserDeleted, envelope.Type)
+				require.Equal(t, int64(1), envelope.GetUserDeleted().GetId())
+				require.Equal(t, "ryanpujo", envelope.GetUserDeleted().GetUsername())
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			require.Len(t, v.envelope.Id, 32)
+			require.Equal(t, "admin", v.envelope.Actor)
+			require.Equal(t, "req-1", v.envelope.RequestId)
+			require.NotNil(t, v.envelope.OccurredAt)
+
+			payload, err := proto.Marshal(v.envelope)
+			require.NoError(t, err)
+			require.NotContains(t, string(payload), "hash")
+			var decoded eventsv1.Envelope
+			require.NoError(t, proto.Unmarshal(payload, &decoded))
+
+			v.assert(t, &decoded)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Monitor serves grpc.health.v1 for user-service. Every service reports
+// SERVING only while the database answers pings and whatever was awaited is
+// ready, so orchestrators stop routing to an instance that lost its database.
+type Monitor struct {
+	server   *health.Server
+	db       Pinger
+	services []string
+	interval time.Duration
+	timeout  time.Duration
+	last     healthpb.HealthCheckResponse_ServingStatus
+
+	mu      sync.Mutex
+	awaited map[string]func() bool
+}
+
+// NewMonitor reports NOT_SERVING until the first ping succeeds. services are
+// the fully qualified names the status is published for besides the overall
+// "" service.
+func NewMonitor(db Pinger, interval, timeout time.Duration, services ...string) *Monitor {
+	m := &Monitor{
+		server:   health.NewServer(),
+		db:       db,
+		services: append([]string{""}, services...),
+		interval: interval,
+		timeout:  timeout,
+		awaited:  make(map[string]func() bool),
+	}
+	m.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	return m
+}
+
+func (m *Monitor) Server() healthpb.HealthServer {
+	return m.server
+}
+
+func (m *Monitor) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range m.services {
+		m.server.SetServingStatus(service, status)
+	}
+	m.last = status
+}
+
+// Await keeps the services NOT_SERVING while ready reports false, for parts
+// of the instance that need to catch up before it takes calls.
+func (m *Monitor) Await(name string, ready func() bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.awaited[name] = ready
+}
+
+// waiting names something awaited that is not ready yet, empty when there is
+// none.
+func (m *Monitor) waiting() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, ready := range m.awaited {
+		if !ready() {
+			return name
+		}
+	}
+	return ""
+}
+
+// Check pings the database once, looks at what is awaited and publishes the
+// outcome.
+func (m *Monitor) Check(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	status := healthpb.HealthCheckResponse_SERVING
+	if err := m.db.PingContext(ctx); err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+		if m.last != status {
+			slog.Error("database ping failed, reporting NOT_SERVING", "error", err)
+		}
+	} else if name := m.waiting(); name != "" {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+		if m.last != status {
+			slog.Warn("instance is not ready yet, reporting NOT_SERVING", "awaiting", name)
+		}
+	} else if m.last != status {
+		slog.Info("database is reachable, reporting SERVING")
+	}
+	m.set(status)
+	return status
+}
+
+// Run checks on every interval until ctx is done, then reports NOT_SERVING for
+// good so clients move away while the server drains.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	m.Check(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			m.server.Shutdown()
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
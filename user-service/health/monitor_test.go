@@ -0,0 +1,101 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/health"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type fakeDB struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (db *fakeDB) PingContext(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.err
+}
+
+func (db *fakeDB) fail(err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.err = err
+}
+
+func check(t *testing.T, server healthpb.HealthServer, service string) healthpb.HealthCheckResponse_ServingStatus {
+	res, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return res.GetStatus()
+}
+
+func TestCheck(t *testing.T) {
+	db := new(fakeDB)
+	monitor := health.NewMonitor(db, time.Hour, time.Second, "user.UserService")
+	server := monitor.Server()
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(t, server, ""))
+
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, monitor.Check(context.Background()))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check(t, server, ""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check(t, server, "user.UserService"))
+
+	db.fail(errors.New("connection refused"))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, monitor.Check(context.Background()))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(t, server, ""))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(t, server, "user.UserService"))
+
+	_, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "user.Unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAwait(t *testing.T) {
+	monitor := health.NewMonitor(new(fakeDB), time.Hour, time.Second, "user.UserService")
+	var ready atomic.Bool
+	monitor.Await("username index", ready.Load)
+
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, monitor.Check(context.Background()))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(t, monitor.Server(), "user.UserService"))
+
+	ready.Store(true)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, monitor.Check(context.Background()))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check(t, monitor.Server(), "user.UserService"))
+}
+
+func TestRun(t *testing.T) {
+	db := new(fakeDB)
+	monitor := health.NewMonitor(db, 10*time.Millisecond, time.Second)
+	server := monitor.Server()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		monitor.Run(ctx)
+		close(stopped)
+	}()
+
+	require.Eventually(t, func() bool {
+		return check(t, server, "") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	db.fail(errors.New("connection refused"))
+	require.Eventually(t, func() bool {
+		return check(t, server, "") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	db.fail(nil)
+	require.Eventually(t, func() bool {
+		return check(t, server, "") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-stopped
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(t, server, ""))
+}
@@ -1,83 +1,367 @@
 package infrastructure
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"net"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/nats-io/nats.go"
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/certs"
+	"github.com/spriigan/RPApp/config"
+	"github.com/spriigan/RPApp/interceptor"
+	"github.com/spriigan/RPApp/interface/controller"
+	repo "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/logging"
+	"github.com/spriigan/RPApp/metrics"
+	"github.com/spriigan/RPApp/migrate"
+	"github.com/spriigan/RPApp/outbox"
+	platformcerts "github.com/spriigan/RPApp/platform/certs"
+	"github.com/spriigan/RPApp/platform/logs"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+	"github.com/spriigan/RPApp/platform/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type application struct {
-	Config config
+	Config  config.Config
+	Metrics *metrics.Metrics
 }
 
-func Application() application {
-	return application{
-		Config: config{
-			GRPC_PORT: os.Getenv("GRPC_PORT"),
-			DSN:       os.Getenv("DSN"),
-		},
+func Application(cfg config.Config) application {
+	return application{Config: cfg, Metrics: metrics.New(metrics.Namespace)}
+}
+
+// NewPublisher connects to NATS, it returns nil when no NATS URL is configured
+// in which case events stay in the outbox until a publisher is available.
+func (app *application) NewPublisher() (outbox.Publisher, error) {
+	if app.Config.NATS.URL == "" {
+		return nil, nil
+	}
+	publisher, err := outbox.NewNatsPublisher(app.Config.NATS.URL, app.Config.NATS.SubjectPrefix,
+		nats.Name("user-service"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return publisher, nil
+}
+
+// SetupLogging makes the structured logger the default, what is written with
+// the log package included.
+func (app *application) SetupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(app.Config.Log.Level)); err == nil {
+		logs.Level.Set(level)
+	}
+	slog.SetDefault(logging.New(os.Stderr, app.Config.Log.Format))
+}
+
+// SetupTracing installs the tracer provider, shutdown flushes the spans still
+// buffered.
+func (app *application) SetupTracing() (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+	if app.Config.Tracing.Exporter != tracing.None {
+		exporter, err = tracing.NewExporter(app.Config.Tracing.Exporter, app.Config.Tracing.Endpoint, os.Stdout)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return tracing.Setup("user-service", exporter, app.Config.Tracing.SampleRatio), nil
 }
 
-func (app *application) StartGrpcServer(server models.UserServiceServer) (func(), error) {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", app.Config.GRPC_PORT))
+// StartGrpcServer serves until ctx is done, then stops accepting connections
+// and waits up to the shutdown timeout for in-flight calls before cutting them off.
+// With TLS on, certificate changes on disk are picked up while serving.
+func (app *application) StartGrpcServer(ctx context.Context, register func(s grpc.ServiceRegistrar)) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", app.Config.GRPC.Port))
 	if err != nil {
-		return func() {
-			lis.Close()
-		}, err
+		return err
 	}
-	s := grpc.NewServer()
-	models.RegisterUserServiceServer(s, server)
+	var opts []grpc.ServerOption
+	var authorizer *certs.Authorizer
+	if mode := app.Config.TLS.Mode; mode != "off" {
+		reloader, err := app.certificates()
+		if err != nil {
+			return err
+		}
+		go reloader.Run(ctx, app.Config.TLS.ReloadInterval)
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig(mode == "mtls"))))
+		if mode == "mtls" {
+			authorizer = certs.NewAuthorizer(app.Config.TLS.AllowedClients, healthpb.Health_ServiceDesc.ServiceName)
+		}
+	}
+	opts = append(opts, app.interceptors(authorizer).ServerOptions()...)
+	s := grpc.NewServer(opts...)
+	register(s)
 
-	if err = s.Serve(lis); err != nil {
-		return func() {
-			lis.Close()
-			s.Stop()
-		}, err
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(lis)
+	}()
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
 	}
 
-	return func() {
-		lis.Close()
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(app.Config.GRPC.ShutdownTimeout):
+		slog.Warn("shutdown timeout reached, aborting in-flight calls")
 		s.Stop()
-	}, nil
+		<-stopped
+	}
+	return <-served
+}
+
+// interceptors is the chain every call goes through. Recovery sits inside
+// metrics and logging so a panic is counted and logged as the Internal error it
+// becomes, and outside the rest so it covers them. authorizer is nil unless
+// clients have to present a certificate.
+func (app *application) interceptors(authorizer *certs.Authorizer) *interceptor.Chain {
+	traced := otelgrpc.WithInterceptorFilter(filters.Not(filters.HealthCheck()))
+	chain := new(interceptor.Chain).
+		Use(otelgrpc.UnaryServerInterceptor(traced), otelgrpc.StreamServerInterceptor(traced)).
+		Use(app.Metrics.GRPC.UnaryServerInterceptor, app.Metrics.GRPC.StreamServerInterceptor).
+		Use(logging.UnaryServerInterceptor(slog.Default()), logging.StreamServerInterceptor(slog.Default())).
+		Use(interceptor.Recovery(slog.Default()))
+	if authorizer != nil {
+		chain.Use(authorizer.UnaryServerInterceptor, authorizer.StreamServerInterceptor)
+	}
+	chain.
+		Use(controller.DeadlineInterceptor, nil).
+		Use(audit.UnaryServerInterceptor, nil)
+	if len(app.Config.Database.ReplicaDSNs) > 0 {
+		readYourWrites := controller.ReadYourWrites{Stickiness: app.Config.Database.ReplicaStickiness, Now: time.Now}
+		chain.Use(readYourWrites.UnaryServerInterceptor, nil)
+	}
+	return chain
+}
+
+// certificates loads the server certificate, issued by the dev CA when a dev
+// directory is configured.
+func (app *application) certificates() (*platformcerts.Reloader, error) {
+	cfg := app.Config.TLS
+	files := platformcerts.Files{Cert: cfg.CertFile, Key: cfg.KeyFile, CA: cfg.CAFile}
+	if cfg.DevDir != "" {
+		var err error
+		files, err = platformcerts.Dev(cfg.DevDir, "user-service", []string{"localhost", "127.0.0.1"})
+		if err != nil {
+			return nil, err
+		}
+		slog.Warn("serving with certificates of a throwaway CA", "dir", cfg.DevDir)
+	}
+	return platformcerts.NewReloader(files.Cert, files.Key, files.CA)
+}
+
+// ServeMetrics serves /metrics and /debug/log-level on their own listener
+// until ctx is done, it returns at once when no address is configured.
+func (app *application) ServeMetrics(ctx context.Context) error {
+	if app.Config.Metrics.Addr == "" {
+		return nil
+	}
+	srv := http.Server{Addr: app.Config.Metrics.Addr, Handler: app.MetricsHandler(), ReadHeaderTimeout: 5 * time.Second}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Config.GRPC.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// MetricsHandler serves /metrics to anyone and /debug/log-level to callers
+// with the admin token.
+func (app *application) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", platformmetrics.Handler(app.Metrics.Registry))
+	mux.Handle("/debug/log-level", requireToken(app.Config.Metrics.AdminToken, logs.LevelHandler()))
+	return mux
 }
 
-func openDB(dsn string) (*sql.DB, error) {
+// requireToken only lets through requests carrying "Authorization: Bearer
+// <token>", h is disabled when no token is configured. The metrics listener is
+// reachable by whatever scrapes it, changing the log level is not for them.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		switch {
+		case token == "":
+			http.Error(w, "disabled, no admin token is configured", http.StatusForbidden)
+		case subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1:
+			http.Error(w, "admin token is missing or invalid", http.StatusUnauthorized)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Migrate applies the pending migrations built into the binary.
+func (app *application) Migrate(ctx context.Context, db *sql.DB) error {
+	migrations, err := migrate.Embedded()
+	if err != nil {
+		return err
+	}
+	steps, err := migrate.New(db, migrations).Up(ctx)
+	for _, step := range steps {
+		slog.Info("migration applied", "version", step.Version, "name", step.Name)
+	}
+	return err
+}
+
+func openDB(ctx context.Context, dsn string) (*sql.DB, error) {
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 	return db, nil
 }
 
-func (app *application) ConnectToDB() *sql.DB {
-	ticker := time.NewTicker(2 * time.Second)
-	var db *sql.DB
-	var err error
-	count := 0
+// ConnectToDB opens the database users are stored in, retrying until postgres
+// is up, ctx is done or the attempts run out. It returns nil with the memory
+// backend, which has none.
+func (app *application) ConnectToDB(ctx context.Context) (*sql.DB, error) {
+	switch app.Config.Database.Backend() {
+	case config.Memory:
+		slog.Warn("users are kept in memory and lost on exit")
+		return nil, nil
+	case config.SQLite:
+		db, err := repo.OpenSQLite(ctx, app.Config.Database.SQLitePath())
+		if err != nil {
+			return nil, err
+		}
+		app.Metrics.WatchDB(db, "users")
+		return db, nil
+	}
+	return app.connect(ctx, app.Config.Database.DSN, "users")
+}
 
-	for db == nil {
-		db, err = openDB(app.Config.DSN)
+// ConnectToReplicas opens the read replicas, none unless configured.
+func (app *application) ConnectToReplicas(ctx context.Context) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(app.Config.Database.ReplicaDSNs))
+	for i, dsn := range app.Config.Database.ReplicaDSNs {
+		db, err := app.connect(ctx, dsn, fmt.Sprintf("users_replica_%d", i))
 		if err != nil {
-			log.Println("postgres is not ready yet:", err)
+			for _, replica := range replicas {
+				_ = replica.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, db)
+	}
+	return replicas, nil
+}
+
+// ConnectPgxPool opens the native pool users are read and written through
+// with the pgxpool driver, nil with the stdlib one.
+func (app *application) ConnectPgxPool(ctx context.Context) (*pgxpool.Pool, error) {
+	cfg := app.Config.Database
+	if cfg.Driver != "pgxpool" {
+		return nil, nil
+	}
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxOpenConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.ConnMaxLifetime
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.ConnMaxIdleTime
+	}
+	var pool *pgxpool.Pool
+	err = app.retry(ctx, "users_pgxpool", func() (err error) {
+		pool, err = repo.OpenPgxPool(ctx, poolConfig.Copy())
+		return err
+	})
+	return pool, err
+}
+
+// connect opens a postgres pool, retrying until it is up. name tells the
+// pool apart in logs and metrics.
+func (app *application) connect(ctx context.Context, dsn, name string) (*sql.DB, error) {
+	cfg := app.Config.Database
+	var db *sql.DB
+	err := app.retry(ctx, name, func() (err error) {
+		db, err = openDB(ctx, dsn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	app.Metrics.WatchDB(db, name)
+	return db, nil
+}
+
+// retry makes attempts at reaching postgres, pausing twice as long after
+// every failed one.
+func (app *application) retry(ctx context.Context, name string, attempt func() error) error {
+	cfg := app.Config.Database
+	pause := cfg.ConnectInterval
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if n >= cfg.ConnectAttempts {
+			return fmt.Errorf("%s: postgres is still not ready after %d attempts: %w", name, n, err)
+		}
+		slog.Warn("postgres is not ready yet", "db", name, "error", err, "attempt", n, "retry_in", pause)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
 		}
-		count++
-		if count > 5 {
-			log.Fatal("cant connect to postgres:", err)
+		if pause *= 2; pause > cfg.ConnectMaxInterval {
+			pause = cfg.ConnectMaxInterval
 		}
-		<-ticker.C
 	}
-	return db
 }
@@ -0,0 +1,64 @@
+package infrastructure_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/RPApp/config"
+	"github.com/spriigan/RPApp/infrastructure"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	testTable := map[string]struct {
+		token         string
+		path          string
+		authorization string
+		expect        int
+	}{
+		"metrics need no token": {
+			token:  "secret",
+			path:   "/metrics",
+			expect: http.StatusOK,
+		},
+		"log level with the token": {
+			token:         "secret",
+			path:          "/debug/log-level",
+			authorization: "Bearer secret",
+			expect:        http.StatusOK,
+		},
+		"log level without a token": {
+			token:  "secret",
+			path:   "/debug/log-level",
+			expect: http.StatusUnauthorized,
+		},
+		"log level with a wrong token": {
+			token:         "secret",
+			path:          "/debug/log-level",
+			authorization: "Bearer guess",
+			expect:        http.StatusUnauthorized,
+		},
+		"log level without a configured token": {
+			path:          "/debug/log-level",
+			authorization: "Bearer ",
+			expect:        http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Metrics.AdminToken = tc.token
+			app := infrastructure.Application(cfg)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			app.MetricsHandler().ServeHTTP(rec, req)
+			require.Equal(t, tc.expect, rec.Code)
+		})
+	}
+}
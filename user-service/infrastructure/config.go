@@ -1,6 +0,0 @@
-package infrastructure
-
-type config struct {
-	GRPC_PORT string
-	DSN       string
-}
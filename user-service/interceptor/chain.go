@@ -0,0 +1,32 @@
+// Package interceptor assembles the gRPC server interceptors and holds the
+// ones that are not tied to a concern of their own.
+package interceptor
+
+import "google.golang.org/grpc"
+
+// Chain is the ordered list of interceptors every call goes through, the first
+// added runs outermost.
+type Chain struct {
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+}
+
+// Use appends an interceptor for unary calls and one for streams, either can be
+// nil when the concern only applies to one kind of call.
+func (c *Chain) Use(unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) *Chain {
+	if unary != nil {
+		c.unary = append(c.unary, unary)
+	}
+	if stream != nil {
+		c.stream = append(c.stream, stream)
+	}
+	return c
+}
+
+// ServerOptions installs the chain on a server.
+func (c *Chain) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.unary...),
+		grpc.ChainStreamInterceptor(c.stream...),
+	}
+}
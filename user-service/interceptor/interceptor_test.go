@@ -0,0 +1,125 @@
+package interceptor_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/interceptor"
+	"github.com/spriigan/RPApp/logging"
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type panickingHealth struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (panickingHealth) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	panic("check is broken")
+}
+
+func (panickingHealth) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	panic("watch is broken")
+}
+
+func TestRecovery(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/FindUsers"}
+	testTable := map[string]struct {
+		ctx     context.Context
+		handler grpc.UnaryHandler
+		expect  error
+	}{
+		"no panic": {
+			ctx: context.Background(),
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.NotFound, "user does not exist")
+			},
+			expect: status.Error(codes.NotFound, "user does not exist"),
+		},
+		"panic with request id": {
+			ctx: audit.WithRequestID(context.Background(), "req-1"),
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("nil map")
+			},
+			expect: status.Error(codes.Internal, "internal error, request id req-1"),
+		},
+		"panic without request id": {
+			ctx: context.Background(),
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("nil map")
+			},
+			expect: status.Error(codes.Internal, "internal error"),
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			unary, _ := interceptor.Recovery(logging.New(&out, logs.JSON))
+			_, err := unary(tc.ctx, nil, info, tc.handler)
+			require.Equal(t, tc.expect.Error(), err.Error())
+			if status.Code(err) == codes.Internal {
+				require.Contains(t, out.String(), `"panic":"nil map"`)
+				require.Contains(t, out.String(), `"stack":"goroutine`)
+			} else {
+				require.Empty(t, out.String())
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}
+	}
+	var out bytes.Buffer
+	logger := logging.New(&out, logs.JSON)
+	chain := new(interceptor.Chain).
+		Use(logging.UnaryServerInterceptor(logger), logging.StreamServerInterceptor(logger)).
+		Use(interceptor.Recovery(logger)).
+		Use(record("auth"), nil).
+		Use(record("metrics"), nil)
+
+	lis := bufconn.Listen(1 << 16)
+	s := grpc.NewServer(chain.ServerOptions()...)
+	healthpb.RegisterHealthServer(s, panickingHealth{})
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	health := healthpb.NewHealthClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), audit.RequestIDKey, "req-1")
+	_, err = health.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, "internal error, request id req-1", status.Convert(err).Message())
+	require.Equal(t, []string{"auth", "metrics"}, order)
+
+	watch, err := health.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// The server survived both and the logging interceptor saw the Internal.
+	require.Contains(t, out.String(), `"panic":"watch is broken"`)
+	require.Contains(t, out.String(), `"grpc.code":"Internal"`)
+	require.Contains(t, out.String(), `"request_id":"req-1"`)
+}
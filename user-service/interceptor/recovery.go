@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/spriigan/RPApp/audit"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recovery turns a panicking handler into an Internal error naming the request
+// id, the panic is logged with its stack. Goroutines started by a handler are
+// not covered.
+func Recovery(logger *slog.Logger) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, logger, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), logger, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
+
+func recovered(ctx context.Context, logger *slog.Logger, method string, p interface{}) error {
+	logger.ErrorCtx(ctx, "panic handling call",
+		"grpc.method", method,
+		"panic", fmt.Sprint(p),
+		"stack", string(debug.Stack()),
+	)
+	if id := audit.RequestIDFrom(ctx); id != "" {
+		return status.Errorf(codes.Internal, "internal error, request id %s", id)
+	}
+	return status.Error(codes.Internal, "internal error")
+}
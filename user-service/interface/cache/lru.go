@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/protobuf/proto"
+)
+
+type entry struct {
+	username string
+	// user is nil for usernames known not to be registered.
+	user    *models.User
+	expires time.Time
+}
+
+// LRU holds up to size users by username, dropping the least recently used
+// first. Entries expire after ttl, or negativeTTL for missing users.
+type LRU struct {
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+	metrics     *Metrics
+
+	mu         sync.Mutex
+	order      *list.List
+	items      map[string]*list.Element
+	byID       map[int64]string
+	generation uint64
+}
+
+func NewLRU(size int, ttl, negativeTTL time.Duration, now func() time.Time, metrics *Metrics) *LRU {
+	return &LRU{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         now,
+		metrics:     metrics,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+		byID:        make(map[int64]string),
+	}
+}
+
+// Get returns a copy of the cached user, ok is false when username is not
+// cached. A nil user with ok means it is known not to be registered.
+func (c *LRU) Get(username string) (user *models.User, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[username]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	if e.user == nil {
+		return nil, true
+	}
+	return proto.Clone(e.user).(*models.User), true
+}
+
+// Generation changes with every invalidation. Add ignores values loaded in an
+// earlier generation since they may predate a write.
+func (c *LRU) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Add caches user under username, a nil user caches that it is missing.
+func (c *LRU) Add(username string, user *models.User, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	ttl := c.ttl
+	if user == nil {
+		ttl = c.negativeTTL
+	} else {
+		user = proto.Clone(user).(*models.User)
+	}
+	if el, ok := c.items[username]; ok {
+		c.remove(el)
+	}
+	c.items[username] = c.order.PushFront(&entry{username: username, user: user, expires: c.now().Add(ttl)})
+	if user != nil {
+		if old, ok := c.byID[user.Id]; ok && old != username {
+			c.remove(c.items[old])
+		}
+		c.byID[user.Id] = username
+	}
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.metrics.Evictions.Inc()
+	}
+}
+
+// Invalidate drops username and whatever username the user id was cached
+// under, which differs once the user was renamed.
+func (c *LRU) Invalidate(username string, id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.metrics.Invalidations.Inc()
+	if el, ok := c.items[username]; ok {
+		c.remove(el)
+	}
+	if old, ok := c.byID[id]; ok {
+		c.remove(c.items[old])
+	}
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	if el == nil {
+		return
+	}
+	e := c.order.Remove(el).(*entry)
+	delete(c.items, e.username)
+	if e.user != nil && c.byID[e.user.Id] == e.username {
+		delete(c.byID, e.user.Id)
+	}
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spriigan/RPApp/interface/cache"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestLRU(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(c *cache.LRU, clk *clock)
+		assert  func(t *testing.T, c *cache.LRU)
+	}{
+		"hit returns a copy": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				c.Add("ryan", &models.User{Id: 1, Username: "ryan"}, c.Generation())
+				user, _ := c.Get("ryan")
+				user.Username = "changed"
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				user, ok := c.Get("ryan")
+				require.True(t, ok)
+				require.Equal(t, "ryan", user.Username)
+			},
+		},
+		"missing user": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				c.Add("ghost", nil, c.Generation())
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				user, ok := c.Get("ghost")
+				require.True(t, ok)
+				require.Nil(t, user)
+			},
+		},
+		"expired": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				c.Add("ryan", &models.User{Id: 1, Username: "ryan"}, c.Generation())
+				c.Add("ghost", nil, c.Generation())
+				clk.now = clk.now.Add(time.Second)
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				_, ok := c.Get("ghost")
+				require.False(t, ok, "missing users expire first")
+				_, ok = c.Get("ryan")
+				require.True(t, ok)
+				require.Equal(t, 1, c.Len())
+			},
+		},
+		"least recently used is evicted": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				c.Add("a", &models.User{Id: 1, Username: "a"}, c.Generation())
+				c.Add("b", &models.User{Id: 2, Username: "b"}, c.Generation())
+				c.Add("c", &models.User{Id: 3, Username: "c"}, c.Generation())
+				c.Get("a")
+				c.Add("d", &models.User{Id: 4, Username: "d"}, c.Generation())
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				_, ok := c.Get("b")
+				require.False(t, ok)
+				_, ok = c.Get("a")
+				require.True(t, ok)
+				require.Equal(t, 3, c.Len())
+			},
+		},
+		"invalidate renamed user": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				c.Add("ryan", &models.User{Id: 1, Username: "ryan"}, c.Generation())
+				c.Add("ryanpujo", nil, c.Generation())
+				c.Invalidate("ryanpujo", 1)
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				require.Equal(t, 0, c.Len())
+			},
+		},
+		"stale load is dropped": {
+			arrange: func(c *cache.LRU, clk *clock) {
+				generation := c.Generation()
+				c.Invalidate("ryan", 1)
+				c.Add("ryan", &models.User{Id: 1, Username: "ryan"}, generation)
+			},
+			assert: func(t *testing.T, c *cache.LRU) {
+				_, ok := c.Get("ryan")
+				require.False(t, ok)
+			},
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			clk := &clock{now: time.Unix(0, 0)}
+			c := cache.NewLRU(3, time.Minute, time.Second, clk.Now, cache.NewMetrics(prometheus.NewRegistry(), "test"))
+			tc.arrange(c, clk)
+			tc.assert(t, c)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics counts how the cache is doing. NegativeHits are hits on usernames
+// known not to be registered, Coalesced are misses that waited on a lookup
+// already in flight instead of querying themselves.
+type Metrics struct {
+	Hits          prometheus.Counter
+	NegativeHits  prometheus.Counter
+	Misses        prometheus.Counter
+	Coalesced     prometheus.Counter
+	Evictions     prometheus.Counter
+	Invalidations prometheus.Counter
+}
+
+// NewMetrics registers the counters with reg under namespace, as
+// <namespace>_cache_hits_total and so on.
+func NewMetrics(reg prometheus.Registerer, namespace string) *Metrics {
+	counter := func(name, help string) prometheus.Counter {
+		c := prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      name,
+			Help:      help,
+		})
+		reg.MustRegister(c)
+		return c
+	}
+	return &Metrics{
+		Hits:          counter("hits_total", "Lookups answered with a cached user."),
+		NegativeHits:  counter("negative_hits_total", "Lookups answered with a cached absence of the user."),
+		Misses:        counter("misses_total", "Lookups that went to the repository."),
+		Coalesced:     counter("coalesced_total", "Misses that waited on a lookup of the same user already in flight."),
+		Evictions:     counter("evictions_total", "Entries evicted to make room for new ones."),
+		Invalidations: counter("invalidations_total", "Entries dropped because the user changed."),
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	repo "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
+)
+
+// userRepository answers FindByUsername from an LRU, loading misses through
+// the wrapped repository once however many callers ask at the same time.
+// Writes going through it invalidate what they touch, writes made by other
+// replicas show up once the entries expire.
+type userRepository struct {
+	repository.UserRepository
+	cache   *LRU
+	metrics *Metrics
+	group   singleflight.Group
+}
+
+func NewUserRepository(next repository.UserRepository, cache *LRU, metrics *Metrics) *userRepository {
+	return &userRepository{UserRepository: next, cache: cache, metrics: metrics}
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	// A transaction sees its own writes, which must not be served to others.
+	if repo.InTx(ctx) {
+		return r.UserRepository.FindByUsername(ctx, username)
+	}
+	if user, ok := r.cache.Get(username); ok {
+		if user == nil {
+			r.metrics.NegativeHits.Inc()
+			return nil, repo.ErrNoUserFound
+		}
+		r.metrics.Hits.Inc()
+		return user, nil
+	}
+	r.metrics.Misses.Inc()
+
+	generation := r.cache.Generation()
+	leader := false
+	result := r.group.DoChan(username, func() (interface{}, error) {
+		leader = true
+		user, err := r.UserRepository.FindByUsername(ctx, username)
+		switch {
+		case err == nil:
+			r.cache.Add(username, user, generation)
+		case errors.Is(err, repo.ErrNoUserFound):
+			r.cache.Add(username, nil, generation)
+		}
+		return user, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if leader {
+			return res.Val.(*models.User), res.Err
+		}
+		r.metrics.Coalesced.Inc()
+		if errors.Is(res.Err, context.Canceled) || errors.Is(res.Err, context.DeadlineExceeded) {
+			// The caller that started the lookup gave up, not this one.
+			return r.UserRepository.FindByUsername(ctx, username)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return proto.Clone(res.Val.(*models.User)).(*models.User), nil
+	}
+}
+
+// invalidate runs after every write, failed ones included since they may
+// have committed before failing. A write made in a transaction is invalidated
+// again once it commits, lookups in between cache what it replaces.
+func (r *userRepository) invalidate(ctx context.Context, username string, id int64) {
+	drop := func() {
+		r.group.Forget(username)
+		r.cache.Invalidate(username, id)
+	}
+	drop()
+	if repo.InTx(ctx) {
+		repo.AfterCommit(ctx, drop)
+	}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	id, err := r.UserRepository.Create(ctx, user)
+	r.invalidate(ctx, user.GetBio().GetUsername(), int64(id))
+	return id, err
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.UserPayload) error {
+	err := r.UserRepository.Update(ctx, user)
+	r.invalidate(ctx, user.GetBio().GetUsername(), user.GetBio().GetId())
+	return err
+}
+
+func (r *userRepository) DeleteByUsername(ctx context.Context, username string) error {
+	err := r.UserRepository.DeleteByUsername(ctx, username)
+	r.invalidate(ctx, username, 0)
+	return err
+}
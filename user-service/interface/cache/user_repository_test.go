@@ -0,0 +1,217 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spriigan/RPApp/interface/cache"
+	repo "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/interface/repository/repositorytest"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRepo serves users from a map, holding lookups until release is
+// closed when it is set.
+type fakeUserRepo struct {
+	repository.UserRepository
+	mu      sync.Mutex
+	users   map[string]*models.User
+	lookups int32
+	release chan struct{}
+}
+
+func (f *fakeUserRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	atomic.AddInt32(&f.lookups, 1)
+	if f.release != nil {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[username]
+	if !ok {
+		return nil, repo.ErrNoUserFound
+	}
+	return user, nil
+}
+
+func (f *fakeUserRepo) Update(ctx context.Context, user *models.UserPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, u := range f.users {
+		if u.Id == user.Bio.Id {
+			delete(f.users, name)
+		}
+	}
+	f.users[user.Bio.Username] = &models.User{Id: user.Bio.Id, Username: user.Bio.Username}
+	return nil
+}
+
+func (f *fakeUserRepo) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := len(f.users) + 1
+	f.users[user.Bio.Username] = &models.User{Id: int64(id), Username: user.Bio.Username}
+	return id, nil
+}
+
+func (f *fakeUserRepo) DeleteByUsername(ctx context.Context, username string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.users, username)
+	return nil
+}
+
+func newRepo() (*fakeUserRepo, repository.UserRepository, *cache.Metrics) {
+	next := &fakeUserRepo{users: map[string]*models.User{"ryan": {Id: 1, Username: "ryan"}}}
+	metrics := cache.NewMetrics(prometheus.NewRegistry(), "test")
+	lru := cache.NewLRU(10, time.Minute, time.Minute, time.Now, metrics)
+	return next, cache.NewUserRepository(next, lru, metrics), metrics
+}
+
+func TestFindByUsername(t *testing.T) {
+	ctx := context.Background()
+	next, users, metrics := newRepo()
+
+	for i := 0; i < 3; i++ {
+		user, err := users.FindByUsername(ctx, "ryan")
+		require.NoError(t, err)
+		require.Equal(t, "ryan", user.Username)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := users.FindByUsername(ctx, "ghost")
+		require.ErrorIs(t, err, repo.ErrNoUserFound)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&next.lookups))
+	require.Equal(t, float64(2), testutil.ToFloat64(metrics.Misses))
+	require.Equal(t, float64(2), testutil.ToFloat64(metrics.Hits))
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.NegativeHits))
+}
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+	_, users, _ := newRepo()
+	_, err := users.FindByUsername(ctx, "ryanpujo")
+	require.ErrorIs(t, err, repo.ErrNoUserFound)
+	_, err = users.FindByUsername(ctx, "ryan")
+	require.NoError(t, err)
+
+	require.NoError(t, users.Update(ctx, &models.UserPayload{Bio: &models.UserBio{Id: 1, Username: "ryanpujo"}}))
+	user, err := users.FindByUsername(ctx, "ryanpujo")
+	require.NoError(t, err, "the missing entry of the new name is dropped")
+	require.Equal(t, int64(1), user.Id)
+	_, err = users.FindByUsername(ctx, "ryan")
+	require.ErrorIs(t, err, repo.ErrNoUserFound, "the old name is dropped")
+
+	_, err = users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+	require.NoError(t, err)
+	_, err = users.FindByUsername(ctx, "ryan")
+	require.NoError(t, err)
+
+	require.NoError(t, users.DeleteByUsername(ctx, "ryan"))
+	_, err = users.FindByUsername(ctx, "ryan")
+	require.ErrorIs(t, err, repo.ErrNoUserFound)
+}
+
+func TestCoalescing(t *testing.T) {
+	next, users, metrics := newRepo()
+	next.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := users.FindByUsername(context.Background(), "ryan")
+			require.NoError(t, err)
+			require.Equal(t, "ryan", user.Username)
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.Misses) == 10
+	}, time.Second, time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&next.lookups))
+	require.Equal(t, float64(9), testutil.ToFloat64(metrics.Coalesced))
+}
+
+func TestCoalescedCallerOutlivesLeader(t *testing.T) {
+	next, users, _ := newRepo()
+	next.release = make(chan struct{})
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		_, err := users.FindByUsername(leaderCtx, "ryan")
+		leaderDone <- err
+	}()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&next.lookups) == 1
+	}, time.Second, time.Millisecond)
+
+	followerDone := make(chan error)
+	go func() {
+		_, err := users.FindByUsername(context.Background(), "ryan")
+		followerDone <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-leaderDone, context.Canceled)
+	close(next.release)
+	require.NoError(t, <-followerDone)
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.UserRepository {
+		metrics := cache.NewMetrics(prometheus.NewRegistry(), "test")
+		lru := cache.NewLRU(10, time.Minute, time.Minute, time.Now, metrics)
+		return cache.NewUserRepository(repo.NewMemoryUserRepository(), lru, metrics)
+	})
+}
+
+func TestWithinTx(t *testing.T) {
+	ctx := context.Background()
+	db, err := repo.OpenSQLite(ctx, filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	metrics := cache.NewMetrics(prometheus.NewRegistry(), "test")
+	users := cache.NewUserRepository(repo.NewSQLiteUserRepository(db), cache.NewLRU(10, time.Minute, time.Minute, time.Now, metrics), metrics)
+
+	failed := errors.New("got an error")
+	err = repo.NewSQLiteTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}}); err != nil {
+			return err
+		}
+		if _, err := users.FindByUsername(ctx, "ryan"); err != nil {
+			return err
+		}
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	_, err = users.FindByUsername(ctx, "ryan")
+	require.ErrorIs(t, err, repo.ErrNoUserFound, "what a transaction rolled back is not cached")
+}
+
+func TestMetricsRegistered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := cache.NewMetrics(reg, "user_service")
+	metrics.Hits.Inc()
+
+	count, err := testutil.GatherAndCount(reg, "user_service_cache_hits_total", "user_service_cache_misses_total")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Panics(t, func() { cache.NewMetrics(reg, "user_service") }, "a registry takes them once")
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type auditServer struct {
+	models.UnimplementedAuditServiceServer
+	interactor interactor.AuditInteractor
+}
+
+func NewAuditServer(i interactor.AuditInteractor) *auditServer {
+	return &auditServer{interactor: i}
+}
+
+func (as *auditServer) ListAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error) {
+	if filter.GetSince() != nil && filter.GetUntil() != nil && !filter.GetSince().AsTime().Before(filter.GetUntil().AsTime()) {
+		return nil, status.Error(codes.InvalidArgument, "since must be before until")
+	}
+	events, err := as.interactor.FindAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return events, nil
+}
@@ -0,0 +1,85 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type auditInteractorMock struct {
+	mock.Mock
+}
+
+func (in *auditInteractorMock) FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error) {
+	args := in.Called(filter.GetActor())
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditEvents), args.Error(1)
+}
+
+var mockAuditInteractor *auditInteractorMock
+var auditClient models.AuditServiceClient
+
+func TestListAuditEvents(t *testing.T) {
+	events := &models.AuditEvents{Events: []*models.AuditEvent{{Actor: "admin"}}}
+	now := time.Now()
+	testTable := map[string]struct {
+		filter  *models.AuditFilter
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.AuditEvents, err error)
+	}{
+		"succes call": {
+			filter: &models.AuditFilter{Actor: "admin"},
+			arrange: func(t *testing.T) {
+				mockAuditInteractor.On("FindAuditEvents", "admin").Return(events, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.NoError(t, err)
+				require.Equal(t, len(events.Events), len(actual.Events))
+			},
+		},
+		"invalid range": {
+			filter: &models.AuditFilter{
+				Since: timestamppb.New(now),
+				Until: timestamppb.New(now.Add(-time.Hour)),
+			},
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		"fail call": {
+			filter: &models.AuditFilter{Actor: "admin"},
+			arrange: func(t *testing.T) {
+				mockAuditInteractor.On("FindAuditEvents", "admin").Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.Error(t, err)
+				require.Nil(t, actual)
+				require.Equal(t, codes.Internal, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := auditClient.ListAuditEvents(ctx, v.filter)
+
+			v.assert(t, result, err)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// DeadlineInterceptor skips calls whose deadline passed before they were
+// handled, and reports DeadlineExceeded or Canceled when the caller gave up
+// midway instead of whatever code the handler made of the aborted query.
+func DeadlineInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	res, err := handler(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return res, err
+}
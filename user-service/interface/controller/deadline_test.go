@@ -0,0 +1,90 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/interface/controller"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeadlineInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/FindUsers"}
+	testTable := map[string]struct {
+		ctx     func() (context.Context, context.CancelFunc)
+		handler func(ctx context.Context, cancel context.CancelFunc) (interface{}, error)
+		called  bool
+		expect  codes.Code
+	}{
+		"within deadline": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			handler: func(ctx context.Context, cancel context.CancelFunc) (interface{}, error) {
+				return "ok", nil
+			},
+			called: true,
+			expect: codes.OK,
+		},
+		"handler error is kept": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			handler: func(ctx context.Context, cancel context.CancelFunc) (interface{}, error) {
+				return nil, status.Error(codes.NotFound, "user does not exist")
+			},
+			called: true,
+			expect: codes.NotFound,
+		},
+		"expired before handling": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), -time.Second)
+			},
+			handler: func(ctx context.Context, cancel context.CancelFunc) (interface{}, error) {
+				return "ok", nil
+			},
+			expect: codes.DeadlineExceeded,
+		},
+		"expired while handling": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			handler: func(ctx context.Context, cancel context.CancelFunc) (interface{}, error) {
+				<-ctx.Done()
+				return nil, status.Error(codes.FailedPrecondition, "failed to query users")
+			},
+			called: true,
+			expect: codes.DeadlineExceeded,
+		},
+		"canceled by the caller": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			handler: func(ctx context.Context, cancel context.CancelFunc) (interface{}, error) {
+				cancel()
+				return nil, errors.New("canceled")
+			},
+			called: true,
+			expect: codes.Canceled,
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			called := false
+			_, err := controller.DeadlineInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return tc.handler(ctx, cancel)
+			})
+			require.Equal(t, tc.called, called)
+			require.Equal(t, tc.expect, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/spriigan/RPApp/interface/repository"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ReadPrimaryUntilKey is the header a call that wrote users is answered with,
+// the time in unix milliseconds until which replicas may lag behind the write.
+// Callers send it back on their next calls so these read from the primary,
+// whichever process of user-service handles them.
+const ReadPrimaryUntilKey = "x-read-primary-until"
+
+// ReadYourWrites carries the reads of a caller that just wrote to the primary
+// across processes.
+type ReadYourWrites struct {
+	// Stickiness is how far replicas may lag behind, callers cannot ask for
+	// reads from the primary further ahead than that.
+	Stickiness time.Duration
+	Now        func() time.Time
+}
+
+func (r ReadYourWrites) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	now := r.Now()
+	if until, ok := readPrimaryUntil(ctx); ok && now.Before(until) && until.Sub(now) <= r.Stickiness {
+		ctx = repository.ReadPrimary(ctx)
+	}
+	ctx, wrote := repository.TrackWrites(ctx)
+	res, err := handler(ctx, req)
+	if err == nil && wrote() {
+		until := r.Now().Add(r.Stickiness).UnixMilli()
+		_ = grpc.SetHeader(ctx, metadata.Pairs(ReadPrimaryUntilKey, strconv.FormatInt(until, 10)))
+	}
+	return res, err
+}
+
+func readPrimaryUntil(ctx context.Context) (time.Time, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ReadPrimaryUntilKey)
+	if len(values) == 0 {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
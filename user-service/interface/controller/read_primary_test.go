@@ -0,0 +1,94 @@
+package controller_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/interface/controller"
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// transportStream keeps the header a handler sets.
+type transportStream struct {
+	header metadata.MD
+}
+
+func (s *transportStream) Method() string { return "/user.UserService/Update" }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *transportStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestReadYourWrites(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stickiness := 5 * time.Second
+	millis := func(at time.Time) string { return strconv.FormatInt(at.UnixMilli(), 10) }
+	testTable := map[string]struct {
+		until   string
+		write   bool
+		err     error
+		primary bool
+		header  []string
+	}{
+		"no write before": {},
+		"write moments ago": {
+			until:   millis(now.Add(4 * time.Second)),
+			primary: true,
+		},
+		"replicas caught up": {
+			until: millis(now.Add(-time.Millisecond)),
+		},
+		"further ahead than replicas lag": {
+			until: millis(now.Add(time.Hour)),
+		},
+		"not a time": {
+			until: "soon",
+		},
+		"write": {
+			write:  true,
+			header: []string{millis(now.Add(stickiness))},
+		},
+		"failed write": {
+			write: true,
+			err:   errors.New("username is already taken"),
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			primary, replica := new(sql.DB), new(sql.DB)
+			replicas := repos.NewReplicas(primary, []*sql.DB{replica}, stickiness, func() time.Time { return now.Add(-time.Hour) })
+			interceptor := controller.ReadYourWrites{Stickiness: stickiness, Now: func() time.Time { return now }}
+
+			ctx := context.Background()
+			if tc.until != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(controller.ReadPrimaryUntilKey, tc.until))
+			}
+			stream := &transportStream{}
+			ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+			var reader *sql.DB
+			_, err := interceptor.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: stream.Method()}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				reader = replicas.Reader(ctx, "shoto")
+				if tc.write {
+					replicas.Wrote(ctx, "shoto")
+				}
+				return nil, tc.err
+			})
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.primary, reader == primary)
+			require.Equal(t, tc.header, stream.header.Get(controller.ReadPrimaryUntilKey))
+		})
+	}
+}
@@ -72,3 +72,14 @@ func (us *userServer) Update(ctx context.Context, payload *models.UserPayload) (
 	}
 	return &emptypb.Empty{}, nil
 }
+
+func (us *userServer) SuggestUsernames(ctx context.Context, req *models.SuggestRequest) (*models.Users, error) {
+	users, err := us.interactor.Suggest(ctx, req.GetPrefix(), int(req.GetLimit()))
+	if err != nil {
+		if errors.Is(err, interactor.ErrEmptyPrefix) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return users, nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/spriigan/RPApp/interface/controller"
 	"github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/interactor"
 	"github.com/spriigan/RPApp/user-proto/grpc/models"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -60,6 +61,14 @@ func (in *interactorMock) Update(ctx context.Context, user *models.UserPayload)
 	return args.Error(0)
 }
 
+func (in *interactorMock) Suggest(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	args := in.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Users), args.Error(1)
+}
+
 var mockInteractor *interactorMock
 var client models.UserServiceClient
 var lis *bufconn.Listener
@@ -75,12 +84,21 @@ func TestMain(m *testing.M) {
 	defer s.Stop()
 	mockInteractor = new(interactorMock)
 	models.RegisterUserServiceServer(s, controller.NewUserServer(mockInteractor))
+	mockAuditInteractor = new(auditInteractorMock)
+	models.RegisterAuditServiceServer(s, controller.NewAuditServer(mockAuditInteractor))
+	mockWebhookInteractor = new(webhookInteractorMock)
+	models.RegisterWebhookServiceServer(s, controller.NewWebhookServer(mockWebhookInteractor))
+	mockWatchInteractor = new(watchInteractorMock)
+	models.RegisterUserWatchServiceServer(s, controller.NewWatchServer(mockWatchInteractor))
 	conn, err := grpc.DialContext(context.Background(), "buffnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer conn.Close()
 	client = models.NewUserServiceClient(conn)
+	auditClient = models.NewAuditServiceClient(conn)
+	webhookClient = models.NewWebhookServiceClient(conn)
+	watchClient = models.NewUserWatchServiceClient(conn)
 	go func() {
 		if err = s.Serve(lis); err != nil {
 			log.Fatal(err)
@@ -293,3 +311,52 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestSuggestUsernames(t *testing.T) {
+	users := &models.Users{User: []*models.UserBio{{Username: "ryan"}}}
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Users, err error)
+	}{
+		"succes call": {
+			arrange: func(t *testing.T) {
+				mockInteractor.On("Suggest", "ry", 5).Return(users, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.NoError(t, err)
+				require.Equal(t, len(users.User), len(actual.User))
+			},
+		},
+		"empty prefix": {
+			arrange: func(t *testing.T) {
+				mockInteractor.On("Suggest", "ry", 5).Return(nil, interactor.ErrEmptyPrefix).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.Error(t, err)
+				require.Nil(t, actual)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		"fail call": {
+			arrange: func(t *testing.T) {
+				mockInteractor.On("Suggest", "ry", 5).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.Internal, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := client.SuggestUsernames(ctx, &models.SuggestRequest{Prefix: "ry", Limit: 5})
+
+			v.assert(t, result, err)
+		})
+	}
+}
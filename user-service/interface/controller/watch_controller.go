@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type watchServer struct {
+	models.UnimplementedUserWatchServiceServer
+	interactor interactor.WatchInteractor
+}
+
+func NewWatchServer(i interactor.WatchInteractor) *watchServer {
+	return &watchServer{interactor: i}
+}
+
+func (ws *watchServer) WatchUsers(req *models.WatchRequest, stream models.UserWatchService_WatchUsersServer) error {
+	err := ws.interactor.Watch(stream.Context(), req.GetAfterId(), stream.Send)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, interactor.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, watch.ErrSlowSubscriber):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,106 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type watchInteractorMock struct {
+	mock.Mock
+}
+
+func (in *watchInteractorMock) Watch(ctx context.Context, afterID int64, send func(*models.UserChange) error) error {
+	args := in.Called(afterID)
+	if changes, ok := args.Get(0).([]*models.UserChange); ok {
+		for _, change := range changes {
+			if err := send(change); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+var mockWatchInteractor *watchInteractorMock
+var watchClient models.UserWatchServiceClient
+
+func TestWatchUsers(t *testing.T) {
+	changes := []*models.UserChange{
+		{Id: 4, Type: "user.created", User: &models.UserBio{Username: "ryanpujo"}},
+		{Id: 5, Type: "user.deleted", User: &models.UserBio{Username: "ryanpujo"}},
+	}
+	testTable := map[string]struct {
+		afterID int64
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, received []*models.UserChange, err error)
+	}{
+		"succes call": {
+			afterID: 3,
+			arrange: func(t *testing.T) {
+				mockWatchInteractor.On("Watch", int64(3)).Return(changes, nil).Once()
+			},
+			assert: func(t *testing.T, received []*models.UserChange, err error) {
+				require.ErrorIs(t, err, io.EOF)
+				require.Len(t, received, 2)
+				require.Equal(t, "user.deleted", received[1].Type)
+			},
+		},
+		"invalid cursor": {
+			afterID: -1,
+			arrange: func(t *testing.T) {
+				mockWatchInteractor.On("Watch", int64(-1)).Return(nil, interactor.ErrInvalidCursor).Once()
+			},
+			assert: func(t *testing.T, received []*models.UserChange, err error) {
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		"slow subscriber": {
+			arrange: func(t *testing.T) {
+				mockWatchInteractor.On("Watch", int64(0)).Return(changes[:1], watch.ErrSlowSubscriber).Once()
+			},
+			assert: func(t *testing.T, received []*models.UserChange, err error) {
+				require.Len(t, received, 1)
+				require.Equal(t, codes.ResourceExhausted, status.Code(err))
+			},
+		},
+		"fail call": {
+			arrange: func(t *testing.T) {
+				mockWatchInteractor.On("Watch", int64(0)).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, received []*models.UserChange, err error) {
+				require.Equal(t, codes.Internal, status.Code(err))
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			stream, err := watchClient.WatchUsers(ctx, &models.WatchRequest{AfterId: v.afterID})
+			require.NoError(t, err)
+			received := make([]*models.UserChange, 0)
+			for {
+				change, err := stream.Recv()
+				if err != nil {
+					v.assert(t, received, err)
+					return
+				}
+				received = append(received, change)
+			}
+		})
+	}
+}
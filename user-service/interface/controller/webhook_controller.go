@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type webhookServer struct {
+	models.UnimplementedWebhookServiceServer
+	interactor interactor.WebhookInteractor
+}
+
+func NewWebhookServer(i interactor.WebhookInteractor) *webhookServer {
+	return &webhookServer{interactor: i}
+}
+
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNoWebhookFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, interactor.ErrInvalidWebhookURL),
+		errors.Is(err, interactor.ErrUnknownEventType),
+		errors.Is(err, interactor.ErrInvalidDeliveryStatus):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (ws *webhookServer) CreateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	hook, err := ws.interactor.CreateWebhook(ctx, payload)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hook, nil
+}
+
+func (ws *webhookServer) FindWebhooks(ctx context.Context, empty *emptypb.Empty) (*models.Webhooks, error) {
+	hooks, err := ws.interactor.FindWebhooks(ctx)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hooks, nil
+}
+
+func (ws *webhookServer) FindWebhook(ctx context.Context, id *models.WebhookId) (*models.Webhook, error) {
+	hook, err := ws.interactor.FindWebhook(ctx, id.GetId())
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hook, nil
+}
+
+func (ws *webhookServer) UpdateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	hook, err := ws.interactor.UpdateWebhook(ctx, payload)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hook, nil
+}
+
+func (ws *webhookServer) DeleteWebhook(ctx context.Context, id *models.WebhookId) (*emptypb.Empty, error) {
+	err := ws.interactor.DeleteWebhook(ctx, id.GetId())
+	if err != nil {
+		return &emptypb.Empty{}, webhookError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ws *webhookServer) FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error) {
+	deliveries, err := ws.interactor.FindDeliveries(ctx, filter)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,292 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type webhookInteractorMock struct {
+	mock.Mock
+}
+
+func (in *webhookInteractorMock) CreateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	args := in.Called(payload.GetUrl())
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *webhookInteractorMock) FindWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	args := in.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhooks), args.Error(1)
+}
+
+func (in *webhookInteractorMock) FindWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	args := in.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *webhookInteractorMock) UpdateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	args := in.Called(payload.GetId())
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *webhookInteractorMock) DeleteWebhook(ctx context.Context, id int64) error {
+	args := in.Called(id)
+	return args.Error(0)
+}
+
+func (in *webhookInteractorMock) FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error) {
+	args := in.Called(filter.GetWebhookId())
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Deliveries), args.Error(1)
+}
+
+var mockWebhookInteractor *webhookInteractorMock
+var webhookClient models.WebhookServiceClient
+
+func TestCreateWebhook(t *testing.T) {
+	hook := &models.Webhook{Id: 1, Url: "https://example.com/hook", Secret: "whsec_test"}
+	testTable := map[string]struct {
+		url     string
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Webhook, err error)
+	}{
+		"succes call": {
+			url: hook.Url,
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("CreateWebhook", hook.Url).Return(hook, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.NoError(t, err)
+				require.Equal(t, hook.Secret, actual.Secret)
+			},
+		},
+		"invalid url": {
+			url: "ftp://example.com",
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("CreateWebhook", "ftp://example.com").Return(nil, interactor.ErrInvalidWebhookURL).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		"fail call": {
+			url: hook.Url,
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("CreateWebhook", hook.Url).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.Internal, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookClient.CreateWebhook(ctx, &models.WebhookPayload{Url: v.url})
+
+			v.assert(t, result, err)
+		})
+	}
+}
+
+func TestFindWebhook(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Webhook, err error)
+	}{
+		"succes call": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("FindWebhook", int64(1)).Return(&models.Webhook{Id: 1}, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.NoError(t, err)
+				require.Equal(t, int64(1), actual.Id)
+			},
+		},
+		"not found": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("FindWebhook", int64(1)).Return(nil, repository.ErrNoWebhookFound).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.NotFound, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookClient.FindWebhook(ctx, &models.WebhookId{Id: 1})
+
+			v.assert(t, result, err)
+		})
+	}
+}
+
+func TestFindWebhooks(t *testing.T) {
+	mockWebhookInteractor.On("FindWebhooks").Return(&models.Webhooks{Webhooks: []*models.Webhook{{Id: 1}, {Id: 2}}}, nil).Once()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := webhookClient.FindWebhooks(ctx, &emptypb.Empty{})
+
+	require.NoError(t, err)
+	require.Len(t, result.Webhooks, 2)
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Webhook, err error)
+	}{
+		"succes call": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("UpdateWebhook", int64(1)).Return(&models.Webhook{Id: 1, Active: true}, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.NoError(t, err)
+				require.True(t, actual.Active)
+			},
+		},
+		"unknown event type": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("UpdateWebhook", int64(1)).Return(nil, interactor.ErrUnknownEventType).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		"not found": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("UpdateWebhook", int64(1)).Return(nil, repository.ErrNoWebhookFound).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.NotFound, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookClient.UpdateWebhook(ctx, &models.WebhookPayload{Id: 1})
+
+			v.assert(t, result, err)
+		})
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, err error)
+	}{
+		"succes call": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("DeleteWebhook", int64(1)).Return(nil).Once()
+			},
+			assert: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		"not found": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("DeleteWebhook", int64(1)).Return(repository.ErrNoWebhookFound).Once()
+			},
+			assert: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.NotFound, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			_, err := webhookClient.DeleteWebhook(ctx, &models.WebhookId{Id: 1})
+
+			v.assert(t, err)
+		})
+	}
+}
+
+func TestFindDeliveries(t *testing.T) {
+	testTable := map[string]struct {
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Deliveries, err error)
+	}{
+		"succes call": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("FindDeliveries", int64(1)).Return(&models.Deliveries{Deliveries: []*models.Delivery{{Id: 3}}}, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Deliveries, err error) {
+				require.NoError(t, err)
+				require.Len(t, actual.Deliveries, 1)
+			},
+		},
+		"invalid status": {
+			arrange: func(t *testing.T) {
+				mockWebhookInteractor.On("FindDeliveries", int64(1)).Return(nil, interactor.ErrInvalidDeliveryStatus).Once()
+			},
+			assert: func(t *testing.T, actual *models.Deliveries, err error) {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookClient.FindDeliveries(ctx, &models.DeliveryFilter{WebhookId: 1})
+
+			v.assert(t, result, err)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *auditRepository {
+	return &auditRepository{db: db}
+}
+
+type fieldChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+const insertAuditStatement = `insert into audit_events (actor, action, target_id, target_username, request_id, diff)
+			values ($1, $2, $3, $4, $5, $6)`
+
+// auditEventArgs are the values insertAuditStatement takes for event.
+func auditEventArgs(event *models.AuditEvent) ([]interface{}, error) {
+	diff := make(map[string]fieldChange, len(event.Diff))
+	for field, change := range event.Diff {
+		diff[field] = fieldChange{Before: change.GetBefore(), After: change.GetAfter()}
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		event.Actor,
+		event.Action,
+		event.TargetId,
+		event.TargetUsername,
+		event.RequestId,
+		diffJSON,
+	}, nil
+}
+
+// insertAuditEvent must run in the same transaction as the change it records.
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error {
+	args, err := auditEventArgs(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, insertAuditStatement, args...)
+	return err
+}
+
+func (repo *auditRepository) FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error) {
+	conditions := make([]string, 0, 6)
+	args := make([]interface{}, 0, 7)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.GetActor() != "" {
+		where("actor=$%d", filter.GetActor())
+	}
+	if filter.GetAction() != "" {
+		where("action=$%d", filter.GetAction())
+	}
+	if filter.GetTargetUsername() != "" {
+		where("target_username=$%d", filter.GetTargetUsername())
+	}
+	if filter.GetSince() != nil {
+		where("created_at>=$%d", filter.GetSince().AsTime())
+	}
+	if filter.GetUntil() != nil {
+		where("created_at<$%d", filter.GetUntil().AsTime())
+	}
+	if filter.GetBeforeId() > 0 {
+		where("id<$%d", filter.GetBeforeId())
+	}
+
+	statement := `select id, actor, action, target_id, target_username, request_id, diff, created_at from audit_events`
+	if len(conditions) > 0 {
+		statement += " where " + strings.Join(conditions, " and ")
+	}
+	args = append(args, filter.GetLimit())
+	statement += fmt.Sprintf(" order by id desc limit $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := models.AuditEvents{
+		Events: make([]*models.AuditEvent, 0, filter.GetLimit()),
+	}
+
+	for rows.Next() {
+		var event models.AuditEvent
+		var diffJSON []byte
+		var createdAt time.Time
+		err = rows.Scan(
+			&event.Id,
+			&event.Actor,
+			&event.Action,
+			&event.TargetId,
+			&event.TargetUsername,
+			&event.RequestId,
+			&diffJSON,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		var diff map[string]fieldChange
+		if err = json.Unmarshal(diffJSON, &diff); err != nil {
+			return nil, err
+		}
+		event.Diff = make(map[string]*models.FieldChange, len(diff))
+		for field, change := range diff {
+			event.Diff[field] = &models.FieldChange{Before: change.Before, After: change.After}
+		}
+		event.CreatedAt = timestamppb.New(createdAt)
+		events.Events = append(events.Events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
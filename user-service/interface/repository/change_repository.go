@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const changeChannel = "user_changes"
+
+type changeRepository struct {
+	db *sql.DB
+}
+
+func NewChangeRepository(db *sql.DB) *changeRepository {
+	return &changeRepository{db: db}
+}
+
+func (repo *changeRepository) FindChanges(ctx context.Context, afterID int64, limit int) ([]*models.UserChange, error) {
+	statement := `select id, change_type, user_id, first_name, last_name, username, email, created_at
+			from user_changes where id > $1 order by id limit $2`
+
+	rows, err := repo.db.QueryContext(ctx, statement, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := make([]*models.UserChange, 0, limit)
+
+	for rows.Next() {
+		change := models.UserChange{User: &models.UserBio{}}
+		var fname, lname, email sql.NullString
+		var createdAt time.Time
+		err = rows.Scan(
+			&change.Id,
+			&change.Type,
+			&change.User.Id,
+			&fname,
+			&lname,
+			&change.User.Username,
+			&email,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		change.User.Fname = fname.String
+		change.User.Lname = lname.String
+		change.User.Email = email.String
+		change.OccurredAt = timestamppb.New(createdAt)
+		changes = append(changes, &change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (repo *changeRepository) LatestChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	err := repo.db.QueryRowContext(ctx, "select coalesce(max(id), 0) from user_changes").Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListenChanges holds a connection of the pool for as long as it listens. The
+// connection is closed when it returns so it never goes back to the pool
+// still subscribed.
+func (repo *changeRepository) ListenChanges(ctx context.Context, notify func()) error {
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		defer pgConn.Close(context.Background())
+
+		_, err := pgConn.Exec(ctx, "listen "+changeChannel)
+		if err != nil {
+			return err
+		}
+		for {
+			_, err = pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			notify()
+		}
+	})
+}
+
+func (repo *changeRepository) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, "delete from user_changes where created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChangesInCommitOrder has two transactions interleave and commit in the
+// reverse order they wrote in. A reader following the ids must not miss the
+// change of the one committed last.
+func TestChangesInCommitOrder(t *testing.T) {
+	requirePostgres(t)
+	truncateUsers(t)
+	t.Cleanup(func() { truncateUsers(t) })
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	changes := repos.NewChangeRepository(testDb)
+	insert := `insert into users (first_name, last_name, username, password, email) values ('ryan', 'pujo', $1, 'hash', $2)`
+
+	first, err := testDb.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer first.Rollback()
+	_, err = first.ExecContext(ctx, insert, "first", "first@gmail.com")
+	require.NoError(t, err)
+
+	second, err := testDb.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer second.Rollback()
+	_, err = second.ExecContext(ctx, insert, "second", "second@gmail.com")
+	require.NoError(t, err)
+
+	last, err := changes.LatestChangeID(ctx)
+	require.NoError(t, err)
+	require.NoError(t, second.Commit())
+	seen, err := changes.FindChanges(ctx, last, 10)
+	require.NoError(t, err)
+	require.Len(t, seen, 1)
+	require.Equal(t, "second", seen[0].User.Username)
+
+	require.NoError(t, first.Commit())
+	seen, err = changes.FindChanges(ctx, seen[0].Id, 10)
+	require.NoError(t, err)
+	require.Len(t, seen, 1, "the change committed last comes after the cursor")
+	require.Equal(t, "first", seen[0].User.Username)
+}
@@ -0,0 +1,91 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/interface/repository/repositorytest"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newSQLiteUserRepository(t *testing.T) repository.UserRepository {
+	db, err := repos.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return repos.NewSQLiteUserRepository(db)
+}
+
+// truncateUsers empties the users table and restarts its ids, for the tests
+// after the suite to find the table as TestMain left it.
+func truncateUsers(t testing.TB) {
+	_, err := testDb.Exec("TRUNCATE users RESTART IDENTITY")
+	require.NoError(t, err)
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	testTable := map[string]repositorytest.Factory{
+		"memory": func(t *testing.T) repository.UserRepository {
+			return repos.NewMemoryUserRepository()
+		},
+		"sqlite": newSQLiteUserRepository,
+		"postgres": func(t *testing.T) repository.UserRepository {
+			requirePostgres(t)
+			truncateUsers(t)
+			t.Cleanup(func() { truncateUsers(t) })
+			return repos.NewUserRepository(testDb)
+		},
+		"pgx": func(t *testing.T) repository.UserRepository {
+			requirePostgres(t)
+			truncateUsers(t)
+			t.Cleanup(func() { truncateUsers(t) })
+			return repos.NewPgxUserRepository(testPgxPool)
+		},
+		// The primary standing in for its replica, for the routing to be
+		// exercised without lag.
+		"postgres with replicas": func(t *testing.T) repository.UserRepository {
+			requirePostgres(t)
+			truncateUsers(t)
+			t.Cleanup(func() { truncateUsers(t) })
+			return repos.NewReplicatedUserRepository(repos.NewReplicas(testDb, []*sql.DB{testDb}, time.Second, time.Now))
+		},
+	}
+
+	for name, newRepo := range testTable {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.Run(t, newRepo)
+		})
+	}
+}
+
+// spans records what the repositories trace. The repository tracer delegates to
+// the first provider installed, so it is installed once for every run.
+var spans = struct {
+	once     sync.Once
+	recorder *tracetest.SpanRecorder
+}{recorder: tracetest.NewSpanRecorder()}
+
+// TestTracedUserRepository runs the suite with spans recorded, tracing must not
+// change what the repository answers.
+func TestTracedUserRepository(t *testing.T) {
+	spans.once.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans.recorder)))
+	})
+	before := len(spans.recorder.Ended())
+
+	repositorytest.Run(t, newSQLiteUserRepository)
+
+	ended := spans.recorder.Ended()[before:]
+	require.NotEmpty(t, ended)
+	for _, span := range ended {
+		require.Contains(t, span.Name(), "sqliteUserRepository.")
+	}
+}
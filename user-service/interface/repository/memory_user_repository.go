@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+// memoryUserRepository keeps users in the process, for running user-service
+// without a database. It answers like the postgres repository down to the
+// errors and the order of the results, but records no audit trail or events.
+type memoryUserRepository struct {
+	mu         sync.RWMutex
+	lastID     int64
+	users      map[int64]*models.User
+	byUsername map[string]int64
+}
+
+func NewMemoryUserRepository() *memoryUserRepository {
+	return &memoryUserRepository{
+		users:      make(map[int64]*models.User),
+		byUsername: make(map[string]int64),
+	}
+}
+
+func (repo *memoryUserRepository) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	created := userOf(user)
+	if _, ok := repo.byUsername[created.Username]; ok {
+		return 0, ErrUsernameTaken
+	}
+	repo.lastID++
+	created.Id = repo.lastID
+	repo.users[created.Id] = created
+	repo.byUsername[created.Username] = created.Id
+	return int(created.Id), nil
+}
+
+func bioOf(user *models.User) *models.UserBio {
+	return &models.UserBio{
+		Id:       user.Id,
+		Fname:    user.Fname,
+		Lname:    user.Lname,
+		Username: user.Username,
+		Email:    user.Email,
+	}
+}
+
+// FindUsers orders by first name, compared bytewise like postgres does with
+// the C collation, then by id.
+func (repo *memoryUserRepository) FindUsers(ctx context.Context) (*models.Users, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	users := models.Users{User: make([]*models.UserBio, 0, len(repo.users))}
+	for _, user := range repo.users {
+		users.User = append(users.User, bioOf(user))
+	}
+	sort.Slice(users.User, func(i, j int) bool {
+		a, b := users.User[i], users.User[j]
+		if a.Fname != b.Fname {
+			return a.Fname < b.Fname
+		}
+		return a.Id < b.Id
+	})
+	return &users, nil
+}
+
+func (repo *memoryUserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	id, ok := repo.byUsername[username]
+	if !ok {
+		return nil, ErrNoUserFound
+	}
+	user := repo.users[id]
+	return &models.User{
+		Id:       user.Id,
+		Fname:    user.Fname,
+		Lname:    user.Lname,
+		Username: user.Username,
+		Password: user.Password,
+		Email:    user.Email,
+	}, nil
+}
+
+func (repo *memoryUserRepository) DeleteByUsername(ctx context.Context, username string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if id, ok := repo.byUsername[username]; ok {
+		delete(repo.users, id)
+		delete(repo.byUsername, username)
+	}
+	return nil
+}
+
+func (repo *memoryUserRepository) Update(ctx context.Context, user *models.UserPayload) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	updated := userOf(user)
+	before, ok := repo.users[updated.Id]
+	if !ok {
+		return nil
+	}
+	if id, ok := repo.byUsername[updated.Username]; ok && id != updated.Id {
+		return ErrUsernameTaken
+	}
+	delete(repo.byUsername, before.Username)
+	repo.users[updated.Id] = updated
+	repo.byUsername[updated.Username] = updated.Id
+	return nil
+}
+
+// FindByUsernamePrefix matches and ranks usernames the way the postgres query
+// does: case-insensitively, shortest first, then bytewise.
+func (repo *memoryUserRepository) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	prefix = strings.ToLower(prefix)
+	users := models.Users{User: make([]*models.UserBio, 0)}
+	for _, user := range repo.users {
+		if strings.HasPrefix(strings.ToLower(user.Username), prefix) {
+			users.User = append(users.User, bioOf(user))
+		}
+	}
+	sort.Slice(users.User, func(i, j int) bool {
+		a, b := users.User[i].Username, users.User[j].Username
+		if la, lb := utf8.RuneCountInString(a), utf8.RuneCountInString(b); la != lb {
+			return la < lb
+		}
+		if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+			return la < lb
+		}
+		return a < b
+	})
+	if limit >= 0 && len(users.User) > limit {
+		users.User = users.User[:limit]
+	}
+	return &users, nil
+}
@@ -0,0 +1,34 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spriigan/RPApp/migrate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	requirePostgres(t)
+	migrations, err := migrate.Embedded()
+	require.NoError(t, err)
+	migrator := migrate.New(testDb, migrations)
+
+	steps, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, steps, "TestMain applied them already")
+
+	states, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, states, len(migrations))
+	for _, s := range states {
+		require.True(t, s.Applied, "migration %d", s.Version)
+		require.False(t, s.Unknown)
+	}
+
+	// The schema predating the migrations is adopted as is.
+	for _, m := range migrations {
+		_, err = testDb.Exec(m.Up)
+		require.NoError(t, err, "migration %d is not idempotent", m.Version)
+	}
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/spriigan/RPApp/outbox"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type outboxRepository struct {
+	db          *sql.DB
+	maxAttempts int
+}
+
+// NewOutboxRepository dead-letters an event once publishing it failed
+// maxAttempts times.
+func NewOutboxRepository(db *sql.DB, maxAttempts int) *outboxRepository {
+	return &outboxRepository{db: db, maxAttempts: maxAttempts}
+}
+
+const insertOutboxStatement = "insert into outbox_events (event_id, event_type, payload) values ($1, $2, $3)"
+
+// outboxEventArgs are the values insertOutboxStatement takes for envelope.
+func outboxEventArgs(envelope *eventsv1.Envelope) ([]interface{}, error) {
+	payload, err := proto.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{envelope.Id, envelope.Type, payload}, nil
+}
+
+// insertOutboxEvent must run in the same transaction as the change it announces.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, envelope *eventsv1.Envelope) error {
+	args, err := outboxEventArgs(envelope)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, insertOutboxStatement, args...)
+	return err
+}
+
+// Dispatch holds a transaction-scoped advisory lock while it publishes, a
+// relay that can not take it dispatches nothing so events go out one relay
+// at a time and in order.
+func (repo *outboxRepository) Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, msg outbox.Message) error) (int, error) {
+	statement := `select id, event_id, event_type, payload from outbox_events
+			where published_at is null and dead_at is null
+			order by id
+			limit $1
+	`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	err = tx.QueryRowContext(ctx, "select pg_try_advisory_xact_lock(hashtext('public.outbox_events'))").Scan(&locked)
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, statement, limit)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]int64, 0, limit)
+	messages := make([]outbox.Message, 0, limit)
+	for rows.Next() {
+		var id int64
+		var msg outbox.Message
+		if err = rows.Scan(&id, &msg.ID, &msg.Type, &msg.Payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		messages = append(messages, msg)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for i, msg := range messages {
+		if publishErr = publish(ctx, msg); publishErr != nil {
+			var dead bool
+			err = tx.QueryRowContext(ctx, `update outbox_events set attempts=attempts+1, last_error=$1,
+				dead_at=case when attempts+1 >= $2 then now() end
+				where id=$3 returning dead_at is not null`, publishErr.Error(), repo.maxAttempts, ids[i]).Scan(&dead)
+			if err != nil {
+				return 0, err
+			}
+			if dead {
+				publishErr = fmt.Errorf("event %s is dead-lettered after %d attempts: %w", msg.ID, repo.maxAttempts, publishErr)
+			}
+			break
+		}
+		_, err = tx.ExecContext(ctx, "update outbox_events set published_at=now(), attempts=attempts+1, last_error=null where id=$1", ids[i])
+		if err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+// truncateOutbox leaves a test with only the events it inserts pending.
+func truncateOutbox(t testing.TB) {
+	_, err := testDb.Exec("TRUNCATE outbox_events RESTART IDENTITY")
+	require.NoError(t, err)
+}
+
+func TestDispatch(t *testing.T) {
+	requirePostgres(t)
+	truncateOutbox(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err := testDb.ExecContext(ctx, `insert into outbox_events (event_id, event_type, payload) values
+		('e1', 'user.created', 'a'), ('e2', 'user.updated', 'b'), ('e3', 'user.deleted', 'c')`)
+	require.NoError(t, err)
+	store := repos.NewOutboxRepository(testDb, 3)
+
+	calls := 0
+	published, err := store.Dispatch(ctx, 10, func(ctx context.Context, msg outbox.Message) error {
+		calls++
+		if msg.ID == "e2" {
+			return errors.New("got an error")
+		}
+		return nil
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, published)
+	require.Equal(t, 2, calls)
+
+	var attempts int
+	var lastError string
+	err = testDb.QueryRowContext(ctx, "select attempts, last_error from outbox_events where event_id='e2'").Scan(&attempts, &lastError)
+	require.NoError(t, err)
+	require.Equal(t, 1, attempts)
+	require.Equal(t, "got an error", lastError)
+
+	publisher := outbox.NewMemoryPublisher()
+	published, err = store.Dispatch(ctx, 10, publisher.Publish)
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+	require.Equal(t, "e2", publisher.Messages()[0].ID)
+	require.Equal(t, []byte("c"), publisher.Messages()[1].Payload)
+
+	published, err = store.Dispatch(ctx, 10, publisher.Publish)
+	require.NoError(t, err)
+	require.Zero(t, published)
+}
+
+func TestDispatchDeadLetter(t *testing.T) {
+	requirePostgres(t)
+	truncateOutbox(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err := testDb.ExecContext(ctx, `insert into outbox_events (event_id, event_type, payload) values
+		('d1', 'user.created', 'too big'), ('d2', 'user.updated', 'b')`)
+	require.NoError(t, err)
+	store := repos.NewOutboxRepository(testDb, 2)
+	rejectD1 := func(ctx context.Context, msg outbox.Message) error {
+		if msg.ID == "d1" {
+			return errors.New("message too large")
+		}
+		return nil
+	}
+
+	published, err := store.Dispatch(ctx, 10, rejectD1)
+	require.EqualError(t, err, "message too large")
+	require.Zero(t, published)
+	published, err = store.Dispatch(ctx, 10, rejectD1)
+	require.ErrorContains(t, err, "event d1 is dead-lettered after 2 attempts")
+	require.Zero(t, published)
+
+	publisher := outbox.NewMemoryPublisher()
+	published, err = store.Dispatch(ctx, 10, publisher.Publish)
+	require.NoError(t, err)
+	require.Equal(t, 1, published, "a dead-lettered event does not hold back the ones behind it")
+	require.Equal(t, "d2", publisher.Messages()[0].ID)
+}
+
+func TestDispatchOneRelayAtATime(t *testing.T) {
+	requirePostgres(t)
+	truncateOutbox(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err := testDb.ExecContext(ctx, `insert into outbox_events (event_id, event_type, payload) values ('l1', 'user.created', 'a')`)
+	require.NoError(t, err)
+	other, err := testDb.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer other.Rollback()
+	_, err = other.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('public.outbox_events'))")
+	require.NoError(t, err)
+
+	store := repos.NewOutboxRepository(testDb, 3)
+	publisher := outbox.NewMemoryPublisher()
+	published, err := store.Dispatch(ctx, 10, publisher.Publish)
+	require.NoError(t, err)
+	require.Zero(t, published)
+
+	require.NoError(t, other.Rollback())
+	published, err = store.Dispatch(ctx, 10, publisher.Publish)
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+const userColumns = "id, first_name, last_name, username, password, email"
+
+// pgxStatements are prepared on every connection of a pool opened with
+// OpenPgxPool and run by name.
+var pgxStatements = map[string]string{
+	"users_create":         "insert into users (first_name, last_name, username, password, email) values ($1, $2, $3, $4, $5) returning id",
+	"users_find":           `select id, first_name, last_name, username, email from users order by first_name collate "C", id`,
+	"users_find_username":  "select " + userColumns + " from users where username=$1",
+	"users_lock_username":  "select " + userColumns + " from users where username=$1 for update",
+	"users_lock_id":        "select " + userColumns + " from users where id=$1 for update",
+	"users_delete":         "delete from users where id=$1",
+	"users_update":         "update users set first_name=$1, last_name=$2, username=$3, password=$4, email=$5 where id=$6",
+	"users_prefix":         `select id, first_name, last_name, username, email from users where lower(username) like $1 escape '\' order by length(username), lower(username) collate "C", username collate "C" limit $2`,
+	"audit_events_insert":  insertAuditStatement,
+	"outbox_events_insert": insertOutboxStatement,
+}
+
+// OpenPgxPool opens a pool to postgres and prepares the statements of the pgx
+// user repository on every connection it makes.
+func OpenPgxPool(ctx context.Context, cfg *pgxpool.Config) (*pgxpool.Pool, error) {
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		for name, statement := range pgxStatements {
+			if _, err := conn.Prepare(ctx, name, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// pgxQueryer is what statements run on, a transaction or the pool itself.
+type pgxQueryer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// pgxConn returns the transaction of pool ctx carries, pool when there is none.
+func pgxConn(ctx context.Context, pool *pgxpool.Pool) pgxQueryer {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.pool == pool {
+		return state.pgxTx
+	}
+	return pool
+}
+
+type pgxTransactor struct {
+	pool *pgxpool.Pool
+	opts TxOptions
+}
+
+// NewPgxTransactor runs work in transactions of pool, which the pgx user
+// repository of the same pool takes part in.
+func NewPgxTransactor(pool *pgxpool.Pool, opts TxOptions) *pgxTransactor {
+	return &pgxTransactor{pool: pool, opts: opts}
+}
+
+var pgxIsolation = map[sql.IsolationLevel]pgx.TxIsoLevel{
+	sql.LevelReadCommitted:  pgx.ReadCommitted,
+	sql.LevelRepeatableRead: pgx.RepeatableRead,
+	sql.LevelSerializable:   pgx.Serializable,
+}
+
+// WithinTx is the WithinTx of the database/sql transactor on pool.
+func (t *pgxTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.pool == t.pool {
+		return fn(ctx)
+	}
+	return retryTx(ctx, t.opts.Retries, serializationFailure, func() (*txState, error) {
+		state := &txState{pool: t.pool}
+		return state, t.run(ctx, state, fn)
+	})
+}
+
+func (t *pgxTransactor) run(ctx context.Context, state *txState, fn func(ctx context.Context) error) (err error) {
+	state.pgxTx, err = t.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgxIsolation[t.opts.Isolation]})
+	if err != nil {
+		return err
+	}
+	defer state.pgxTx.Rollback(context.Background())
+
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+	return state.pgxTx.Commit(ctx)
+}
+
+// pgxUserRepository is the postgres user repository on a pgx pool, running
+// statements prepared once per connection and sending what goes together in
+// one round trip. It records the same audit trail and events.
+type pgxUserRepository struct {
+	pool *pgxpool.Pool
+	tx   *pgxTransactor
+}
+
+func NewPgxUserRepository(pool *pgxpool.Pool) *pgxUserRepository {
+	return &pgxUserRepository{pool: pool, tx: NewPgxTransactor(pool, DefaultTxOptions)}
+}
+
+func (repo *pgxUserRepository) withTx(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	return repo.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return fn(ctx, ctx.Value(txKey{}).(*txState).pgxTx)
+	})
+}
+
+// recordChanges queues the audit rows and the outbox events of mutations,
+// one per action and pair of users.
+func recordChanges(ctx context.Context, batch *pgx.Batch, action string, before, after []*models.User) error {
+	for i := range before {
+		event, envelope := changeRecords(ctx, action, before[i], after[i])
+		auditArgs, err := auditEventArgs(event)
+		if err != nil {
+			return err
+		}
+		outboxArgs, err := outboxEventArgs(envelope)
+		if err != nil {
+			return err
+		}
+		batch.Queue("audit_events_insert", auditArgs...)
+		batch.Queue("outbox_events_insert", outboxArgs...)
+	}
+	return nil
+}
+
+// sendChanges sends what recordChanges queued.
+func sendChanges(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) error {
+	results := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			return err
+		}
+	}
+	return results.Close()
+}
+
+func (repo *pgxUserRepository) Create(ctx context.Context, user *models.UserPayload) (_ int, err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.Create", pgxStatements["users_create"])
+	defer func() { endSpan(span, err) }()
+
+	var id int
+	err = repo.withTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "users_create",
+			user.Bio.Fname,
+			user.Bio.Lname,
+			user.Bio.Username,
+			user.Password,
+			user.Bio.Email,
+		).Scan(&id)
+		if usernameTaken(err) {
+			return ErrUsernameTaken
+		}
+		if err != nil {
+			return err
+		}
+		created := userOf(user)
+		created.Id = int64(id)
+		var batch pgx.Batch
+		if err = recordChanges(ctx, &batch, audit.ActionCreate, []*models.User{nil}, []*models.User{created}); err != nil {
+			return err
+		}
+		return sendChanges(ctx, tx, &batch)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func scanPgxUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.Id,
+		&user.Fname,
+		&user.Lname,
+		&user.Username,
+		&user.Password,
+		&user.Email,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repo *pgxUserRepository) FindByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.FindByUsername", pgxStatements["users_find_username"])
+	defer func() { endSpan(span, err) }()
+
+	return scanPgxUser(pgxConn(ctx, repo.pool).QueryRow(ctx, "users_find_username", username))
+}
+
+func (repo *pgxUserRepository) findBios(ctx context.Context, name string, args ...interface{}) (*models.Users, error) {
+	rows, err := pgxConn(ctx, repo.pool).Query(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := models.Users{
+		User: make([]*models.UserBio, 0, 15),
+	}
+	for rows.Next() {
+		var bio models.UserBio
+		err = rows.Scan(
+			&bio.Id,
+			&bio.Fname,
+			&bio.Lname,
+			&bio.Username,
+			&bio.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users.User = append(users.User, &bio)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &users, nil
+}
+
+func (repo *pgxUserRepository) FindUsers(ctx context.Context) (_ *models.Users, err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.FindUsers", pgxStatements["users_find"])
+	defer func() { endSpan(span, err) }()
+
+	return repo.findBios(ctx, "users_find")
+}
+
+func (repo *pgxUserRepository) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (_ *models.Users, err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.FindByUsernamePrefix", pgxStatements["users_prefix"])
+	defer func() { endSpan(span, err) }()
+
+	return repo.findBios(ctx, "users_prefix", likeEscaper.Replace(strings.ToLower(prefix))+"%", limit)
+}
+
+func (repo *pgxUserRepository) DeleteByUsername(ctx context.Context, username string) (err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.DeleteByUsername", pgxStatements["users_delete"])
+	defer func() { endSpan(span, err) }()
+
+	return repo.withTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		before, err := scanPgxUser(tx.QueryRow(ctx, "users_lock_username", username))
+		if errors.Is(err, ErrNoUserFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var batch pgx.Batch
+		batch.Queue("users_delete", before.Id)
+		if err = recordChanges(ctx, &batch, audit.ActionDelete, []*models.User{before}, []*models.User{nil}); err != nil {
+			return err
+		}
+		return sendChanges(ctx, tx, &batch)
+	})
+}
+
+func (repo *pgxUserRepository) Update(ctx context.Context, user *models.UserPayload) (err error) {
+	ctx, span := startSpan(ctx, "pgxUserRepository.Update", pgxStatements["users_update"])
+	defer func() { endSpan(span, err) }()
+
+	payload := user.GetBio()
+	return repo.withTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		before, err := scanPgxUser(tx.QueryRow(ctx, "users_lock_id", payload.Id))
+		if errors.Is(err, ErrNoUserFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var batch pgx.Batch
+		batch.Queue("users_update",
+			payload.Fname,
+			payload.Lname,
+			payload.Username,
+			user.Password,
+			payload.Email,
+			payload.Id,
+		)
+		if err = recordChanges(ctx, &batch, audit.ActionUpdate, []*models.User{before}, []*models.User{userOf(user)}); err != nil {
+			return err
+		}
+		err = sendChanges(ctx, tx, &batch)
+		if usernameTaken(err) {
+			return ErrUsernameTaken
+		}
+		return err
+	})
+}
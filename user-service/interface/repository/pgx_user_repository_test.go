@@ -0,0 +1,121 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/audit"
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+func bulkPayloads(prefix string, n int) []*models.UserPayload {
+	users := make([]*models.UserPayload, n)
+	for i := range users {
+		username := fmt.Sprintf("%s%d", prefix, i)
+		users[i] = &models.UserPayload{
+			Bio:      &models.UserBio{Fname: "nomu", Lname: "high-end", Username: username, Email: username + "@gmail.com"},
+			Password: "hash-" + username,
+		}
+	}
+	return users
+}
+
+func TestPgxUserRepository(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	truncateUsers(t)
+	t.Cleanup(func() { truncateUsers(t) })
+	users := repos.NewPgxUserRepository(testPgxPool)
+
+	payloads := bulkPayloads("nomu", 2)
+	for _, payload := range payloads {
+		_, err := users.Create(ctx, payload)
+		require.NoError(t, err)
+	}
+	created, err := users.FindByUsername(ctx, "nomu1")
+	require.NoError(t, err)
+	require.Equal(t, "hash-nomu1", created.Password)
+
+	events, err := auditRepo.FindAuditEvents(ctx, &models.AuditFilter{TargetUsername: "nomu1", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events.Events))
+	require.Equal(t, audit.ActionCreate, events.Events[0].Action)
+	require.Equal(t, created.Id, events.Events[0].TargetId)
+
+	// The update goes out in the batch with its audit row, a taken username
+	// fails the whole of it.
+	taken := bulkPayloads("nomu", 1)[0]
+	taken.Bio.Id = created.Id
+	require.ErrorIs(t, users.Update(ctx, taken), repos.ErrUsernameTaken)
+	events, err = auditRepo.FindAuditEvents(ctx, &models.AuditFilter{TargetUsername: "nomu1", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events.Events))
+}
+
+// The benchmarks compare the pgx repository with the database/sql one against
+// the same postgres, run them with -bench=. -run=^$ while docker is up.
+
+func benchmarkRepos() map[string]repository.UserRepository {
+	return map[string]repository.UserRepository{
+		"stdlib": repos.NewUserRepository(testDb),
+		"pgx":    repos.NewPgxUserRepository(testPgxPool),
+	}
+}
+
+func seedUsers(b *testing.B, n int) []string {
+	truncateUsers(b)
+	b.Cleanup(func() { truncateUsers(b) })
+	users := repos.NewPgxUserRepository(testPgxPool)
+	payloads := bulkPayloads("seed", n)
+	usernames := make([]string, n)
+	for i, payload := range payloads {
+		_, err := users.Create(context.Background(), payload)
+		require.NoError(b, err)
+		usernames[i] = payload.Bio.Username
+	}
+	return usernames
+}
+
+func BenchmarkFindByUsername(b *testing.B) {
+	requirePostgres(b)
+	usernames := seedUsers(b, 100)
+	ctx := context.Background()
+	for name, users := range benchmarkRepos() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := users.FindByUsername(ctx, usernames[i%len(usernames)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCreate inserts users along with their audit rows and events, one
+// statement after the other with database/sql and in one batch with pgx.
+func BenchmarkCreate(b *testing.B) {
+	requirePostgres(b)
+	ctx := context.Background()
+	truncateUsers(b)
+	b.Cleanup(func() { truncateUsers(b) })
+	// Every run of a sub-benchmark inserts users of its own.
+	round := 0
+	for name, users := range benchmarkRepos() {
+		b.Run(name, func(b *testing.B) {
+			round++
+			payloads := bulkPayloads(fmt.Sprintf("%s%d_", name, round), b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := users.Create(ctx, payloads[i]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replicas spreads reads over read replicas of a primary. A user written
+// through it is read from the primary until the replicas may be expected to
+// have caught up, so are lists after any write. Only writes made by this
+// process are known of, callers whose writes went through another one say so
+// with ReadPrimary.
+type Replicas struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	sticky   time.Duration
+	now      func() time.Time
+	next     uint64
+
+	mu        sync.Mutex
+	lastWrite time.Time
+	written   map[string]time.Time
+	pruned    time.Time
+}
+
+func NewReplicas(primary *sql.DB, replicas []*sql.DB, sticky time.Duration, now func() time.Time) *Replicas {
+	return &Replicas{
+		primary:  primary,
+		replicas: replicas,
+		sticky:   sticky,
+		now:      now,
+		written:  make(map[string]time.Time),
+	}
+}
+
+type (
+	primaryKey struct{}
+	writesKey  struct{}
+)
+
+// ReadPrimary has the reads made with ctx go to the primary.
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// TrackWrites returns a context whose writes wrote reports once they reached
+// Wrote.
+func TrackWrites(ctx context.Context) (_ context.Context, wrote func() bool) {
+	written := new(int32)
+	return context.WithValue(ctx, writesKey{}, written), func() bool { return atomic.LoadInt32(written) == 1 }
+}
+
+// Wrote records that the users were written, usernames being every name they
+// had before and after.
+func (r *Replicas) Wrote(ctx context.Context, usernames ...string) {
+	if written, ok := ctx.Value(writesKey{}).(*int32); ok {
+		atomic.StoreInt32(written, 1)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.lastWrite = now
+	for _, username := range usernames {
+		r.written[username] = now
+	}
+	if now.Sub(r.pruned) < r.sticky {
+		return
+	}
+	for username, at := range r.written {
+		if now.Sub(at) >= r.sticky {
+			delete(r.written, username)
+		}
+	}
+	r.pruned = now
+}
+
+// Reader returns the database to read username from, the one lists are read
+// from when it is empty.
+func (r *Replicas) Reader(ctx context.Context, username string) *sql.DB {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary || len(r.replicas) == 0 {
+		return r.primary
+	}
+	r.mu.Lock()
+	at := r.lastWrite
+	if username != "" {
+		at = r.written[username]
+	}
+	sticky := !at.IsZero() && r.now().Sub(at) < r.sticky
+	r.mu.Unlock()
+	if sticky {
+		return r.primary
+	}
+	return r.replicas[atomic.AddUint64(&r.next, 1)%uint64(len(r.replicas))]
+}
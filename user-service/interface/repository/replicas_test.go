@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func openUnused(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplicas(t *testing.T) {
+	primary, first, second := openUnused(t), openUnused(t), openUnused(t)
+	c := &clock{now: time.Unix(0, 0)}
+	replicas := repos.NewReplicas(primary, []*sql.DB{first, second}, 5*time.Second, c.Now)
+	ctx := context.Background()
+
+	require.ElementsMatch(t, []*sql.DB{first, second}, []*sql.DB{replicas.Reader(ctx, "shoto"), replicas.Reader(ctx, "shoto")}, "round robin")
+	require.Same(t, primary, replicas.Reader(repos.ReadPrimary(ctx), "shoto"), "caller wrote through another process")
+
+	tracked, wrote := repos.TrackWrites(ctx)
+	require.False(t, wrote())
+	replicas.Wrote(tracked, "shoto", "todoroki")
+	require.True(t, wrote())
+	require.Same(t, primary, replicas.Reader(ctx, "shoto"))
+	require.Same(t, primary, replicas.Reader(ctx, "todoroki"))
+	require.Same(t, primary, replicas.Reader(ctx, ""), "lists follow any write")
+	require.NotSame(t, primary, replicas.Reader(ctx, "dabi"))
+
+	c.now = c.now.Add(5 * time.Second)
+	require.NotSame(t, primary, replicas.Reader(ctx, "shoto"))
+	require.NotSame(t, primary, replicas.Reader(ctx, ""))
+
+	require.Same(t, primary, repos.NewReplicas(primary, nil, time.Second, c.Now).Reader(ctx, "shoto"), "without replicas")
+}
+
+func TestReplicaFailover(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.Cleanup(func() { truncateUsers(t) })
+
+	down := openUnused(t)
+	require.NoError(t, down.Close())
+	users := repos.NewReplicatedUserRepository(repos.NewReplicas(testDb, []*sql.DB{down}, time.Nanosecond, time.Now))
+	_, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Fname: "shoto", Username: "shoto"}, Password: "hash"})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	user, err := users.FindByUsername(ctx, "shoto")
+	require.NoError(t, err, "read from the primary when the replica fails")
+	require.Equal(t, "shoto", user.Fname)
+	all, err := users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, all.User, 1)
+}
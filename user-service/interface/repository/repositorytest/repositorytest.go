@@ -0,0 +1,351 @@
+// Package repositorytest holds the behaviour every repository.UserRepository
+// has to show, so storage backends and the decorators wrapped around them are
+// all tested against the same contract.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty repository. It is called once for every test of
+// the suite, cleanup is registered on t.
+type Factory func(t *testing.T) repository.UserRepository
+
+// Run tests the repositories newRepo makes against the whole contract.
+func Run(t *testing.T, newRepo Factory) {
+	tests := map[string]func(t *testing.T, users repository.UserRepository){
+		"create":             testCreate,
+		"duplicate username": testDuplicateUsername,
+		"not found":          testNotFound,
+		"update":             testUpdate,
+		"delete":             testDelete,
+		"order":              testOrder,
+		"prefix":             testPrefix,
+		"pagination":         testPagination,
+		"concurrent creates": testConcurrentCreates,
+		"concurrent access":  testConcurrentAccess,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func payload(fname, username string) *models.UserPayload {
+	return &models.UserPayload{
+		Bio: &models.UserBio{
+			Fname:    fname,
+			Lname:    "todoroki",
+			Username: username,
+			Email:    username + "@gmail.com",
+		},
+		Password: "hash-" + username,
+	}
+}
+
+func create(ctx context.Context, t *testing.T, users repository.UserRepository, fname, username string) int64 {
+	t.Helper()
+	id, err := users.Create(ctx, payload(fname, username))
+	require.NoError(t, err)
+	return int64(id)
+}
+
+func usernames(users *models.Users) []string {
+	names := make([]string, 0, len(users.User))
+	for _, user := range users.User {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+func testCreate(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	first := create(ctx, t, users, "shoto", "shoto")
+	second := create(ctx, t, users, "enji", "endeavor")
+	require.Positive(t, first)
+	require.Less(t, first, second, "ids grow with every user")
+
+	user, err := users.FindByUsername(ctx, "endeavor")
+	require.NoError(t, err)
+	require.Equal(t, second, user.Id)
+	require.Equal(t, "enji", user.Fname)
+	require.Equal(t, "todoroki", user.Lname)
+	require.Equal(t, "endeavor@gmail.com", user.Email)
+	require.Equal(t, "hash-endeavor", user.Password)
+}
+
+func testDuplicateUsername(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	id := create(ctx, t, users, "shoto", "shoto")
+
+	_, err := users.Create(ctx, payload("touya", "shoto"))
+	require.ErrorIs(t, err, repos.ErrUsernameTaken)
+	user, err := users.FindByUsername(ctx, "shoto")
+	require.NoError(t, err)
+	require.Equal(t, id, user.Id)
+	require.Equal(t, "shoto", user.Fname, "the registered user is left alone")
+
+	create(ctx, t, users, "shoto", "Shoto")
+}
+
+func testNotFound(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	_, err := users.FindByUsername(ctx, "hawks")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+
+	// A lookup that missed must not hide the user registered after it.
+	create(ctx, t, users, "keigo", "hawks")
+	user, err := users.FindByUsername(ctx, "hawks")
+	require.NoError(t, err)
+	require.Equal(t, "keigo", user.Fname)
+
+	_, err = users.FindByUsername(ctx, "HAWKS")
+	require.ErrorIs(t, err, repos.ErrNoUserFound, "usernames are case sensitive")
+	_, err = users.FindByUsername(ctx, "hawk")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+}
+
+func testUpdate(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	id := create(ctx, t, users, "touya", "dabi")
+	create(ctx, t, users, "shoto", "shoto")
+	// Warm any cache in front of the store with the name about to change.
+	_, err := users.FindByUsername(ctx, "dabi")
+	require.NoError(t, err)
+
+	update := &models.UserPayload{
+		Bio:      &models.UserBio{Id: id, Fname: "touya", Lname: "todoroki", Username: "touya", Email: "touya@gmail.com"},
+		Password: "hash2",
+	}
+	require.NoError(t, users.Update(ctx, update))
+	_, err = users.FindByUsername(ctx, "dabi")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+	user, err := users.FindByUsername(ctx, "touya")
+	require.NoError(t, err)
+	require.Equal(t, id, user.Id)
+	require.Equal(t, "touya@gmail.com", user.Email)
+	require.Equal(t, "hash2", user.Password)
+	suggested, err := users.FindByUsernamePrefix(ctx, "dab", 10)
+	require.NoError(t, err)
+	require.Empty(t, suggested.User)
+	suggested, err = users.FindByUsernamePrefix(ctx, "tou", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"touya"}, usernames(suggested))
+
+	update.Bio.Username = "shoto"
+	require.ErrorIs(t, users.Update(ctx, update), repos.ErrUsernameTaken)
+	user, err = users.FindByUsername(ctx, "touya")
+	require.NoError(t, err)
+	require.Equal(t, id, user.Id, "a rejected update changes nothing")
+	user, err = users.FindByUsername(ctx, "shoto")
+	require.NoError(t, err)
+	require.Equal(t, "shoto", user.Fname)
+
+	update.Bio.Id = id + 1000
+	update.Bio.Username = "nobody"
+	require.NoError(t, users.Update(ctx, update), "updating a user that is not registered is not an error")
+	_, err = users.FindByUsername(ctx, "nobody")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+}
+
+func testDelete(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	create(ctx, t, users, "touya", "dabi")
+	create(ctx, t, users, "shoto", "shoto")
+	_, err := users.FindByUsername(ctx, "dabi")
+	require.NoError(t, err)
+
+	require.NoError(t, users.DeleteByUsername(ctx, "dabi"))
+	require.NoError(t, users.DeleteByUsername(ctx, "dabi"), "deleting twice is not an error")
+	require.NoError(t, users.DeleteByUsername(ctx, "nobody"))
+	_, err = users.FindByUsername(ctx, "dabi")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+	all, err := users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"shoto"}, usernames(all))
+	suggested, err := users.FindByUsernamePrefix(ctx, "d", 10)
+	require.NoError(t, err)
+	require.Empty(t, suggested.User)
+
+	// The username is free again.
+	create(ctx, t, users, "touya", "dabi")
+}
+
+func testOrder(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	all, err := users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, all)
+	require.Empty(t, all.User)
+
+	create(ctx, t, users, "shoto", "shoto")
+	create(ctx, t, users, "dabi", "dabi")
+	create(ctx, t, users, "shoto", "Shoto_2")
+	create(ctx, t, users, "Shoto", "shotox")
+	create(ctx, t, users, "", "nameless")
+	all, err = users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"nameless", "shotox", "dabi", "shoto", "Shoto_2"}, usernames(all), "by first name bytewise, then id")
+	require.Empty(t, all.User[0].Fname)
+	require.Equal(t, "shoto@gmail.com", all.User[3].Email)
+}
+
+func testPrefix(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	for _, username := range []string{"shoto", "Shoto_2", "shotox", "ShotoA", "sho%to", "dabi"} {
+		create(ctx, t, users, "shoto", username)
+	}
+
+	testTable := map[string]struct {
+		prefix string
+		expect []string
+	}{
+		"shortest first then case insensitive": {prefix: "SHOTO", expect: []string{"shoto", "ShotoA", "shotox", "Shoto_2"}},
+		"underscore is no wildcard":            {prefix: "shoto_", expect: []string{"Shoto_2"}},
+		"percent is no wildcard":               {prefix: "sho%", expect: []string{"sho%to"}},
+		"empty prefix matches all":             {prefix: "", expect: []string{"dabi", "shoto", "sho%to", "ShotoA", "shotox", "Shoto_2"}},
+		"no match":                             {prefix: "hawks", expect: []string{}},
+	}
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			suggested, err := users.FindByUsernamePrefix(ctx, tc.prefix, 10)
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, usernames(suggested))
+		})
+	}
+}
+
+func testPagination(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	for i := 0; i < 12; i++ {
+		create(ctx, t, users, "deku", fmt.Sprintf("deku%02d", i))
+	}
+	all, err := users.FindByUsernamePrefix(ctx, "deku", 100)
+	require.NoError(t, err)
+	require.Len(t, all.User, 12)
+
+	for _, limit := range []int{0, 1, 5, 12, 13} {
+		page, err := users.FindByUsernamePrefix(ctx, "deku", limit)
+		require.NoError(t, err)
+		expect := limit
+		if expect > 12 {
+			expect = 12
+		}
+		require.Equal(t, usernames(all)[:expect], usernames(page), "limit %d takes the first results in order", limit)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	const n = 16
+
+	var wg sync.WaitGroup
+	ids := make([]int, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = users.Create(ctx, payload("mirio", fmt.Sprintf("lemillion%02d", i)))
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[int]bool, n)
+	for i := range ids {
+		require.NoError(t, errs[i])
+		require.False(t, seen[ids[i]], "id %d handed out twice", ids[i])
+		seen[ids[i]] = true
+	}
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = users.Create(ctx, payload("mirio", "mirio"))
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, repos.ErrUsernameTaken)
+	}
+	require.Equal(t, 1, created, "one of the racing creates wins")
+
+	all, err := users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, all.User, n+1)
+}
+
+// testConcurrentAccess interleaves reads with the writes they observe, for the
+// race detector to look at.
+func testConcurrentAccess(t *testing.T, users repository.UserRepository) {
+	ctx := testContext(t)
+	const n = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*n)
+	for i := 0; i < n; i++ {
+		username := fmt.Sprintf("tamaki%02d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			id, err := users.Create(ctx, payload("tamaki", username))
+			if err != nil {
+				errs <- err
+				return
+			}
+			update := payload("suneater", username)
+			update.Bio.Id = int64(id)
+			if err = users.Update(ctx, update); err != nil {
+				errs <- err
+				return
+			}
+			errs <- users.DeleteByUsername(ctx, username)
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := users.FindByUsername(ctx, username); err != nil && !errors.Is(err, repos.ErrNoUserFound) {
+				errs <- err
+			}
+			if _, err := users.FindByUsernamePrefix(ctx, "tamaki", 5); err != nil {
+				errs <- err
+			}
+			if _, err := users.FindUsers(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	all, err := users.FindUsers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, all.User)
+	suggested, err := users.FindByUsernamePrefix(ctx, "tamaki", 5)
+	require.NoError(t, err)
+	require.Empty(t, suggested.User)
+}
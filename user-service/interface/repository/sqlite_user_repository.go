@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const sqliteSchema = `create table if not exists users (
+  id integer not null primary key autoincrement,
+  first_name text,
+  last_name text,
+  username text not null unique,
+  password text,
+  email text
+)`
+
+// OpenSQLite opens the SQLite database at path, ":memory:" for one that lives
+// as long as the process, and creates the users table when it is missing.
+func OpenSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite takes one writer at a time anyway, and an in-memory database only
+	// lives as long as its connection.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	if _, err = db.ExecContext(ctx, sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteUserRepository stores users in SQLite, for running user-service
+// without postgres. It answers like the postgres repository down to the errors
+// and the order of the results, but records no audit trail or events.
+// Statements run in the transaction the context carries, if any.
+type sqliteUserRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserRepository(db *sql.DB) *sqliteUserRepository {
+	return &sqliteUserRepository{db: db}
+}
+
+func startSQLiteSpan(ctx context.Context, name, statement string) (context.Context, trace.Span) {
+	return startSpanOn(ctx, semconv.DBSystemSqlite, name, statement)
+}
+
+func sqliteUsernameTaken(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func (repo *sqliteUserRepository) Create(ctx context.Context, user *models.UserPayload) (_ int, err error) {
+	statement := "insert into users (first_name, last_name, username, password, email) values (?, ?, ?, ?, ?) returning id"
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.Create", statement)
+	defer func() { endSpan(span, err) }()
+
+	var id int
+	err = conn(ctx, repo.db).QueryRowContext(ctx, statement,
+		user.Bio.Fname,
+		user.Bio.Lname,
+		user.Bio.Username,
+		user.Password,
+		user.Bio.Email,
+	).Scan(&id)
+	if sqliteUsernameTaken(err) {
+		return 0, ErrUsernameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (repo *sqliteUserRepository) findBios(ctx context.Context, statement string, args ...interface{}) (*models.Users, error) {
+	rows, err := conn(ctx, repo.db).QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := models.Users{
+		User: make([]*models.UserBio, 0, 15),
+	}
+	for rows.Next() {
+		var bio models.UserBio
+		err = rows.Scan(
+			&bio.Id,
+			&bio.Fname,
+			&bio.Lname,
+			&bio.Username,
+			&bio.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users.User = append(users.User, &bio)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &users, nil
+}
+
+func (repo *sqliteUserRepository) FindUsers(ctx context.Context) (_ *models.Users, err error) {
+	statement := `select id, first_name, last_name, username, email from users order by first_name, id`
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.FindUsers", statement)
+	defer func() { endSpan(span, err) }()
+
+	return repo.findBios(ctx, statement)
+}
+
+func (repo *sqliteUserRepository) FindByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	statement := `select id, first_name, last_name, username, password, email from users where username=?`
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.FindByUsername", statement)
+	defer func() { endSpan(span, err) }()
+
+	var user models.User
+	err = conn(ctx, repo.db).QueryRowContext(ctx, statement, username).Scan(
+		&user.Id,
+		&user.Fname,
+		&user.Lname,
+		&user.Username,
+		&user.Password,
+		&user.Email,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoUserFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repo *sqliteUserRepository) DeleteByUsername(ctx context.Context, username string) (err error) {
+	statement := "delete from users where username=?"
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.DeleteByUsername", statement)
+	defer func() { endSpan(span, err) }()
+
+	_, err = conn(ctx, repo.db).ExecContext(ctx, statement, username)
+	return err
+}
+
+func (repo *sqliteUserRepository) Update(ctx context.Context, user *models.UserPayload) (err error) {
+	statement := `update users set
+			first_name=?,
+			last_name=?,
+			username=?,
+			password=?,
+			email=?
+			where id=?
+	`
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.Update", statement)
+	defer func() { endSpan(span, err) }()
+
+	payload := user.GetBio()
+	_, err = conn(ctx, repo.db).ExecContext(ctx, statement,
+		payload.Fname,
+		payload.Lname,
+		payload.Username,
+		user.Password,
+		payload.Email,
+		payload.Id,
+	)
+	if sqliteUsernameTaken(err) {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// FindByUsernamePrefix ranks like the postgres query, SQLite compares text
+// bytewise as postgres does with the C collation.
+func (repo *sqliteUserRepository) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (_ *models.Users, err error) {
+	statement := `select id, first_name, last_name, username, email from users
+			where lower(username) like ? escape '\'
+			order by length(username), lower(username), username
+			limit ?
+	`
+	ctx, span := startSQLiteSpan(ctx, "sqliteUserRepository.FindByUsernamePrefix", statement)
+	defer func() { endSpan(span, err) }()
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+	return repo.findBios(ctx, statement, pattern, limit)
+}
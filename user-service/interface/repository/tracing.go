@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/spriigan/RPApp/interface/repository")
+
+// startSpan starts the span of a repository method running statement. Only
+// the statement is recorded, never the values bound to it.
+func startSpan(ctx context.Context, name, statement string) (context.Context, trace.Span) {
+	return startSpanOn(ctx, semconv.DBSystemPostgreSQL, name, statement)
+}
+
+func startSpanOn(ctx context.Context, system attribute.KeyValue, name, statement string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(system, semconv.DBStatementKey.String(statement)),
+	)
+}
+
+// endSpan marks the span failed unless err is nil or only reports a missing
+// user, which callers expect.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNoUserFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
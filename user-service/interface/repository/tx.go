@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type txKey struct{}
+
+// txState is the transaction a context carries, along with the work waiting
+// for it to commit. It is a transaction of db, or of pool with pgx.
+type txState struct {
+	db          *sql.DB
+	tx          *sql.Tx
+	pool        *pgxpool.Pool
+	pgxTx       pgx.Tx
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+// TxOptions tunes the transactions a transactor starts. Retries is how many
+// more times work is run when postgres aborts it on a serialization failure or
+// a deadlock.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	Retries   int
+}
+
+var DefaultTxOptions = TxOptions{Isolation: sql.LevelReadCommitted, Retries: 3}
+
+type transactor struct {
+	db        *sql.DB
+	opts      TxOptions
+	retryable func(error) bool
+}
+
+// NewTransactor runs work in postgres transactions of db.
+func NewTransactor(db *sql.DB, opts TxOptions) *transactor {
+	return &transactor{db: db, opts: opts, retryable: serializationFailure}
+}
+
+// NewSQLiteTransactor runs work in SQLite transactions of db. SQLite lets one
+// writer in at a time, there is no isolation to pick or conflict to retry.
+func NewSQLiteTransactor(db *sql.DB) *transactor {
+	return &transactor{db: db, retryable: func(error) bool { return false }}
+}
+
+// serializationFailure tells whether postgres aborted the transaction for
+// running it again to succeed, 40001 being serialization_failure and 40P01
+// deadlock_detected.
+func serializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// WithinTx runs fn in a transaction, committed when fn returns nil and rolled
+// back otherwise. Work postgres aborts to keep transactions apart is run again
+// from the start in a new transaction, so fn must not have effects outside the
+// database; AfterCommit is there for those.
+func (t *transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.db == t.db {
+		return fn(ctx)
+	}
+	return retryTx(ctx, t.opts.Retries, t.retryable, func() (*txState, error) {
+		state := &txState{db: t.db}
+		return state, t.run(ctx, state, fn)
+	})
+}
+
+// retryTx makes attempts at a transaction until one commits, then runs what
+// waits on the commit. It gives up on errors retryable turns down and once
+// the retries run out.
+func retryTx(ctx context.Context, retries int, retryable func(error) bool, attempt func() (*txState, error)) error {
+	for n := 0; ; n++ {
+		state, err := attempt()
+		if err == nil {
+			for _, after := range state.afterCommit {
+				after()
+			}
+			return nil
+		}
+		if n >= retries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff(n)):
+		}
+	}
+}
+
+func (t *transactor) run(ctx context.Context, state *txState, fn func(ctx context.Context) error) (err error) {
+	state.tx, err = t.db.BeginTx(ctx, &sql.TxOptions{Isolation: t.opts.Isolation})
+	if err != nil {
+		return err
+	}
+	defer state.tx.Rollback()
+
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+	return state.tx.Commit()
+}
+
+// backoff spreads out the attempts of transactions that keep running into
+// each other.
+func backoff(attempt int) time.Duration {
+	d := 10 * time.Millisecond << attempt
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// memoryTransactor gives the memory backend the same WithinTx as the others.
+// It has nothing to roll back with: writes fn made stay when it fails.
+type memoryTransactor struct{}
+
+func NewMemoryTransactor() memoryTransactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// InTx tells whether ctx carries a transaction.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction ctx carries commits, right away
+// when it carries none. Nothing runs for a transaction rolled back, and of
+// work run again only the attempt that committed counts.
+func AfterCommit(ctx context.Context, fn func()) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		fn()
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.afterCommit = append(state.afterCommit, fn)
+}
+
+// queryer is what statements run on, a transaction or the database itself.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction of db ctx carries, db when there is none.
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.db == db {
+		return state.tx
+	}
+	return db
+}
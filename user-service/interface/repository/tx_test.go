@@ -0,0 +1,111 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithinTx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db, err := repos.OpenSQLite(ctx, filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	users := repos.NewSQLiteUserRepository(db)
+	tx := repos.NewSQLiteTransactor(db)
+	payload := func(username string) *models.UserPayload {
+		return &models.UserPayload{Bio: &models.UserBio{Fname: "shoto", Username: username}, Password: "hash"}
+	}
+
+	committed := false
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		require.True(t, repos.InTx(ctx))
+		repos.AfterCommit(ctx, func() { committed = true })
+		if _, err := users.Create(ctx, payload("shoto")); err != nil {
+			return err
+		}
+		// Nested work joins, SQLite having a single connection it would
+		// wait on the outer transaction forever otherwise.
+		return tx.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := users.FindByUsername(ctx, "shoto")
+			return err
+		})
+	})
+	require.NoError(t, err)
+	require.True(t, committed)
+	_, err = users.FindByUsername(ctx, "shoto")
+	require.NoError(t, err)
+
+	failed := errors.New("got an error")
+	committed = false
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		repos.AfterCommit(ctx, func() { committed = true })
+		if _, err := users.Create(ctx, payload("dabi")); err != nil {
+			return err
+		}
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	require.False(t, committed, "nothing runs after a rollback")
+	_, err = users.FindByUsername(ctx, "dabi")
+	require.ErrorIs(t, err, repos.ErrNoUserFound)
+
+	require.False(t, repos.InTx(ctx))
+	repos.AfterCommit(ctx, func() { committed = true })
+	require.True(t, committed, "runs at once outside a transaction")
+}
+
+func TestWithinTxRetries(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	t.Cleanup(func() { truncateUsers(t) })
+	tx := repos.NewTransactor(testDb, repos.TxOptions{Isolation: sql.LevelSerializable, Retries: 3})
+	users := repos.NewUserRepository(testDb)
+
+	// Both count the users then add one, each first attempt waiting for the
+	// other to have counted too. Serializable, one of them has to go again.
+	var counted sync.WaitGroup
+	counted.Add(2)
+	var mu sync.Mutex
+	attempts := 0
+	write := func(username string) error {
+		first := true
+		return tx.WithinTx(ctx, func(ctx context.Context) error {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			if _, err := users.FindUsers(ctx); err != nil {
+				return err
+			}
+			if first {
+				first = false
+				counted.Done()
+				counted.Wait()
+			}
+			_, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Fname: "mirio", Username: username}, Password: "hash"})
+			return err
+		})
+	}
+
+	errs := make(chan error, 2)
+	for _, username := range []string{"lemillion", "suneater"} {
+		go func(username string) { errs <- write(username) }(username)
+	}
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	require.Greater(t, attempts, 2)
+	for _, username := range []string{"lemillion", "suneater"} {
+		_, err := users.FindByUsername(ctx, username)
+		require.NoError(t, err)
+	}
+}
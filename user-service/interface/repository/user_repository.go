@@ -4,73 +4,177 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/events"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
 	"github.com/spriigan/RPApp/user-proto/grpc/models"
 )
 
+// userRepository stores users in postgres. Writes run in the transaction the
+// context carries, in one of their own otherwise, together with the audit row
+// and the outbox event recording them.
 type userRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	tx       *transactor
+	replicas *Replicas
 }
 
 func NewUserRepository(db *sql.DB) *userRepository {
-	return &userRepository{db: db}
+	return &userRepository{db: db, tx: NewTransactor(db, DefaultTxOptions)}
+}
+
+// NewReplicatedUserRepository writes to the primary of replicas and looks
+// users up on its replicas.
+func NewReplicatedUserRepository(replicas *Replicas) *userRepository {
+	repo := NewUserRepository(replicas.primary)
+	repo.replicas = replicas
+	return repo
 }
 
 var ErrNoUserFound = errors.New("user is not registered yet")
 
-func (repo *userRepository) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+// ErrUsernameTaken is returned by Create and Update when another user already
+// has the username.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// usernameTaken tells whether err is postgres rejecting a duplicate username,
+// 23505 being unique_violation.
+func usernameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_username_key"
+}
+
+func (repo *userRepository) withTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return repo.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return fn(ctx, ctx.Value(txKey{}).(*txState).tx)
+	})
+}
+
+// read runs fn on the database username is read from. The primary answers
+// in a transaction and when a replica fails.
+func (repo *userRepository) read(ctx context.Context, username string, fn func(q queryer) error) error {
+	if repo.replicas == nil || InTx(ctx) {
+		return fn(conn(ctx, repo.db))
+	}
+	db := repo.replicas.Reader(ctx, username)
+	err := fn(db)
+	if err != nil && db != repo.db && ctx.Err() == nil && !errors.Is(err, sql.ErrNoRows) {
+		return fn(repo.db)
+	}
+	return err
+}
+
+// wrote sends the reads of the users to the primary once the write commits.
+func (repo *userRepository) wrote(ctx context.Context, usernames ...string) {
+	if repo.replicas != nil {
+		AfterCommit(ctx, func() { repo.replicas.Wrote(ctx, usernames...) })
+	}
+}
+
+func (repo *userRepository) Create(ctx context.Context, user *models.UserPayload) (_ int, err error) {
 
 	statement := "insert into users (first_name, last_name, username, password, email) values ($1, $2, $3, $4, $5) returning id"
 	var id int
+	ctx, span := startSpan(ctx, "userRepository.Create", statement)
+	defer func() { endSpan(span, err) }()
 
-	err := repo.db.QueryRowContext(ctx, statement,
-		user.Bio.Fname,
-		user.Bio.Lname,
-		user.Bio.Username,
-		user.Password,
-		user.Bio.Email,
-	).Scan(&id)
+	err = repo.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, statement,
+			user.Bio.Fname,
+			user.Bio.Lname,
+			user.Bio.Username,
+			user.Password,
+			user.Bio.Email,
+		).Scan(&id)
+		if usernameTaken(err) {
+			return ErrUsernameTaken
+		}
+		if err != nil {
+			return err
+		}
+		created := userOf(user)
+		created.Id = int64(id)
+		repo.wrote(ctx, created.Username)
+		return recordChange(ctx, tx, audit.ActionCreate, nil, created)
+	})
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (repo *userRepository) FindUsers(ctx context.Context) (*models.Users, error) {
-	statement := `select id, first_name, last_name, username, email from users order by first_name`
+func (repo *userRepository) FindUsers(ctx context.Context) (_ *models.Users, err error) {
+	statement := `select id, first_name, last_name, username, email from users order by first_name collate "C", id`
+	ctx, span := startSpan(ctx, "userRepository.FindUsers", statement)
+	defer func() { endSpan(span, err) }()
 
-	rows, err := repo.db.QueryContext(ctx, statement)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
 	users := models.Users{
 		User: make([]*models.UserBio, 0, 15),
 	}
-
-	for rows.Next() {
-		var bio models.UserBio
-		err = rows.Scan(
-			&bio.Id,
-			&bio.Fname,
-			&bio.Lname,
-			&bio.Username,
-			&bio.Email,
-		)
+	err = repo.read(ctx, "", func(q queryer) error {
+		rows, err := q.QueryContext(ctx, statement)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		users.User = append(users.User, &bio)
+		defer rows.Close()
+		users.User = users.User[:0]
+		for rows.Next() {
+			var bio models.UserBio
+			err = rows.Scan(
+				&bio.Id,
+				&bio.Fname,
+				&bio.Lname,
+				&bio.Username,
+				&bio.Email,
+			)
+			if err != nil {
+				return err
+			}
+			users.User = append(users.User, &bio)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &users, nil
 }
 
-func (repo *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+func (repo *userRepository) FindByUsername(ctx context.Context, username string) (_ *models.User, err error) {
 
 	statement := `select id, first_name, last_name, username, password, email from users where username=$1`
 	var user models.User
+	ctx, span := startSpan(ctx, "userRepository.FindByUsername", statement)
+	defer func() { endSpan(span, err) }()
 
-	err := repo.db.QueryRowContext(ctx, statement, username).Scan(
+	err = repo.read(ctx, username, func(q queryer) error {
+		return q.QueryRowContext(ctx, statement, username).Scan(
+			&user.Id,
+			&user.Fname,
+			&user.Lname,
+			&user.Username,
+			&user.Password,
+			&user.Email,
+		)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoUserFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findForUpdate locks the row of the user matching condition until tx ends.
+func findForUpdate(ctx context.Context, tx *sql.Tx, condition string, arg interface{}) (*models.User, error) {
+	statement := `select id, first_name, last_name, username, password, email from users where ` + condition + ` for update`
+	var user models.User
+
+	err := tx.QueryRowContext(ctx, statement, arg).Scan(
 		&user.Id,
 		&user.Fname,
 		&user.Lname,
@@ -87,18 +191,70 @@ func (repo *userRepository) FindByUsername(ctx context.Context, username string)
 	return &user, nil
 }
 
-func (repo *userRepository) DeleteByUsername(ctx context.Context, username string) error {
+// recordChange writes the audit row and the outbox event describing a mutation
+// in the transaction that performs it.
+func recordChange(ctx context.Context, tx *sql.Tx, action string, before, after *models.User) error {
+	event, envelope := changeRecords(ctx, action, before, after)
+	if err := insertAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, envelope)
+}
+
+// changeRecords are the audit event and the outbox event recording a mutation.
+func changeRecords(ctx context.Context, action string, before, after *models.User) (*models.AuditEvent, *eventsv1.Envelope) {
+	var envelope *eventsv1.Envelope
+	switch action {
+	case audit.ActionCreate:
+		envelope = events.UserCreated(ctx, after)
+	case audit.ActionUpdate:
+		envelope = events.UserUpdated(ctx, before, after)
+	case audit.ActionDelete:
+		envelope = events.UserDeleted(ctx, before)
+	}
+	return audit.NewEvent(ctx, action, before, after), envelope
+}
+
+func userOf(payload *models.UserPayload) *models.User {
+	bio := payload.GetBio()
+	return &models.User{
+		Id:       bio.GetId(),
+		Fname:    bio.GetFname(),
+		Lname:    bio.GetLname(),
+		Username: bio.GetUsername(),
+		Email:    bio.GetEmail(),
+		Password: payload.GetPassword(),
+	}
+}
 
-	statement := "delete from users where username=$1"
+func (repo *userRepository) DeleteByUsername(ctx context.Context, username string) (err error) {
 
-	_, err := repo.db.ExecContext(ctx, statement, username)
+	statement := "delete from users where id=$1"
+	ctx, span := startSpan(ctx, "userRepository.DeleteByUsername", statement)
+	defer func() { endSpan(span, err) }()
+
+	err = repo.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		before, err := findForUpdate(ctx, tx, "username=$1", username)
+		if err != nil {
+			if errors.Is(err, ErrNoUserFound) {
+				return nil
+			}
+			return err
+		}
+		_, err = tx.ExecContext(ctx, statement, before.Id)
+		if err != nil {
+			return err
+		}
+		repo.wrote(ctx, before.Username)
+		return recordChange(ctx, tx, audit.ActionDelete, before, nil)
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (repo *userRepository) Update(ctx context.Context, user *models.UserPayload) error {
+func (repo *userRepository) Update(ctx context.Context, user *models.UserPayload) (err error) {
 
 	payload := user.GetBio()
 	statement := `update users set
@@ -109,17 +265,80 @@ func (repo *userRepository) Update(ctx context.Context, user *models.UserPayload
 			email=$5
 			where id=$6
 	`
+	ctx, span := startSpan(ctx, "userRepository.Update", statement)
+	defer func() { endSpan(span, err) }()
 
-	_, err := repo.db.ExecContext(ctx, statement,
-		payload.Fname,
-		payload.Lname,
-		payload.Username,
-		user.Password,
-		payload.Email,
-		payload.Id,
-	)
+	err = repo.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		before, err := findForUpdate(ctx, tx, "id=$1", payload.Id)
+		if err != nil {
+			if errors.Is(err, ErrNoUserFound) {
+				return nil
+			}
+			return err
+		}
+		_, err = tx.ExecContext(ctx, statement,
+			payload.Fname,
+			payload.Lname,
+			payload.Username,
+			user.Password,
+			payload.Email,
+			payload.Id,
+		)
+		if usernameTaken(err) {
+			return ErrUsernameTaken
+		}
+		if err != nil {
+			return err
+		}
+		repo.wrote(ctx, before.Username, payload.Username)
+		return recordChange(ctx, tx, audit.ActionUpdate, before, userOf(user))
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// FindByUsernamePrefix matches usernames case-insensitively, shortest first so
+// that an exact match always ranks on top.
+func (repo *userRepository) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (_ *models.Users, err error) {
+	statement := `select id, first_name, last_name, username, email from users
+			where lower(username) like $1 escape '\'
+			order by length(username), lower(username) collate "C", username collate "C"
+			limit $2
+	`
+
+	ctx, span := startSpan(ctx, "userRepository.FindByUsernamePrefix", statement)
+	defer func() { endSpan(span, err) }()
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+	rows, err := conn(ctx, repo.db).QueryContext(ctx, statement, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := models.Users{
+		User: make([]*models.UserBio, 0, limit),
+	}
+
+	for rows.Next() {
+		var bio models.UserBio
+		err = rows.Scan(
+			&bio.Id,
+			&bio.Fname,
+			&bio.Lname,
+			&bio.Username,
+			&bio.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users.User = append(users.User, &bio)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &users, nil
+}
@@ -11,13 +11,19 @@ import (
 
 	_ "github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/events"
 	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/migrate"
 	"github.com/spriigan/RPApp/usecases/repository"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
 	"github.com/spriigan/RPApp/user-proto/grpc/models"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -32,13 +38,20 @@ const (
 var resource *dockertest.Resource
 var pool *dockertest.Pool
 var testDb *sql.DB
+var testPgxPool *pgxpool.Pool
 var userRepo repository.UserRepository
+var auditRepo repository.AuditRepository
 
 func TestMain(m *testing.M) {
 	p, err := dockertest.NewPool("")
 	if err != nil {
 		log.Fatalf("cant connect to docker, make sure that docker is running: %s", err)
 	}
+	if err = p.Client.Ping(); err != nil {
+		// The backends that need no server are still tested.
+		log.Printf("docker is not running, skipping the postgres tests: %s", err)
+		os.Exit(m.Run())
+	}
 
 	pool = p
 
@@ -81,10 +94,23 @@ func TestMain(m *testing.M) {
 		log.Fatalf("cant create table: %s", err)
 	}
 
+	poolConfig, err := pgxpool.ParseConfig(fmt.Sprintf(dsn, host, port, user, password, dbName))
+	if err != nil {
+		_ = pool.Purge(resource)
+		log.Fatalf("cant parse the dsn: %s", err)
+	}
+	testPgxPool, err = repos.OpenPgxPool(context.Background(), poolConfig)
+	if err != nil {
+		_ = pool.Purge(resource)
+		log.Fatalf("cant open the pgx pool: %s", err)
+	}
+
 	userRepo = repos.NewUserRepository(testDb)
+	auditRepo = repos.NewAuditRepository(testDb)
 
 	code := m.Run()
 
+	testPgxPool.Close()
 	if err = pool.Purge(resource); err != nil {
 		log.Fatalf("cant clean up resources: %s", err)
 	}
@@ -92,13 +118,21 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// requirePostgres skips t when docker was not there to start postgres.
+func requirePostgres(t testing.TB) {
+	t.Helper()
+	if testDb == nil {
+		t.Skip("postgres is not running")
+	}
+}
+
 func createTables() error {
-	tableSql, err := os.ReadFile("./testdata/user.sql")
+	migrations, err := migrate.Embedded()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	_, err = testDb.Exec(string(tableSql))
+	_, err = migrate.New(testDb, migrations).Up(context.Background())
 	if err != nil {
 		log.Println(err)
 		return err
@@ -107,11 +141,13 @@ func createTables() error {
 }
 
 func TestPingDb(t *testing.T) {
+	requirePostgres(t)
 	err := testDb.Ping()
 	require.NoError(t, err)
 }
 
 func TestCreate(t *testing.T) {
+	requirePostgres(t)
 	payload := models.UserPayload{
 		Bio: &models.UserBio{
 			Fname:    "ryan",
@@ -130,6 +166,7 @@ func TestCreate(t *testing.T) {
 }
 
 func TestFindUsers(t *testing.T) {
+	requirePostgres(t)
 	payload := models.UserPayload{
 		Bio: &models.UserBio{
 			Fname:    "ryan",
@@ -151,6 +188,7 @@ func TestFindUsers(t *testing.T) {
 }
 
 func TestFindByUsername(t *testing.T) {
+	requirePostgres(t)
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 	user, err := userRepo.FindByUsername(ctx, "ryanpujo1")
@@ -164,6 +202,7 @@ func TestFindByUsername(t *testing.T) {
 }
 
 func TestDeleteByUsername(t *testing.T) {
+	requirePostgres(t)
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 	err := userRepo.DeleteByUsername(ctx, "ryanpujo1")
@@ -175,6 +214,7 @@ func TestDeleteByUsername(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
+	requirePostgres(t)
 	payload := &models.UserPayload{
 		Bio: &models.UserBio{
 			Id:       1,
@@ -194,3 +234,160 @@ func TestUpdate(t *testing.T) {
 	require.NotNil(t, user)
 	require.Equal(t, payload.Bio.Lname, user.Lname)
 }
+
+func TestFindByUsernamePrefix(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	for _, username := range []string{"ryanp", "ryan_x", "Ryanpujo2"} {
+		_, err := userRepo.Create(ctx, &models.UserPayload{
+			Bio:      &models.UserBio{Fname: "ryan", Lname: "pujo", Username: username},
+			Password: "oke",
+		})
+		require.NoError(t, err)
+	}
+
+	users, err := userRepo.FindByUsernamePrefix(ctx, "RYAN", 10)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(users.User))
+	require.Equal(t, "ryanp", users.User[0].Username)
+
+	users, err = userRepo.FindByUsernamePrefix(ctx, "ryan_", 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(users.User))
+	require.Equal(t, "ryan_x", users.User[0].Username)
+
+	users, err = userRepo.FindByUsernamePrefix(ctx, "ryan", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(users.User))
+}
+
+func TestAuditTrail(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	ctx = audit.WithRequestID(audit.WithActor(ctx, "admin"), "req-1")
+
+	payload := &models.UserPayload{
+		Bio:      &models.UserBio{Fname: "dabi", Lname: "todoroki", Username: "dabi", Email: "dabi@gmail.com"},
+		Password: "secret",
+	}
+	id, err := userRepo.Create(ctx, payload)
+	require.NoError(t, err)
+	payload.Bio.Id = int64(id)
+	payload.Bio.Email = "touya@gmail.com"
+	payload.Password = "secret2"
+	require.NoError(t, userRepo.Update(ctx, payload))
+	require.NoError(t, userRepo.DeleteByUsername(ctx, "dabi"))
+	require.NoError(t, userRepo.DeleteByUsername(ctx, "dabi"))
+
+	events, err := auditRepo.FindAuditEvents(ctx, &models.AuditFilter{TargetUsername: "dabi", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events.Events))
+
+	deleted, updated, created := events.Events[0], events.Events[1], events.Events[2]
+	require.Equal(t, audit.ActionDelete, deleted.Action)
+	require.Equal(t, audit.ActionUpdate, updated.Action)
+	require.Equal(t, audit.ActionCreate, created.Action)
+	require.Equal(t, "admin", created.Actor)
+	require.Equal(t, "req-1", created.RequestId)
+	require.Equal(t, int64(id), created.TargetId)
+	require.Equal(t, "touya@gmail.com", updated.Diff["email"].After)
+	require.Equal(t, audit.Redacted, updated.Diff["password"].After)
+	require.NotContains(t, updated.Diff, "fname")
+
+	events, err = auditRepo.FindAuditEvents(ctx, &models.AuditFilter{Action: audit.ActionUpdate, BeforeId: updated.Id, Limit: 10})
+	require.NoError(t, err)
+	for _, event := range events.Events {
+		require.Less(t, event.Id, updated.Id)
+	}
+}
+
+func TestOutboxEvents(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	rows, err := testDb.QueryContext(ctx, "select event_type, payload from outbox_events where published_at is null order by id")
+	require.NoError(t, err)
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var eventType string
+		var payload []byte
+		require.NoError(t, rows.Scan(&eventType, &payload))
+		var envelope eventsv1.Envelope
+		require.NoError(t, proto.Unmarshal(payload, &envelope))
+		require.Equal(t, eventType, envelope.Type)
+		require.NotContains(t, string(payload), "oke")
+		types = append(types, eventType)
+	}
+	require.Contains(t, types, events.TypeUserCreated)
+	require.Contains(t, types, events.TypeUserUpdated)
+	require.Contains(t, types, events.TypeUserDeleted)
+}
+
+func TestUserChanges(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	changeRepo := repos.NewChangeRepository(testDb)
+	last, err := changeRepo.LatestChangeID(ctx)
+	require.NoError(t, err)
+
+	notified := make(chan struct{}, 10)
+	listenCtx, stopListening := context.WithCancel(ctx)
+	listening := make(chan error)
+	go func() {
+		listening <- changeRepo.ListenChanges(listenCtx, func() { notified <- struct{}{} })
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	payload := &models.UserPayload{
+		Bio:      &models.UserBio{Fname: "shoto", Lname: "todoroki", Username: "shoto", Email: "shoto@gmail.com"},
+		Password: "secret",
+	}
+	id, err := userRepo.Create(ctx, payload)
+	require.NoError(t, err)
+	require.NoError(t, userRepo.DeleteByUsername(ctx, "shoto"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-notified:
+		case <-ctx.Done():
+			t.Fatal("no notification received")
+		}
+	}
+	stopListening()
+	require.ErrorIs(t, <-listening, context.Canceled)
+
+	changes, err := changeRepo.FindChanges(ctx, last, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, events.TypeUserCreated, changes[0].Type)
+	require.Equal(t, events.TypeUserDeleted, changes[1].Type)
+	require.Equal(t, int64(id), changes[1].User.Id)
+	require.Equal(t, "shoto@gmail.com", changes[1].User.Email)
+	require.Less(t, changes[0].Id, changes[1].Id)
+
+	pruned, err := changeRepo.PruneChanges(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, pruned, int64(2))
+}
+
+func TestUsernameTaken(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	payload := &models.UserPayload{
+		Bio:      &models.UserBio{Fname: "izuku", Lname: "midoriya", Username: "deku", Email: "deku@gmail.com"},
+		Password: "secret",
+	}
+	_, err := userRepo.Create(ctx, payload)
+	require.NoError(t, err)
+	_, err = userRepo.Create(ctx, payload)
+	require.ErrorIs(t, err, repos.ErrUsernameTaken)
+
+	payload.Bio.Id = 1
+	require.ErrorIs(t, userRepo.Update(ctx, payload), repos.ErrUsernameTaken)
+}
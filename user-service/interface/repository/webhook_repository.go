@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/webhook"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type webhookRepository struct {
+	db *sql.DB
+	// Lease is how long claimed deliveries are left to the worker that
+	// claimed them, it has to outlast delivering a whole batch.
+	Lease time.Duration
+}
+
+// DefaultLease covers a batch of 50 deliveries timing out after 10 seconds.
+const DefaultLease = 10 * time.Minute
+
+func NewWebhookRepository(db *sql.DB) *webhookRepository {
+	return &webhookRepository{db: db, Lease: DefaultLease}
+}
+
+var ErrNoWebhookFound = errors.New("webhook does not exist")
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var hook models.Webhook
+	var eventTypes []byte
+	var createdAt, updatedAt time.Time
+	err := row.Scan(
+		&hook.Id,
+		&hook.Url,
+		&eventTypes,
+		&hook.Active,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoWebhookFound
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(eventTypes, &hook.EventTypes); err != nil {
+		return nil, err
+	}
+	hook.CreatedAt = timestamppb.New(createdAt)
+	hook.UpdatedAt = timestamppb.New(updatedAt)
+	return &hook, nil
+}
+
+func (repo *webhookRepository) CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error) {
+	statement := `insert into webhooks (url, secret, event_types, active) values ($1, $2, $3, $4)
+			returning id, url, event_types, active, created_at, updated_at`
+
+	eventTypes, err := json.Marshal(hook.GetEventTypes())
+	if err != nil {
+		return nil, err
+	}
+	created, err := scanWebhook(repo.db.QueryRowContext(ctx, statement, hook.Url, hook.Secret, eventTypes, hook.Active))
+	if err != nil {
+		return nil, err
+	}
+	created.Secret = hook.Secret
+	return created, nil
+}
+
+func (repo *webhookRepository) FindWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	statement := `select id, url, event_types, active, created_at, updated_at from webhooks order by id`
+
+	rows, err := repo.db.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := models.Webhooks{
+		Webhooks: make([]*models.Webhook, 0, 15),
+	}
+
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks.Webhooks = append(hooks.Webhooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &hooks, nil
+}
+
+func (repo *webhookRepository) FindWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	statement := `select id, url, event_types, active, created_at, updated_at from webhooks where id=$1`
+
+	return scanWebhook(repo.db.QueryRowContext(ctx, statement, id))
+}
+
+func (repo *webhookRepository) UpdateWebhook(ctx context.Context, hook *models.WebhookPayload) (*models.Webhook, error) {
+	statement := `update webhooks set
+			url=$1,
+			event_types=$2,
+			active=$3,
+			updated_at=now()
+			where id=$4
+			returning id, url, event_types, active, created_at, updated_at
+	`
+
+	eventTypes, err := json.Marshal(hook.GetEventTypes())
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhook(repo.db.QueryRowContext(ctx, statement, hook.Url, eventTypes, hook.Active, hook.Id))
+}
+
+func (repo *webhookRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	statement := "delete from webhooks where id=$1"
+
+	result, err := repo.db.ExecContext(ctx, statement, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNoWebhookFound
+	}
+	return nil
+}
+
+func (repo *webhookRepository) FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error) {
+	conditions := []string{"webhook_id=$1"}
+	args := []interface{}{filter.GetWebhookId()}
+	if filter.GetStatus() != "" {
+		args = append(args, filter.GetStatus())
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(args)))
+	}
+	if filter.GetBeforeId() > 0 {
+		args = append(args, filter.GetBeforeId())
+		conditions = append(conditions, fmt.Sprintf("id<$%d", len(args)))
+	}
+	args = append(args, filter.GetLimit())
+	statement := `select id, webhook_id, event_id, event_type, status, attempts, next_attempt_at, delivered_at, created_at
+			from webhook_deliveries where ` + strings.Join(conditions, " and ") +
+		fmt.Sprintf(" order by id desc limit $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := models.Deliveries{
+		Deliveries: make([]*models.Delivery, 0, filter.GetLimit()),
+	}
+	byID := make(map[int64]*models.Delivery, filter.GetLimit())
+	ids := make([]string, 0, filter.GetLimit())
+
+	for rows.Next() {
+		var delivery models.Delivery
+		var nextAttemptAt, createdAt time.Time
+		var deliveredAt sql.NullTime
+		err = rows.Scan(
+			&delivery.Id,
+			&delivery.WebhookId,
+			&delivery.EventId,
+			&delivery.EventType,
+			&delivery.Status,
+			&delivery.Attempts,
+			&nextAttemptAt,
+			&deliveredAt,
+			&createdAt,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if delivery.Status == webhook.StatusPending {
+			delivery.NextAttemptAt = timestamppb.New(nextAttemptAt)
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = timestamppb.New(deliveredAt.Time)
+		}
+		delivery.CreatedAt = timestamppb.New(createdAt)
+		deliveries.Deliveries = append(deliveries.Deliveries, &delivery)
+		byID[delivery.Id] = &delivery
+		ids = append(ids, fmt.Sprint(delivery.Id))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return &deliveries, nil
+	}
+
+	statement = `select delivery_id, status_code, error, duration_ms, attempted_at from webhook_delivery_attempts
+			where delivery_id in (` + strings.Join(ids, ",") + `) order by id`
+	rows, err = repo.db.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var deliveryID int64
+		var attempt models.DeliveryAttempt
+		var attemptedAt time.Time
+		err = rows.Scan(&deliveryID, &attempt.StatusCode, &attempt.Error, &attempt.DurationMs, &attemptedAt)
+		if err != nil {
+			return nil, err
+		}
+		attempt.AttemptedAt = timestamppb.New(attemptedAt)
+		byID[deliveryID].History = append(byID[deliveryID].History, &attempt)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &deliveries, nil
+}
+
+func (repo *webhookRepository) Enqueue(ctx context.Context, eventID, eventType string, payload []byte) error {
+	statement := `insert into webhook_deliveries (webhook_id, event_id, event_type, payload)
+			select id, $1::text, $2::text, $3::bytea from webhooks
+			where active and (event_types = '[]'::jsonb or event_types ? $2::text)
+			on conflict (webhook_id, event_id) do nothing
+	`
+
+	_, err := repo.db.ExecContext(ctx, statement, eventID, eventType, payload)
+	return err
+}
+
+// errLeaseLost is why the outcome of a delivery is dropped when its lease ran
+// out and another worker claimed it since.
+var errLeaseLost = errors.New("the lease on the delivery ran out")
+
+// DeliverDue claims a batch of due deliveries, delivers them outside any
+// transaction and records each outcome on its own. A claim is a lease, the
+// deliveries of a worker that died holding one are due again once it expires.
+// Outcomes of deliveries whose lease was lost are not recorded nor counted.
+func (repo *webhookRepository) DeliverDue(ctx context.Context, limit int, deliver func(ctx context.Context, d webhook.Delivery) webhook.Outcome) (int, error) {
+	due, err := repo.claim(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+	recorded := 0
+	for _, c := range due {
+		err = repo.record(ctx, c, deliver(ctx, c.Delivery))
+		if errors.Is(err, errLeaseLost) {
+			continue
+		}
+		if err != nil {
+			return recorded, err
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+// claimed is a delivery and the end of the lease it was claimed with, which
+// tells the claim apart from any later one.
+type claimed struct {
+	webhook.Delivery
+	leaseUntil time.Time
+}
+
+func (repo *webhookRepository) claim(ctx context.Context, limit int) ([]claimed, error) {
+	statement := `with due as (
+				select id from webhook_deliveries
+				where (status = 'pending' and next_attempt_at <= now())
+					or (status = 'delivering' and lease_until <= now())
+				order by next_attempt_at
+				limit $1
+				for update skip locked
+			)
+			update webhook_deliveries d set status = 'delivering', lease_until = now() + $2 * interval '1 millisecond'
+			from due, webhooks w
+			where d.id = due.id and w.id = d.webhook_id
+			returning d.id, d.webhook_id, w.url, w.secret, d.event_id, d.event_type, d.payload, d.attempts, d.lease_until
+	`
+
+	rows, err := repo.db.QueryContext(ctx, statement, limit, repo.Lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	due := make([]claimed, 0, limit)
+	for rows.Next() {
+		var c claimed
+		err = rows.Scan(&c.ID, &c.WebhookID, &c.URL, &c.Secret, &c.EventID, &c.EventType, &c.Payload, &c.Attempts, &c.leaseUntil)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// record moves the delivery on from its claim and keeps the attempt, unless
+// the lease ran out and another worker claimed the delivery since.
+func (repo *webhookRepository) record(ctx context.Context, c claimed, outcome webhook.Outcome) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deliveredAt, nextAttemptAt interface{}
+	if outcome.Status == webhook.StatusSucceeded {
+		deliveredAt = time.Now()
+	}
+	if outcome.Status == webhook.StatusPending {
+		nextAttemptAt = outcome.NextAttemptAt
+	}
+	result, err := tx.ExecContext(ctx, `update webhook_deliveries set
+			status=$1,
+			attempts=attempts+1,
+			delivered_at=$2,
+			next_attempt_at=coalesce($3, next_attempt_at),
+			lease_until=null
+			where id=$4 and status='delivering' and lease_until=$5`,
+		outcome.Status, deliveredAt, nextAttemptAt, c.ID, c.leaseUntil)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errLeaseLost
+	}
+	_, err = tx.ExecContext(ctx, `insert into webhook_delivery_attempts (delivery_id, status_code, error, duration_ms)
+			values ($1, $2, $3, $4)`,
+		c.ID, outcome.StatusCode, outcome.Error, outcome.Duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
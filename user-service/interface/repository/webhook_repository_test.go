@@ -0,0 +1,119 @@
+package repository_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	store := repos.NewWebhookRepository(testDb)
+
+	subscribed, err := store.CreateWebhook(ctx, &models.Webhook{
+		Url:        "https://example.com/created",
+		Secret:     "whsec_a",
+		EventTypes: []string{"user.created"},
+		Active:     true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"user.created"}, subscribed.EventTypes)
+	everything, err := store.CreateWebhook(ctx, &models.Webhook{Url: "https://example.com/all", Secret: "whsec_b", Active: true})
+	require.NoError(t, err)
+	_, err = store.CreateWebhook(ctx, &models.Webhook{Url: "https://example.com/off", Secret: "whsec_c"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Enqueue(ctx, "w1", "user.created", []byte(`{}`)))
+	require.NoError(t, store.Enqueue(ctx, "w1", "user.created", []byte(`{}`)))
+	require.NoError(t, store.Enqueue(ctx, "w2", "user.deleted", []byte(`{}`)))
+
+	delivered := map[string]int{}
+	n, err := store.DeliverDue(ctx, 10, func(ctx context.Context, d webhook.Delivery) webhook.Outcome {
+		delivered[d.URL]++
+		claimed, err := store.FindDeliveries(ctx, &models.DeliveryFilter{WebhookId: d.WebhookID, Status: webhook.StatusDelivering, Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, claimed.Deliveries, "the claim is committed before delivering")
+		again, err := store.DeliverDue(ctx, 10, func(ctx context.Context, d webhook.Delivery) webhook.Outcome {
+			t.Fatal("claimed deliveries are not handed out twice")
+			return webhook.Outcome{}
+		})
+		require.NoError(t, err)
+		require.Zero(t, again)
+		if d.WebhookID == everything.Id && d.EventID == "w2" {
+			return webhook.Outcome{StatusCode: http.StatusBadGateway, Error: "got an error", Status: webhook.StatusDead}
+		}
+		return webhook.Outcome{StatusCode: http.StatusOK, Status: webhook.StatusSucceeded}
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, map[string]int{"https://example.com/created": 1, "https://example.com/all": 2}, delivered)
+
+	n, err = store.DeliverDue(ctx, 10, func(ctx context.Context, d webhook.Delivery) webhook.Outcome {
+		t.Fatal("nothing should be due")
+		return webhook.Outcome{}
+	})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	dead, err := store.FindDeliveries(ctx, &models.DeliveryFilter{WebhookId: everything.Id, Status: webhook.StatusDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dead.Deliveries, 1)
+	require.Equal(t, "w2", dead.Deliveries[0].EventId)
+	require.Len(t, dead.Deliveries[0].History, 1)
+	require.Equal(t, int32(http.StatusBadGateway), dead.Deliveries[0].History[0].StatusCode)
+
+	updated, err := store.UpdateWebhook(ctx, &models.WebhookPayload{Id: subscribed.Id, Url: subscribed.Url})
+	require.NoError(t, err)
+	require.False(t, updated.Active)
+
+	require.NoError(t, store.DeleteWebhook(ctx, subscribed.Id))
+	require.ErrorIs(t, store.DeleteWebhook(ctx, subscribed.Id), repos.ErrNoWebhookFound)
+	_, err = store.FindWebhook(ctx, subscribed.Id)
+	require.ErrorIs(t, err, repos.ErrNoWebhookFound)
+}
+
+func TestWebhookDeliveryLease(t *testing.T) {
+	requirePostgres(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	store := repos.NewWebhookRepository(testDb)
+	store.Lease = 0
+
+	hook, err := store.CreateWebhook(ctx, &models.Webhook{Url: "https://example.com/lease", Secret: "whsec_d", Active: true})
+	require.NoError(t, err)
+	require.NoError(t, store.Enqueue(ctx, "w3", "user.created", []byte(`{}`)))
+
+	var attempts int
+	n, err := store.DeliverDue(ctx, 10, func(ctx context.Context, d webhook.Delivery) webhook.Outcome {
+		attempts++
+		if attempts == 1 {
+			// The first worker is taking longer than its lease, the next one
+			// takes the delivery over.
+			taken, err := store.DeliverDue(ctx, 10, func(ctx context.Context, d webhook.Delivery) webhook.Outcome {
+				attempts++
+				return webhook.Outcome{StatusCode: http.StatusOK, Status: webhook.StatusSucceeded}
+			})
+			require.NoError(t, err)
+			require.Equal(t, 1, taken)
+		}
+		return webhook.Outcome{StatusCode: http.StatusOK, Status: webhook.StatusSucceeded}
+	})
+	require.NoError(t, err)
+	require.Zero(t, n, "the outcome of a lost lease is dropped")
+	require.Equal(t, 2, attempts)
+
+	succeeded, err := store.FindDeliveries(ctx, &models.DeliveryFilter{WebhookId: hook.Id, Status: webhook.StatusSucceeded, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, succeeded.Deliveries, 1)
+	require.Len(t, succeeded.Deliveries[0].History, 1, "only the attempt of the last claim is kept")
+	require.Equal(t, int32(1), succeeded.Deliveries[0].Attempts, "only the last claim moves the delivery on")
+	require.NoError(t, store.DeleteWebhook(ctx, hook.Id))
+}
@@ -0,0 +1,75 @@
+package suggest
+
+import (
+	"context"
+	"time"
+
+	"github.com/spriigan/RPApp/events"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"golang.org/x/exp/slog"
+)
+
+// Feed carries the changes committed by every user-service process,
+// *watch.Hub in user-service.
+type Feed interface {
+	Subscribe() *watch.Subscription
+}
+
+// Run warms the index, again with backoff while that fails, then applies
+// every change of feed to it until ctx is done so writes handled by other
+// processes are found too. Losing the subscription warms the index again
+// from scratch, lookups go to the wrapped repository meanwhile. feed may be
+// nil when no other process writes users.
+func (repo *userRepository) Run(ctx context.Context, feed Feed) {
+	for ctx.Err() == nil {
+		if feed == nil {
+			repo.warm(ctx)
+			<-ctx.Done()
+			return
+		}
+		// Subscribing first, changes committed while warming are applied on
+		// top of what was loaded.
+		sub := feed.Subscribe()
+		repo.warm(ctx)
+		for change := range sub.C {
+			repo.apply(change)
+		}
+		repo.ready.Store(false)
+		if ctx.Err() == nil {
+			slog.Warn("username index lost the user change feed, warming it again", "error", sub.Err())
+		}
+		sub.Close()
+	}
+}
+
+// warm tries Warm until it succeeds or ctx is done.
+func (repo *userRepository) warm(ctx context.Context) {
+	pause := repo.Backoff
+	for {
+		attempt, cancel := context.WithTimeout(ctx, repo.WarmTimeout)
+		err := repo.Warm(attempt)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		slog.Warn("username index is not warmed, suggestions hit the database meanwhile", "error", err, "retry_in", pause)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+		if pause *= 2; pause > repo.MaxBackoff {
+			pause = repo.MaxBackoff
+		}
+	}
+}
+
+func (repo *userRepository) apply(change *models.UserChange) {
+	switch change.GetType() {
+	case events.TypeUserCreated, events.TypeUserUpdated:
+		repo.index.Put(change.GetUser())
+	case events.TypeUserDeleted:
+		repo.index.Remove(change.GetUser().GetUsername())
+	}
+}
@@ -0,0 +1,89 @@
+package suggest_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/interface/suggest"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"github.com/stretchr/testify/require"
+)
+
+// changes is the user_changes log as other processes write it.
+type changes struct {
+	mu  sync.Mutex
+	log []*models.UserChange
+}
+
+func (c *changes) record(changeType string, bio *models.UserBio) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.log = append(c.log, &models.UserChange{Id: int64(len(c.log) + 1), Type: changeType, User: bio})
+}
+
+func (c *changes) FindChanges(ctx context.Context, afterID int64, limit int) ([]*models.UserChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]*models.UserChange, 0, limit)
+	for _, change := range c.log {
+		if change.Id > afterID && len(result) < limit {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+// LatestChangeID leaves the hub at the start of the log, whenever it starts.
+func (c *changes) LatestChangeID(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (c *changes) ListenChanges(ctx context.Context, notify func()) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *changes) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := &changes{}
+	hub := watch.NewHub(log, 10, time.Millisecond, time.Hour)
+	go hub.Run(ctx)
+
+	next := new(mockUserRepo)
+	next.On("FindUsers").Return(nil, errors.New("connection refused")).Twice()
+	next.On("FindUsers").Return(&models.Users{User: []*models.UserBio{{Id: 1, Username: "ryan"}}}, nil).Once()
+	repo := suggest.NewUserRepository(next, suggest.NewIndex())
+	repo.Backoff, repo.MaxBackoff = time.Millisecond, time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		repo.Run(ctx, hub)
+		close(done)
+	}()
+
+	require.Eventually(t, repo.Ready, time.Second, time.Millisecond, "warming is tried again")
+	log.record("user.created", &models.UserBio{Id: 2, Username: "ryanpujo"})
+	log.record("user.deleted", &models.UserBio{Id: 1, Username: "ryan"})
+	require.Eventually(t, func() bool {
+		users, err := repo.FindByUsernamePrefix(ctx, "ry", 5)
+		require.NoError(t, err)
+		return len(users.User) == 1 && users.User[0].Username == "ryanpujo"
+	}, time.Second, time.Millisecond, "writes of other processes reach the index")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("index did not stop")
+	}
+	require.False(t, repo.Ready())
+	next.AssertExpectations(t)
+}
@@ -0,0 +1,176 @@
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+// Index is an in-memory prefix tree over lower-cased usernames.
+type Index struct {
+	mu    sync.RWMutex
+	root  *node
+	names map[int64]string
+}
+
+type node struct {
+	children map[rune]*node
+	users    []*models.UserBio
+}
+
+func newNode() *node {
+	return &node{children: make(map[rune]*node)}
+}
+
+func NewIndex() *Index {
+	return &Index{root: newNode(), names: make(map[int64]string)}
+}
+
+func key(username string) string {
+	return strings.ToLower(username)
+}
+
+func copyBio(bio *models.UserBio) *models.UserBio {
+	return &models.UserBio{
+		Id:       bio.GetId(),
+		Fname:    bio.GetFname(),
+		Lname:    bio.GetLname(),
+		Username: bio.GetUsername(),
+		Email:    bio.GetEmail(),
+	}
+}
+
+// Reset replaces the whole content of the index.
+func (ix *Index) Reset(users []*models.UserBio) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.root = newNode()
+	ix.names = make(map[int64]string, len(users))
+	for _, bio := range users {
+		ix.put(bio)
+	}
+}
+
+// Put inserts the user, replacing any entry previously stored under its id.
+func (ix *Index) Put(bio *models.UserBio) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.put(bio)
+}
+
+// Update replaces the entry stored under the user's id, it is a no-op for
+// users the index has never seen.
+func (ix *Index) Update(bio *models.UserBio) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, ok := ix.names[bio.GetId()]; !ok {
+		return
+	}
+	ix.put(bio)
+}
+
+func (ix *Index) Remove(username string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(username)
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.names)
+}
+
+func (ix *Index) put(bio *models.UserBio) {
+	if old, ok := ix.names[bio.GetId()]; ok {
+		ix.remove(old)
+	}
+	ix.remove(bio.GetUsername())
+
+	n := ix.root
+	for _, r := range key(bio.GetUsername()) {
+		child, ok := n.children[r]
+		if !ok {
+			child = newNode()
+			n.children[r] = child
+		}
+		n = child
+	}
+	n.users = append(n.users, copyBio(bio))
+	sort.Slice(n.users, func(i, j int) bool {
+		return n.users[i].Username < n.users[j].Username
+	})
+	ix.names[bio.GetId()] = bio.GetUsername()
+}
+
+func (ix *Index) remove(username string) {
+	path := []*node{ix.root}
+	runes := []rune(key(username))
+	n := ix.root
+	for _, r := range runes {
+		child, ok := n.children[r]
+		if !ok {
+			return
+		}
+		n = child
+		path = append(path, n)
+	}
+
+	for i, bio := range n.users {
+		if bio.Username == username {
+			delete(ix.names, bio.Id)
+			n.users = append(n.users[:i], n.users[i+1:]...)
+			break
+		}
+	}
+
+	for i := len(runes); i > 0; i-- {
+		current := path[i]
+		if len(current.users) > 0 || len(current.children) > 0 {
+			break
+		}
+		delete(path[i-1].children, runes[i-1])
+	}
+}
+
+// Search returns at most limit users whose username starts with prefix. The
+// tree is walked breadth first with children in rune order, so an exact match
+// comes first, followed by shorter usernames and then alphabetical order.
+func (ix *Index) Search(prefix string, limit int) []*models.UserBio {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	result := make([]*models.UserBio, 0, limit)
+	n := ix.root
+	for _, r := range key(prefix) {
+		child, ok := n.children[r]
+		if !ok {
+			return result
+		}
+		n = child
+	}
+
+	queue := []*node{n}
+	for len(queue) > 0 && len(result) < limit {
+		current := queue[0]
+		queue = queue[1:]
+		for _, bio := range current.users {
+			if len(result) == limit {
+				break
+			}
+			result = append(result, copyBio(bio))
+		}
+
+		runes := make([]rune, 0, len(current.children))
+		for r := range current.children {
+			runes = append(runes, r)
+		}
+		sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+		for _, r := range runes {
+			queue = append(queue, current.children[r])
+		}
+	}
+	return result
+}
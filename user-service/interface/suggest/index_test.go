@@ -0,0 +1,87 @@
+package suggest_test
+
+import (
+	"testing"
+
+	"github.com/spriigan/RPApp/interface/suggest"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+)
+
+func usernames(users []*models.UserBio) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
+
+func newIndex() *suggest.Index {
+	index := suggest.NewIndex()
+	index.Reset([]*models.UserBio{
+		{Id: 1, Username: "ryanpujo"},
+		{Id: 2, Username: "ryan"},
+		{Id: 3, Username: "Ryanp"},
+		{Id: 4, Username: "rya"},
+		{Id: 5, Username: "dabi"},
+		{Id: 6, Username: "ryanA"},
+	})
+	return index
+}
+
+func TestIndexSearch(t *testing.T) {
+	testTable := map[string]struct {
+		prefix string
+		limit  int
+		expect []string
+	}{
+		"exact match first then shortest": {
+			prefix: "ryan",
+			limit:  10,
+			expect: []string{"ryan", "ryanA", "Ryanp", "ryanpujo"},
+		},
+		"case insensitive": {
+			prefix: "RYANP",
+			limit:  10,
+			expect: []string{"Ryanp", "ryanpujo"},
+		},
+		"limited": {
+			prefix: "r",
+			limit:  2,
+			expect: []string{"rya", "ryan"},
+		},
+		"no match": {
+			prefix: "x",
+			limit:  10,
+			expect: []string{},
+		},
+	}
+
+	index := newIndex()
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			actual := index.Search(v.prefix, v.limit)
+
+			require.Equal(t, v.expect, usernames(actual))
+		})
+	}
+}
+
+func TestIndexMutations(t *testing.T) {
+	index := newIndex()
+
+	index.Update(&models.UserBio{Id: 1, Username: "endeavour"})
+	require.Equal(t, []string{"endeavour"}, usernames(index.Search("end", 10)))
+	require.NotContains(t, usernames(index.Search("ryan", 10)), "ryanpujo")
+
+	index.Update(&models.UserBio{Id: 99, Username: "ghost"})
+	require.Empty(t, index.Search("ghost", 10))
+
+	index.Remove("dabi")
+	require.Empty(t, index.Search("d", 10))
+	require.Equal(t, 5, index.Len())
+
+	index.Put(&models.UserBio{Id: 7, Username: "dabi"})
+	require.Equal(t, []string{"dabi"}, usernames(index.Search("da", 10)))
+	require.Equal(t, 6, index.Len())
+}
@@ -0,0 +1,91 @@
+package suggest
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+// userRepository keeps an Index in sync with every write going through the
+// wrapped repository, and with the writes of other processes while it Runs,
+// and answers prefix lookups from it once it is warmed. Writes made in a
+// transaction reach the index when it commits, lookups in one go to the
+// wrapped repository.
+type userRepository struct {
+	repository.UserRepository
+	// WarmTimeout bounds every attempt of Run to warm the index. Backoff is
+	// the pause after the first failed one, doubled up to MaxBackoff.
+	WarmTimeout time.Duration
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	index       *Index
+	ready       atomic.Bool
+}
+
+func NewUserRepository(next repository.UserRepository, index *Index) *userRepository {
+	return &userRepository{
+		UserRepository: next,
+		WarmTimeout:    5 * time.Second,
+		Backoff:        time.Second,
+		MaxBackoff:     time.Minute,
+		index:          index,
+	}
+}
+
+// Warm loads every user into the index. Until it succeeds prefix lookups are
+// served by the wrapped repository.
+func (repo *userRepository) Warm(ctx context.Context) error {
+	users, err := repo.UserRepository.FindUsers(ctx)
+	if err != nil {
+		return err
+	}
+	repo.index.Reset(users.GetUser())
+	repo.ready.Store(true)
+	return nil
+}
+
+// Ready tells whether prefix lookups are answered from the index.
+func (repo *userRepository) Ready() bool {
+	return repo.ready.Load()
+}
+
+func (repo *userRepository) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	id, err := repo.UserRepository.Create(ctx, user)
+	if err != nil {
+		return 0, err
+	}
+	bio := copyBio(user.GetBio())
+	bio.Id = int64(id)
+	repos.AfterCommit(ctx, func() { repo.index.Put(bio) })
+	return id, nil
+}
+
+func (repo *userRepository) Update(ctx context.Context, user *models.UserPayload) error {
+	err := repo.UserRepository.Update(ctx, user)
+	if err != nil {
+		return err
+	}
+	bio := copyBio(user.GetBio())
+	repos.AfterCommit(ctx, func() { repo.index.Update(bio) })
+	return nil
+}
+
+func (repo *userRepository) DeleteByUsername(ctx context.Context, username string) error {
+	err := repo.UserRepository.DeleteByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	repos.AfterCommit(ctx, func() { repo.index.Remove(username) })
+	return nil
+}
+
+func (repo *userRepository) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	if !repo.ready.Load() || repos.InTx(ctx) {
+		return repo.UserRepository.FindByUsernamePrefix(ctx, prefix, limit)
+	}
+	return &models.Users{User: repo.index.Search(prefix, limit)}, nil
+}
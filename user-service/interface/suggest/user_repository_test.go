@@ -0,0 +1,154 @@
+package suggest_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spriigan/RPApp/interface/cache"
+	repos "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/interface/repository/repositorytest"
+	"github.com/spriigan/RPApp/interface/suggest"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockUserRepo struct {
+	mock.Mock
+}
+
+func (in *mockUserRepo) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	args := in.Called(user)
+	return args.Int(0), args.Error(1)
+}
+
+func (in *mockUserRepo) FindUsers(ctx context.Context) (*models.Users, error) {
+	args := in.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Users), args.Error(1)
+}
+
+func (in *mockUserRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	args := in.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (in *mockUserRepo) DeleteByUsername(ctx context.Context, username string) error {
+	args := in.Called(username)
+	return args.Error(0)
+}
+
+func (in *mockUserRepo) Update(ctx context.Context, user *models.UserPayload) error {
+	args := in.Called(user)
+	return args.Error(0)
+}
+
+func (in *mockUserRepo) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	args := in.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Users), args.Error(1)
+}
+
+func TestUserRepository(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	next := new(mockUserRepo)
+	repo := suggest.NewUserRepository(next, suggest.NewIndex())
+
+	next.On("FindByUsernamePrefix", "ry", 5).Return(&models.Users{}, nil).Once()
+	_, err := repo.FindByUsernamePrefix(ctx, "ry", 5)
+	require.NoError(t, err)
+
+	next.On("FindUsers").Return(nil, errors.New("got an error")).Once()
+	require.Error(t, repo.Warm(ctx))
+
+	next.On("FindUsers").Return(&models.Users{User: []*models.UserBio{{Id: 1, Username: "ryan"}}}, nil).Once()
+	require.NoError(t, repo.Warm(ctx))
+
+	payload := &models.UserPayload{Bio: &models.UserBio{Username: "ryanpujo"}}
+	next.On("Create", payload).Return(2, nil).Once()
+	_, err = repo.Create(ctx, payload)
+	require.NoError(t, err)
+
+	next.On("DeleteByUsername", "ryan").Return(nil).Once()
+	require.NoError(t, repo.DeleteByUsername(ctx, "ryan"))
+
+	next.On("DeleteByUsername", "ryanpujo").Return(errors.New("got an error")).Once()
+	require.Error(t, repo.DeleteByUsername(ctx, "ryanpujo"))
+
+	users, err := repo.FindByUsernamePrefix(ctx, "ry", 5)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ryanpujo"}, usernames(users.User))
+	require.Equal(t, int64(2), users.User[0].Id)
+	next.AssertExpectations(t)
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	testTable := map[string]func() repository.UserRepository{
+		"memory": func() repository.UserRepository {
+			return repos.NewMemoryUserRepository()
+		},
+		// As the registry stacks them with the cache on.
+		"cached": func() repository.UserRepository {
+			metrics := cache.NewMetrics(prometheus.NewRegistry(), "test")
+			lru := cache.NewLRU(10, time.Minute, time.Minute, time.Now, metrics)
+			return cache.NewUserRepository(repos.NewMemoryUserRepository(), lru, metrics)
+		},
+	}
+
+	for name, next := range testTable {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.Run(t, func(t *testing.T) repository.UserRepository {
+				users := suggest.NewUserRepository(next(), suggest.NewIndex())
+				require.NoError(t, users.Warm(context.Background()))
+				return users
+			})
+		})
+	}
+}
+
+func TestWithinTx(t *testing.T) {
+	ctx := context.Background()
+	db, err := repos.OpenSQLite(ctx, filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	users := suggest.NewUserRepository(repos.NewSQLiteUserRepository(db), suggest.NewIndex())
+	require.NoError(t, users.Warm(ctx))
+	tx := repos.NewSQLiteTransactor(db)
+
+	failed := errors.New("got an error")
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}}); err != nil {
+			return err
+		}
+		found, err := users.FindByUsernamePrefix(ctx, "ry", 5)
+		require.NoError(t, err)
+		require.Equal(t, []string{"ryan"}, usernames(found.User), "a transaction sees its own writes")
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	found, err := users.FindByUsernamePrefix(ctx, "ry", 5)
+	require.NoError(t, err)
+	require.Empty(t, found.User, "what a transaction rolled back is not indexed")
+
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+		return err
+	})
+	require.NoError(t, err)
+	found, err = users.FindByUsernamePrefix(ctx, "ry", 5)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ryan"}, usernames(found.User))
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/platform/logs"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// incoming puts the request id sent by the caller in ctx, or a new one when it
+// sent none or a malformed one, and returns it to the caller in the headers.
+func incoming(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(audit.RequestIDKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !logs.ValidRequestID(id) {
+		id = logs.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(audit.RequestIDKey, id))
+	return audit.WithRequestID(ctx, id)
+}
+
+// UnaryServerInterceptor logs every call with its method, code and latency.
+// Calls failing on the server side are logged as errors and health checks only
+// at debug.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = incoming(ctx)
+		res, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, err, time.Since(start))
+		return res, err
+	}
+}
+
+// StreamServerInterceptor logs every stream once it ends.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := incoming(ss.Context())
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, err error, latency time.Duration) {
+	code := status.Code(err)
+	if _, ok := status.FromError(err); !ok {
+		code = status.FromContextError(err).Code()
+	}
+	level := slog.LevelInfo
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented:
+		level = slog.LevelError
+	case codes.OK:
+		if method == "/"+grpc_health_v1.Health_ServiceDesc.ServiceName+"/Check" {
+			level = slog.LevelDebug
+		}
+	default:
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("grpc.method", method),
+		slog.String("grpc.code", code.String()),
+		slog.Duration("latency", latency),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "rpc", attrs...)
+}
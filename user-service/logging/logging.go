@@ -0,0 +1,15 @@
+package logging
+
+import (
+	"io"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/platform/logs"
+	"golang.org/x/exp/slog"
+)
+
+// New builds a logger writing format to w whose records carry the request id
+// kept by the audit package.
+func New(w io.Writer, format string) *slog.Logger {
+	return logs.New(w, format, audit.RequestIDFrom)
+}
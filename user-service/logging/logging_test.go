@@ -0,0 +1,139 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/spriigan/RPApp/audit"
+	"github.com/spriigan/RPApp/logging"
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func records(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var all []map[string]interface{}
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var record map[string]interface{}
+		require.NoError(t, dec.Decode(&record))
+		all = append(all, record)
+	}
+	return all
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	testTable := map[string]struct {
+		method   string
+		md       metadata.MD
+		err      error
+		level    string
+		code     string
+		assertID func(t *testing.T, id string)
+	}{
+		"forwarded request id": {
+			method: "/user.UserService/FindUsers",
+			md:     metadata.Pairs(audit.RequestIDKey, "req-1"),
+			level:  "INFO",
+			code:   "OK",
+			assertID: func(t *testing.T, id string) {
+				require.Equal(t, "req-1", id)
+			},
+		},
+		"generated request id": {
+			method: "/user.UserService/FindByUsername",
+			md:     metadata.Pairs(audit.RequestIDKey, "forged\nline"),
+			err:    status.Error(codes.NotFound, "no user found"),
+			level:  "WARN",
+			code:   "NotFound",
+			assertID: func(t *testing.T, id string) {
+				require.Len(t, id, 32)
+			},
+		},
+		"server error": {
+			method: "/user.UserService/RegisterUser",
+			err:    status.Error(codes.Internal, "boom"),
+			level:  "ERROR",
+			code:   "Internal",
+			assertID: func(t *testing.T, id string) {
+				require.Len(t, id, 32)
+			},
+		},
+		"cancelled by the caller": {
+			method: "/user.UserService/FindUsers",
+			err:    context.Canceled,
+			level:  "WARN",
+			code:   "Canceled",
+			assertID: func(t *testing.T, id string) {
+				require.NotEmpty(t, id)
+			},
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			interceptor := logging.UnaryServerInterceptor(logging.New(&out, logs.JSON))
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			var seen string
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				seen = audit.RequestIDFrom(ctx)
+				return nil, tc.err
+			})
+			require.Equal(t, tc.err, err)
+			tc.assertID(t, seen)
+
+			logged := records(t, &out)
+			require.Len(t, logged, 1)
+			require.Equal(t, "rpc", logged[0]["msg"])
+			require.Equal(t, tc.level, logged[0]["level"])
+			require.Equal(t, tc.method, logged[0]["grpc.method"])
+			require.Equal(t, tc.code, logged[0]["grpc.code"])
+			require.Equal(t, seen, logged[0]["request_id"])
+		})
+	}
+}
+
+func TestHealthChecksAreDebug(t *testing.T) {
+	var out bytes.Buffer
+	interceptor := logging.UnaryServerInterceptor(logging.New(&out, logs.JSON))
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+	require.Empty(t, out.String())
+
+	logs.Level.Set(slog.LevelDebug)
+	defer logs.Level.Set(slog.LevelInfo)
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+	require.Equal(t, "DEBUG", records(t, &out)[0]["level"])
+}
+
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s stream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var out bytes.Buffer
+	interceptor := logging.StreamServerInterceptor(logging.New(&out, logs.JSON))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(audit.RequestIDKey, "req-1"))
+	err := interceptor(nil, stream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/user.UserWatchService/WatchUsers"},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			require.Equal(t, "req-1", audit.RequestIDFrom(ss.Context()))
+			return nil
+		})
+	require.NoError(t, err)
+	logged := records(t, &out)
+	require.Len(t, logged, 1)
+	require.Equal(t, "req-1", logged[0]["request_id"])
+}
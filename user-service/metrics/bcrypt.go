@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Bcrypt times password hashing, it is by design the slowest part of creating
+// and updating users.
+type Bcrypt struct {
+	duration prometheus.Histogram
+}
+
+func NewBcrypt(reg prometheus.Registerer, namespace string) *Bcrypt {
+	m := &Bcrypt{
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "bcrypt",
+			Name:      "hash_duration_seconds",
+			Help:      "Time taken to hash a password.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 10),
+		}),
+	}
+	reg.MustRegister(m.duration)
+	return m
+}
+
+// Time wraps hash so every call is observed.
+func (m *Bcrypt) Time(hash func(password []byte) ([]byte, error)) func(password []byte) ([]byte, error) {
+	return func(password []byte) ([]byte, error) {
+		start := time.Now()
+		defer func() { m.duration.Observe(time.Since(start).Seconds()) }()
+		return hash(password)
+	}
+}
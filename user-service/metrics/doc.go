@@ -0,0 +1,14 @@
+// Package metrics exposes user-service to Prometheus, under the user_service
+// namespace and the naming of platform/metrics.
+//
+// The metrics are
+//
+//	user_service_grpc_server_handled_total{grpc_service,grpc_method,grpc_code}
+//	user_service_grpc_server_handling_seconds{grpc_service,grpc_method}
+//	user_service_bcrypt_hash_duration_seconds
+//	user_service_build_info{version,revision,go_version}
+//
+// Streams are counted and timed when they end. The connection pool keeps the
+// stock go_sql_*{db_name="users"} names of client_golang, among them
+// go_sql_open_connections, go_sql_in_use_connections and go_sql_wait_count_total.
+package metrics
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+	"google.golang.org/grpc"
+)
+
+// GRPCServer records the rate, errors and duration of calls per method.
+type GRPCServer struct {
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+}
+
+func NewGRPCServer(reg prometheus.Registerer, namespace string) *GRPCServer {
+	m := &GRPCServer{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_server",
+			Name:      "handled_total",
+			Help:      "Calls completed by the server, by method and status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_server",
+			Name:      "handling_seconds",
+			Help:      "Time taken to handle calls, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+	reg.MustRegister(m.handled, m.handling)
+	return m
+}
+
+func (m *GRPCServer) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	m.observe(info.FullMethod, err, time.Since(start))
+	return res, err
+}
+
+func (m *GRPCServer) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observe(info.FullMethod, err, time.Since(start))
+	return err
+}
+
+func (m *GRPCServer) observe(fullMethod string, err error, elapsed time.Duration) {
+	service, method := platformmetrics.SplitMethod(fullMethod)
+	m.handled.WithLabelValues(service, method, platformmetrics.Code(err).String()).Inc()
+	m.handling.WithLabelValues(service, method).Observe(elapsed.Seconds())
+}
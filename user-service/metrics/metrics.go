@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+)
+
+const Namespace = "user_service"
+
+// Metrics holds every collector of the service in its own registry.
+type Metrics struct {
+	Registry *prometheus.Registry
+	GRPC     *GRPCServer
+	Bcrypt   *Bcrypt
+}
+
+// New registers the runtime, process and build info collectors along with the
+// service metrics under namespace.
+func New(namespace string) *Metrics {
+	reg := platformmetrics.NewRegistry(namespace)
+	return &Metrics{
+		Registry: reg,
+		GRPC:     NewGRPCServer(reg, namespace),
+		Bcrypt:   NewBcrypt(reg, namespace),
+	}
+}
+
+// WatchDB reports the stats of the connection pool of db, name tells pools
+// apart.
+func (m *Metrics) WatchDB(db *sql.DB, name string) {
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
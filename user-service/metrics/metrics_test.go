@@ -0,0 +1,93 @@
+package metrics_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spriigan/RPApp/metrics"
+	platformmetrics "github.com/spriigan/RPApp/platform/metrics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCServer(t *testing.T) {
+	m := metrics.New("test")
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/FindByUsername"}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "user", nil }
+	notFound := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no user found")
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := m.GRPC.UnaryServerInterceptor(context.Background(), nil, info, ok)
+		require.NoError(t, err)
+	}
+	_, err := m.GRPC.UnaryServerInterceptor(context.Background(), nil, info, notFound)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	err = m.GRPC.StreamServerInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/user.UserWatchService/WatchUsers"},
+		func(srv interface{}, stream grpc.ServerStream) error { return context.Canceled })
+	require.Error(t, err)
+
+	expected := `
+# HELP test_grpc_server_handled_total Calls completed by the server, by method and status code.
+# TYPE test_grpc_server_handled_total counter
+test_grpc_server_handled_total{grpc_code="Canceled",grpc_method="WatchUsers",grpc_service="user.UserWatchService"} 1
+test_grpc_server_handled_total{grpc_code="NotFound",grpc_method="FindByUsername",grpc_service="user.UserService"} 1
+test_grpc_server_handled_total{grpc_code="OK",grpc_method="FindByUsername",grpc_service="user.UserService"} 2
+`
+	require.NoError(t, testutil.GatherAndCompare(m.Registry, strings.NewReader(expected), "test_grpc_server_handled_total"))
+	count, err := testutil.GatherAndCount(m.Registry, "test_grpc_server_handling_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestBcrypt(t *testing.T) {
+	m := metrics.New("test")
+	hash := m.Bcrypt.Time(func(password []byte) ([]byte, error) {
+		return nil, errors.New("too long")
+	})
+	_, err := hash([]byte("secret"))
+	require.Error(t, err)
+
+	body := scrape(t, m)
+	require.Contains(t, body, "test_bcrypt_hash_duration_seconds_count 1")
+}
+
+func TestHandler(t *testing.T) {
+	m := metrics.New("test")
+	db := sql.OpenDB(connector{})
+	defer db.Close()
+	m.WatchDB(db, "users")
+
+	body := scrape(t, m)
+	require.Contains(t, body, "test_build_info{")
+	require.Contains(t, body, "go_goroutines")
+	require.Contains(t, body, `go_sql_open_connections{db_name="users"} 0`)
+	require.Contains(t, body, `go_sql_in_use_connections{db_name="users"} 0`)
+	require.Contains(t, body, `go_sql_wait_count_total{db_name="users"} 0`)
+}
+
+// connector never connects, the pool stats are there without a database.
+type connector struct{}
+
+func (connector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("no database")
+}
+
+func (connector) Driver() driver.Driver { return nil }
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	platformmetrics.Handler(m.Registry).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
@@ -0,0 +1,308 @@
+// Package migrate keeps the postgres schema in step with the binary. A
+// migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, each applied in a transaction of its own and
+// recorded in schema_migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockID names the advisory lock held while migrating, instances starting
+// together wait for each other instead of applying the same migration twice.
+const lockID int64 = 0x75736572736d6967
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is a migration as recorded in the database. Migrations applied by a
+// newer binary are listed as Unknown with only their version and name.
+type State struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool
+}
+
+// Step is a migration to apply, or to roll back.
+type Step struct {
+	Migration
+	Rollback bool
+}
+
+// Embedded returns the migrations built into the binary.
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Load reads the migrations at the root of fsys sorted by version. Every
+// version needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, file := range names {
+		base, direction := strings.TrimSuffix(file, ".sql"), ""
+		switch ext := path.Ext(base); ext {
+		case ".up", ".down":
+			base, direction = strings.TrimSuffix(base, ext), ext
+		default:
+			return nil, fmt.Errorf("migration %s: name must end in .up.sql or .down.sql", file)
+		}
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version and an underscore", file)
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, name)
+		}
+		if direction == ".up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Plan lists the steps bringing a database with the applied versions to target:
+// the pending migrations up to it are applied in order and the applied ones
+// above it rolled back newest first. Target is a known or applied version, or 0
+// to roll everything back.
+func Plan(migrations []Migration, applied []int64, target int64) ([]Step, error) {
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	done := make(map[int64]bool, len(applied))
+	var down []int64
+	for _, version := range applied {
+		done[version] = true
+		if version > target {
+			down = append(down, version)
+		}
+	}
+	if _, ok := known[target]; !ok && !done[target] && target != 0 {
+		return nil, fmt.Errorf("there is no migration %d", target)
+	}
+
+	var steps []Step
+	sort.Slice(down, func(i, j int) bool { return down[i] > down[j] })
+	for _, version := range down {
+		m, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d was applied by a newer binary and can not be rolled back by this one", version)
+		}
+		steps = append(steps, Step{Migration: m, Rollback: true})
+	}
+	for _, m := range migrations {
+		if m.Version <= target && !done[m.Version] {
+			steps = append(steps, Step{Migration: m})
+		}
+	}
+	return steps, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies every pending migration. Migrations of a newer binary are left in
+// place.
+func (m *Migrator) Up(ctx context.Context) ([]Step, error) {
+	return m.migrate(ctx, func(applied []int64) int64 {
+		var latest int64
+		if len(m.migrations) > 0 {
+			latest = m.migrations[len(m.migrations)-1].Version
+		}
+		if len(applied) > 0 && applied[len(applied)-1] > latest {
+			latest = applied[len(applied)-1]
+		}
+		return latest
+	})
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) ([]Step, error) {
+	return m.migrate(ctx, func(applied []int64) int64 {
+		if len(applied) < 2 {
+			return 0
+		}
+		return applied[len(applied)-2]
+	})
+}
+
+// To applies or rolls back migrations until version is the latest applied.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Step, error) {
+	return m.migrate(ctx, func(applied []int64) int64 {
+		return version
+	})
+}
+
+// Status lists the migrations of the binary along with those only the database
+// knows about, sorted by version.
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass('public.schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	recorded := make(map[int64]State)
+	if exists {
+		rows, err := m.db.QueryContext(ctx, "SELECT version, name, applied_at FROM public.schema_migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s := State{Applied: true, Unknown: true}
+			if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+				return nil, err
+			}
+			recorded[s.Version] = s
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]State, 0, len(m.migrations)+len(recorded))
+	for _, migration := range m.migrations {
+		s := State{Migration: migration}
+		if r, ok := recorded[migration.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			delete(recorded, migration.Version)
+		}
+		states = append(states, s)
+	}
+	for _, s := range recorded {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// migrate runs the plan to the version target picks from the applied ones,
+// sorted, while holding the advisory lock.
+func (m *Migrator) migrate(ctx context.Context, target func(applied []int64) int64) (steps []Step, err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// The lock goes with the session, unlock even when ctx is done.
+		if _, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS public.schema_migrations (
+  version bigint NOT NULL PRIMARY KEY,
+  name text NOT NULL,
+  applied_at timestamp with time zone NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	steps, err = Plan(m.migrations, applied, target(applied))
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if err = run(ctx, conn, step); err != nil {
+			return steps[:i], err
+		}
+	}
+	return steps, nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) ([]int64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM public.schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var applied []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied = append(applied, version)
+	}
+	return applied, rows.Err()
+}
+
+func run(ctx context.Context, conn *sql.Conn, step Step) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record, args := step.Up, "INSERT INTO public.schema_migrations (version, name) VALUES ($1, $2)", []interface{}{step.Version, step.Name}
+	if step.Rollback {
+		script, record, args = step.Down, "DELETE FROM public.schema_migrations WHERE version = $1", []interface{}{step.Version}
+	}
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", step.Version, step.Name, err)
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
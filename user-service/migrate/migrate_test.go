@@ -0,0 +1,142 @@
+package migrate_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/spriigan/RPApp/migrate"
+	"github.com/stretchr/testify/require"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func TestLoad(t *testing.T) {
+	testTable := map[string]struct {
+		fsys   fstest.MapFS
+		expect []migrate.Migration
+		err    string
+	}{
+		"sorted by version": {
+			fsys: fstest.MapFS{
+				"0010_add_index.up.sql":      file("CREATE INDEX"),
+				"0010_add_index.down.sql":    file("DROP INDEX"),
+				"0002_create_users.up.sql":   file("CREATE TABLE"),
+				"0002_create_users.down.sql": file("DROP TABLE"),
+			},
+			expect: []migrate.Migration{
+				{Version: 2, Name: "create_users", Up: "CREATE TABLE", Down: "DROP TABLE"},
+				{Version: 10, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+			},
+		},
+		"missing down": {
+			fsys: fstest.MapFS{"0001_create_users.up.sql": file("CREATE TABLE")},
+			err:  "migration 1_create_users needs both an up and a down file",
+		},
+		"no direction": {
+			fsys: fstest.MapFS{"0001_create_users.sql": file("CREATE TABLE")},
+			err:  "migration 0001_create_users.sql: name must end in .up.sql or .down.sql",
+		},
+		"no version": {
+			fsys: fstest.MapFS{"create_users.up.sql": file("CREATE TABLE")},
+			err:  "migration create_users.up.sql: name must start with a positive version and an underscore",
+		},
+		"names disagree": {
+			fsys: fstest.MapFS{
+				"0001_create_users.up.sql":  file("CREATE TABLE"),
+				"0001_create_user.down.sql": file("DROP TABLE"),
+			},
+			err: "migration 1 is named both",
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			migrations, err := migrate.Load(tc.fsys)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, migrations)
+		})
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := migrate.Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		require.Equal(t, int64(i+1), m.Version, "versions are numbered without gaps")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []migrate.Migration{
+		{Version: 1, Name: "create_users"},
+		{Version: 2, Name: "create_audit_events"},
+		{Version: 3, Name: "create_outbox_events"},
+	}
+	up := func(m migrate.Migration) migrate.Step { return migrate.Step{Migration: m} }
+	down := func(m migrate.Migration) migrate.Step { return migrate.Step{Migration: m, Rollback: true} }
+
+	testTable := map[string]struct {
+		applied []int64
+		target  int64
+		expect  []migrate.Step
+		err     string
+	}{
+		"fresh database": {
+			target: 3,
+			expect: []migrate.Step{up(migrations[0]), up(migrations[1]), up(migrations[2])},
+		},
+		"up to date": {
+			applied: []int64{1, 2, 3},
+			target:  3,
+		},
+		"pending below the latest applied": {
+			applied: []int64{1, 3},
+			target:  3,
+			expect:  []migrate.Step{up(migrations[1])},
+		},
+		"roll back newest first": {
+			applied: []int64{1, 2, 3},
+			target:  1,
+			expect:  []migrate.Step{down(migrations[2]), down(migrations[1])},
+		},
+		"roll back everything": {
+			applied: []int64{1, 2},
+			target:  0,
+			expect:  []migrate.Step{down(migrations[1]), down(migrations[0])},
+		},
+		"newer migrations are kept": {
+			applied: []int64{1, 2, 4},
+			target:  4,
+			expect:  []migrate.Step{up(migrations[2])},
+		},
+		"newer migrations can not be rolled back": {
+			applied: []int64{1, 2, 3, 4},
+			target:  2,
+			err:     "migration 4 was applied by a newer binary",
+		},
+		"unknown target": {
+			applied: []int64{1},
+			target:  7,
+			err:     "there is no migration 7",
+		},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			steps, err := migrate.Plan(migrations, tc.applied, tc.target)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, steps)
+		})
+	}
+}
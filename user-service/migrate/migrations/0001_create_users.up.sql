@@ -1,8 +1,10 @@
-CREATE TABLE public.users (
+-- Databases created from the former sql/user.sql already have the schema of
+-- the first migrations, IF NOT EXISTS lets them adopt it as is.
+CREATE TABLE IF NOT EXISTS public.users (
   id bigserial NOT NULL PRIMARY KEY,
   first_name character varying(25),
   last_name character varying(25),
   username character varying(25) NOT NULL UNIQUE,
   password character varying(255),
   email character varying(255)
-);
\ No newline at end of file
+);
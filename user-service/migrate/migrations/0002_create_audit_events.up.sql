@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS public.audit_events (
+  id bigserial NOT NULL PRIMARY KEY,
+  actor character varying(255) NOT NULL,
+  action character varying(25) NOT NULL,
+  target_id bigint NOT NULL,
+  target_username character varying(25) NOT NULL,
+  request_id character varying(64) NOT NULL DEFAULT '',
+  diff jsonb NOT NULL DEFAULT '{}',
+  created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON public.audit_events (actor);
+CREATE INDEX IF NOT EXISTS audit_events_target_username_idx ON public.audit_events (target_username);
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON public.audit_events (created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS public.outbox_events (
+  id bigserial NOT NULL PRIMARY KEY,
+  event_id character varying(36) NOT NULL UNIQUE,
+  event_type character varying(64) NOT NULL,
+  payload bytea NOT NULL,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  published_at timestamp with time zone,
+  attempts integer NOT NULL DEFAULT 0,
+  last_error text
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON public.outbox_events (id) WHERE published_at IS NULL;
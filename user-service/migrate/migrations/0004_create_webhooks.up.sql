@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS public.webhooks (
+  id bigserial NOT NULL PRIMARY KEY,
+  url text NOT NULL,
+  secret character varying(64) NOT NULL,
+  event_types jsonb NOT NULL DEFAULT '[]',
+  active boolean NOT NULL DEFAULT true,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+  id bigserial NOT NULL PRIMARY KEY,
+  webhook_id bigint NOT NULL REFERENCES public.webhooks (id) ON DELETE CASCADE,
+  event_id character varying(36) NOT NULL,
+  event_type character varying(64) NOT NULL,
+  payload bytea NOT NULL,
+  status character varying(16) NOT NULL DEFAULT 'pending',
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+  delivered_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON public.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS public.webhook_delivery_attempts (
+  id bigserial NOT NULL PRIMARY KEY,
+  delivery_id bigint NOT NULL REFERENCES public.webhook_deliveries (id) ON DELETE CASCADE,
+  status_code integer NOT NULL DEFAULT 0,
+  error text NOT NULL DEFAULT '',
+  duration_ms bigint NOT NULL DEFAULT 0,
+  attempted_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id_idx ON public.webhook_delivery_attempts (delivery_id);
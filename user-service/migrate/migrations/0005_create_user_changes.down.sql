@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS users_changes_trigger ON public.users;
+DROP FUNCTION IF EXISTS public.record_user_change();
+DROP TABLE IF EXISTS public.user_changes;
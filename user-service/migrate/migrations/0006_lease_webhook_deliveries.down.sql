@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.webhook_deliveries_lease_idx;
+UPDATE public.webhook_deliveries SET status = 'pending' WHERE status = 'delivering';
+ALTER TABLE public.webhook_deliveries DROP COLUMN IF EXISTS lease_until;
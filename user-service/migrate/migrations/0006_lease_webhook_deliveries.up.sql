@@ -0,0 +1,3 @@
+ALTER TABLE public.webhook_deliveries ADD COLUMN IF NOT EXISTS lease_until timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_lease_idx ON public.webhook_deliveries (lease_until) WHERE status = 'delivering';
@@ -0,0 +1,22 @@
+CREATE OR REPLACE FUNCTION public.record_user_change() RETURNS trigger AS $$
+DECLARE
+  change_id bigint;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    INSERT INTO public.user_changes (change_type, user_id, first_name, last_name, username, email)
+      VALUES ('user.deleted', OLD.id, OLD.first_name, OLD.last_name, OLD.username, OLD.email)
+      RETURNING id INTO change_id;
+  ELSE
+    INSERT INTO public.user_changes (change_type, user_id, first_name, last_name, username, email)
+      VALUES (CASE TG_OP WHEN 'INSERT' THEN 'user.created' ELSE 'user.updated' END,
+        NEW.id, NEW.first_name, NEW.last_name, NEW.username, NEW.email)
+      RETURNING id INTO change_id;
+  END IF;
+  PERFORM pg_notify('user_changes', change_id::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_changes_trigger ON public.users;
+CREATE TRIGGER users_changes_trigger AFTER INSERT OR UPDATE OR DELETE ON public.users
+  FOR EACH ROW EXECUTE FUNCTION public.record_user_change();
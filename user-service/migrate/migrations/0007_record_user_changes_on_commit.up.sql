@@ -0,0 +1,28 @@
+-- Changes are recorded as their transaction commits, one transaction at a
+-- time, so that ids are handed out in commit order. A reader that has seen a
+-- change has then seen every change with a smaller id and following ids is
+-- enough not to miss any.
+CREATE OR REPLACE FUNCTION public.record_user_change() RETURNS trigger AS $$
+DECLARE
+  change_id bigint;
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('public.user_changes'));
+  IF TG_OP = 'DELETE' THEN
+    INSERT INTO public.user_changes (change_type, user_id, first_name, last_name, username, email)
+      VALUES ('user.deleted', OLD.id, OLD.first_name, OLD.last_name, OLD.username, OLD.email)
+      RETURNING id INTO change_id;
+  ELSE
+    INSERT INTO public.user_changes (change_type, user_id, first_name, last_name, username, email)
+      VALUES (CASE TG_OP WHEN 'INSERT' THEN 'user.created' ELSE 'user.updated' END,
+        NEW.id, NEW.first_name, NEW.last_name, NEW.username, NEW.email)
+      RETURNING id INTO change_id;
+  END IF;
+  PERFORM pg_notify('user_changes', change_id::text);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_changes_trigger ON public.users;
+CREATE CONSTRAINT TRIGGER users_changes_trigger AFTER INSERT OR UPDATE OR DELETE ON public.users
+  DEFERRABLE INITIALLY DEFERRED
+  FOR EACH ROW EXECUTE FUNCTION public.record_user_change();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.outbox_events_pending_idx;
+ALTER TABLE public.outbox_events DROP COLUMN IF EXISTS dead_at;
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON public.outbox_events (id) WHERE published_at IS NULL;
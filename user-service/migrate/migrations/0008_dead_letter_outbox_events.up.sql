@@ -0,0 +1,6 @@
+-- Events that failed to publish too many times are set aside so the ones
+-- behind them keep flowing.
+ALTER TABLE public.outbox_events ADD COLUMN IF NOT EXISTS dead_at timestamp with time zone;
+
+DROP INDEX IF EXISTS public.outbox_events_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON public.outbox_events (id) WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,28 @@
+package outbox
+
+import "context"
+
+// Fanout publishes every message to all its publishers in order. When one of
+// them fails the relay retries the message on every publisher, so each must
+// tolerate duplicates.
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, msg Message) error {
+	for _, p := range f {
+		if err := p.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every publisher and returns the first error met.
+func (f Fanout) Close() error {
+	var first error
+	for _, p := range f {
+		if err := p.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,26 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spriigan/RPApp/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanout(t *testing.T) {
+	first, second := outbox.NewMemoryPublisher(), outbox.NewMemoryPublisher()
+	fanout := outbox.Fanout{first, second}
+	msg := outbox.Message{ID: "e1", Type: "user.created"}
+
+	require.NoError(t, fanout.Publish(context.Background(), msg))
+	require.Equal(t, []outbox.Message{msg}, first.Messages())
+	require.Equal(t, []outbox.Message{msg}, second.Messages())
+
+	second.FailWith(errors.New("got an error"))
+	require.Error(t, fanout.Publish(context.Background(), msg))
+	require.Len(t, first.Messages(), 2)
+	require.Len(t, second.Messages(), 1)
+	require.NoError(t, fanout.Close())
+}
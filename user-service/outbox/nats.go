@@ -0,0 +1,38 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NatsPublisher publishes every message on "<prefix>.<type>", e.g.
+// "events.v1.user.created". The message id is sent as Nats-Msg-Id so JetStream
+// streams can drop the duplicates a relay retry may produce.
+type NatsPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNatsPublisher(url, prefix string, opts ...nats.Option) (*NatsPublisher, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &NatsPublisher{conn: conn, prefix: prefix}, nil
+}
+
+func (p *NatsPublisher) Publish(ctx context.Context, msg Message) error {
+	natsMsg := nats.NewMsg(p.prefix + "." + msg.Type)
+	natsMsg.Header.Set(nats.MsgIdHdr, msg.ID)
+	natsMsg.Header.Set("Event-Type", msg.Type)
+	natsMsg.Data = msg.Payload
+	if err := p.conn.PublishMsg(natsMsg); err != nil {
+		return err
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *NatsPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,16 @@
+package outbox
+
+import "context"
+
+// Message is an event taken from the outbox table. Payload holds a serialized
+// user.events.v1.Envelope.
+type Message struct {
+	ID      string
+	Type    string
+	Payload []byte
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
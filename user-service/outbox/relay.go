@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"context"
+	"golang.org/x/exp/slog"
+	"time"
+)
+
+// Store keeps the messages to relay. Subscribers get every message at least
+// once: one published right before its transaction fails to commit goes out
+// again.
+type Store interface {
+	// Dispatch hands up to limit pending messages, oldest first, to publish and
+	// marks the ones it accepted as published. It stops at the first failure so
+	// events keep their order, a message that keeps failing is dead-lettered
+	// and skipped from then on. Only one relay dispatches at a time, the
+	// others hand nothing over.
+	Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, msg Message) error) (int, error)
+}
+
+type Relay struct {
+	store     Store
+	publisher Publisher
+	interval  time.Duration
+	batch     int
+}
+
+func NewRelay(store Store, publisher Publisher, interval time.Duration, batch int) *Relay {
+	return &Relay{store: store, publisher: publisher, interval: interval, batch: batch}
+}
+
+// Flush publishes pending messages until the outbox is drained or a publish
+// fails.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := r.store.Dispatch(ctx, r.batch, r.publisher.Publish)
+		total += n
+		if err != nil || n < r.batch {
+			return total, err
+		}
+	}
+}
+
+// Run flushes the outbox every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("outbox relay failed to publish", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	pending []outbox.Message
+}
+
+func (s *memoryStore) Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, msg outbox.Message) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	published := 0
+	for published < limit && len(s.pending) > 0 {
+		if err := publish(ctx, s.pending[0]); err != nil {
+			return published, err
+		}
+		s.pending = s.pending[1:]
+		published++
+	}
+	return published, nil
+}
+
+func (s *memoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func newStore(n int) *memoryStore {
+	store := &memoryStore{}
+	for i := 0; i < n; i++ {
+		store.pending = append(store.pending, outbox.Message{ID: fmt.Sprint(i), Type: "user.created"})
+	}
+	return store
+}
+
+func TestFlush(t *testing.T) {
+	testTable := map[string]struct {
+		pending int
+		arrange func(t *testing.T, publisher *outbox.MemoryPublisher)
+		assert  func(t *testing.T, published int, err error, store *memoryStore, publisher *outbox.MemoryPublisher)
+	}{
+		"drains every batch in order": {
+			pending: 7,
+			arrange: func(t *testing.T, publisher *outbox.MemoryPublisher) {},
+			assert: func(t *testing.T, published int, err error, store *memoryStore, publisher *outbox.MemoryPublisher) {
+				require.NoError(t, err)
+				require.Equal(t, 7, published)
+				require.Zero(t, store.Len())
+				for i, msg := range publisher.Messages() {
+					require.Equal(t, fmt.Sprint(i), msg.ID)
+				}
+			},
+		},
+		"empty outbox": {
+			arrange: func(t *testing.T, publisher *outbox.MemoryPublisher) {},
+			assert: func(t *testing.T, published int, err error, store *memoryStore, publisher *outbox.MemoryPublisher) {
+				require.NoError(t, err)
+				require.Zero(t, published)
+			},
+		},
+		"publisher is down": {
+			pending: 3,
+			arrange: func(t *testing.T, publisher *outbox.MemoryPublisher) {
+				publisher.FailWith(errors.New("got an error"))
+			},
+			assert: func(t *testing.T, published int, err error, store *memoryStore, publisher *outbox.MemoryPublisher) {
+				require.Error(t, err)
+				require.Zero(t, published)
+				require.Equal(t, 3, store.Len())
+				require.Empty(t, publisher.Messages())
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			store := newStore(v.pending)
+			publisher := outbox.NewMemoryPublisher()
+			v.arrange(t, publisher)
+			relay := outbox.NewRelay(store, publisher, time.Millisecond, 3)
+
+			published, err := relay.Flush(context.Background())
+
+			v.assert(t, published, err, store, publisher)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := newStore(2)
+	publisher := outbox.NewMemoryPublisher()
+	publisher.FailWith(errors.New("got an error"))
+	relay := outbox.NewRelay(store, publisher, time.Millisecond, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	publisher.FailWith(nil)
+	require.Eventually(t, func() bool { return store.Len() == 0 }, time.Second, time.Millisecond)
+	require.Len(t, publisher.Messages(), 2)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop")
+	}
+}
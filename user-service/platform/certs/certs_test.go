@@ -0,0 +1,53 @@
+package certs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/platform/certs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDev(t *testing.T) {
+	dir := t.TempDir()
+	server, err := certs.Dev(dir, "user-service", []string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	client, err := certs.Dev(dir, "broker", nil)
+	require.NoError(t, err)
+
+	serverCA, err := os.ReadFile(server.CA)
+	require.NoError(t, err)
+	clientCA, err := os.ReadFile(client.CA)
+	require.NoError(t, err)
+	require.Equal(t, serverCA, clientCA, "services sharing a directory share the CA")
+	require.FileExists(t, filepath.Join(dir, "broker-key.pem"))
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	files, err := certs.Dev(dir, "user-service", nil)
+	require.NoError(t, err)
+	reloader, err := certs.NewReloader(files.Cert, files.Key, files.CA)
+	require.NoError(t, err)
+	before := reloader.Certificate()
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	// Issuing again rotates the certificate in place.
+	time.Sleep(10 * time.Millisecond)
+	_, err = certs.Dev(dir, "user-service", nil)
+	require.NoError(t, err)
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.NotEqual(t, before.Certificate[0], reloader.Certificate().Certificate[0])
+
+	require.NoError(t, os.WriteFile(files.Cert, []byte("garbage"), 0o600))
+	_, err = reloader.Reload()
+	require.Error(t, err)
+	require.NotNil(t, reloader.Certificate(), "a broken rotation keeps the last good certificate")
+}
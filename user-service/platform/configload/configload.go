@@ -0,0 +1,338 @@
+// Package configload fills the Config struct of a service from a YAML file,
+// the environment and the command line, in that order. Fields say where they
+// come from in their tags:
+//
+//	Port int `yaml:"port" env:"PORT" flag:"port" usage:"port to listen on"`
+//
+// A secret tag has the field redacted by Write, secret:"dsn" only redacts the
+// password of a connection string.
+package configload
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Options are the command line switches that are about loading the
+// configuration rather than part of it.
+type Options struct {
+	File  string
+	Print bool
+}
+
+// field is a leaf of the configuration tree together with the places it can
+// be set from.
+type field struct {
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret string
+	value  reflect.Value
+}
+
+func (f field) sources() string {
+	var sources []string
+	if f.env != "" {
+		sources = append(sources, f.env)
+	}
+	if f.flag != "" {
+		sources = append(sources, "--"+f.flag)
+	}
+	sources = append(sources, f.path+" in the config file")
+	return strings.Join(sources, ", ")
+}
+
+func fields(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		if sf.Type.Kind() == reflect.Struct {
+			out = append(out, fields(v.Field(i), path+".")...)
+			continue
+		}
+		out = append(out, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			flag:   sf.Tag.Get("flag"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret"),
+			value:  v.Field(i),
+		})
+	}
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, use a value like 500ms, 10s or 1m", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		items := reflect.MakeMap(v.Type())
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			i := strings.LastIndex(item, "=")
+			if i < 0 {
+				return fmt.Errorf("%q is not a key=value pair", item)
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := set(value, strings.TrimSpace(item[i+1:])); err != nil {
+				return err
+			}
+			items.SetMapIndex(reflect.ValueOf(strings.TrimSpace(item[:i])), value)
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	case v.Kind() == reflect.Map:
+		items := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			items = append(items, iter.Key().String()+"="+format(iter.Value()))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// flagValue lets a configuration field be set straight from a flag.
+type flagValue struct {
+	field
+}
+
+func (f flagValue) String() string {
+	if !f.value.IsValid() {
+		return ""
+	}
+	return format(f.value)
+}
+
+func (f flagValue) Set(raw string) error {
+	return set(f.value, raw)
+}
+
+func (f flagValue) IsBoolFlag() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the config
+// file, then the environment, then the flags given on the command line. Later
+// sources win. name is the program name -h prints.
+func Load(name string, cfg interface{}, args []string, lookupEnv func(string) (string, bool)) (Options, error) {
+	var opts Options
+	root := reflect.ValueOf(cfg).Elem()
+
+	// Flags are parsed into a scratch copy first because the config file they
+	// point at has to be read before them.
+	scratch := reflect.New(root.Type()).Elem()
+	scratch.Set(root)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.File, "config", "", "path to a YAML config file (CONFIG_FILE)")
+	fs.BoolVar(&opts.Print, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, f := range fields(scratch, "") {
+		if f.flag != "" {
+			usage := f.usage
+			if f.env != "" {
+				usage = fmt.Sprintf("%s (%s)", usage, f.env)
+			}
+			fs.Var(flagValue{f}, f.flag, usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if opts.File == "" {
+		opts.File, _ = lookupEnv("CONFIG_FILE")
+	}
+
+	if opts.File != "" {
+		if err := readFile(opts.File, cfg); err != nil {
+			return opts, err
+		}
+	}
+
+	var problems ValidationError
+	for _, f := range fields(root, "") {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := lookupEnv(f.env); ok && raw != "" {
+			if err := set(f.value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s: %s", f.env, f.path, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return opts, problems
+	}
+
+	flags := map[string]field{}
+	for _, f := range fields(scratch, "") {
+		flags[f.flag] = f
+	}
+	byPath := map[string]field{}
+	for _, f := range fields(root, "") {
+		byPath[f.path] = f
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := flags[fl.Name]; ok && f.flag != "" {
+			byPath[f.path].value.Set(f.value)
+		}
+	})
+	return opts, nil
+}
+
+func readFile(path string, cfg interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Describe names every way the setting at path, like "grpc.port", can be
+// changed, so validation errors tell the operator where to fix them.
+func Describe(cfg interface{}, path string) string {
+	for _, f := range fields(reflect.ValueOf(cfg).Elem(), "") {
+		if f.path == path {
+			return f.sources()
+		}
+	}
+	return path
+}
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+func redact(kind, value string) string {
+	if value == "" {
+		return value
+	}
+	if kind != "dsn" {
+		return redacted
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return value
+	}
+	return dsnPassword.ReplaceAllString(value, "${1}"+redacted)
+}
+
+// Write prints cfg as YAML with every secret replaced.
+func Write(w io.Writer, cfg interface{}) error {
+	copied := reflect.New(reflect.TypeOf(cfg).Elem())
+	copied.Elem().Set(reflect.ValueOf(cfg).Elem())
+	for _, f := range fields(copied.Elem(), "") {
+		if f.secret == "" {
+			continue
+		}
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(redact(f.secret, f.value.String()))
+		case reflect.Slice:
+			// The copy shares the items with cfg, they are replaced rather
+			// than written over.
+			items := make([]string, f.value.Len())
+			for i := range items {
+				items[i] = redact(f.secret, f.value.Index(i).String())
+			}
+			f.value.Set(reflect.ValueOf(items))
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(copied.Interface()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ValidationError lists every problem found in the configuration at once.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
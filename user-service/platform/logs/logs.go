@@ -0,0 +1,117 @@
+// Package logs holds what the loggers of both services share: the level that
+// can be changed at runtime, the handler changing it and request ids.
+package logs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// Formats are how records are written, json for machines and text for people
+// reading a terminal.
+const (
+	JSON = "json"
+	Text = "text"
+)
+
+// Level is the minimum level of the loggers built by New, it can be changed
+// while the service runs.
+var Level = new(slog.LevelVar)
+
+// New builds a logger writing format to w. Records logged with a context carry
+// the trace id found in it and the request id requestID finds there.
+func New(w io.Writer, format string, requestID func(context.Context) string) *slog.Logger {
+	opts := slog.HandlerOptions{Level: Level}
+	var handler slog.Handler = opts.NewJSONHandler(w)
+	if format == Text {
+		handler = opts.NewTextHandler(w)
+	}
+	return slog.New(contextHandler{handler, requestID})
+}
+
+type contextHandler struct {
+	slog.Handler
+	requestID func(context.Context) string
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := h.requestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+			r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs), h.requestID}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name), h.requestID}
+}
+
+// NewRequestID returns 16 random bytes in hex.
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// ValidRequestID accepts ids of up to 128 letters, digits and -_.: so callers
+// cannot forge log lines or blow up their size.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// LevelHandler reports Level on GET and sets it on PUT, from a body or a
+// level query parameter like "debug" or "warn".
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			value := r.URL.Query().Get("level")
+			if value == "" {
+				body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				value = strings.TrimSpace(string(body))
+			}
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(value)); err != nil {
+				http.Error(w, fmt.Sprintf("unknown level %q, use debug, info, warn or error", value), http.StatusBadRequest)
+				return
+			}
+			Level.Set(level)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, Level.Level())
+	})
+}
@@ -0,0 +1,86 @@
+package logs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spriigan/RPApp/platform/logs"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+type requestIDKey struct{}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger := logs.New(&out, logs.JSON, requestID)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = context.WithValue(ctx, requestIDKey{}, "req-1")
+
+	logger.With("service", "test").InfoCtx(ctx, "request", "status", 200)
+	logger.Debug("hidden")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	require.Equal(t, "request", record["msg"])
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, "test", record["service"])
+	require.Equal(t, float64(200), record["status"])
+	require.Equal(t, "req-1", record["request_id"])
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+}
+
+func TestValidRequestID(t *testing.T) {
+	testTable := map[string]struct {
+		id    string
+		valid bool
+	}{
+		"uuid":        {id: "0b8a6f3e-62a4-4d8e-9a55-3b4c1d2e5f60", valid: true},
+		"generated":   {id: logs.NewRequestID(), valid: true},
+		"empty":       {id: "", valid: false},
+		"too long":    {id: strings.Repeat("a", 129), valid: false},
+		"line break":  {id: "req-1\n{\"level\":\"ERROR\"}", valid: false},
+		"with spaces": {id: "req 1", valid: false},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.valid, logs.ValidRequestID(tc.id))
+		})
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	defer logs.Level.Set(slog.LevelInfo)
+	handler := logs.LevelHandler()
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := send(http.MethodPut, "/debug/log-level", "debug")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, slog.LevelDebug, logs.Level.Level())
+
+	rr = send(http.MethodPut, "/debug/log-level?level=warn", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "WARN\n", send(http.MethodGet, "/debug/log-level", "").Body.String())
+
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPut, "/debug/log-level", "verbose").Code)
+	require.Equal(t, slog.LevelWarn, logs.Level.Level())
+	require.Equal(t, http.StatusMethodNotAllowed, send(http.MethodPost, "/debug/log-level", "").Code)
+}
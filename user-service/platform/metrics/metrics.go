@@ -0,0 +1,84 @@
+// Package metrics holds what the Prometheus metrics of both services share:
+// the registry, its handler and the labels of gRPC calls.
+//
+// Every metric the services define is named <namespace>_<subsystem>_<name>_<unit>:
+//
+//   - namespace is the service, broker or user_service, so both can be
+//     scraped into one Prometheus without relabeling
+//   - subsystem is what is measured, like http, grpc_client or build
+//   - units are base units, durations are histograms in _seconds and counters
+//     end in _total
+//   - label values are bounded, gRPC methods are split into grpc_service and
+//     grpc_method and grpc_code is the name of the status code
+//
+// The collectors of client_golang keep their stock go_* and process_* names
+// so existing dashboards work.
+package metrics
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewRegistry registers the runtime, process and build info collectors, the
+// build info under namespace.
+func NewRegistry(namespace string) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		BuildInfo(namespace),
+	)
+	return reg
+}
+
+// Handler serves the metrics of reg in the Prometheus text format.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// BuildInfo is a gauge always at 1 whose labels tell which build is running.
+func BuildInfo(namespace string) prometheus.Collector {
+	version, revision, goVersion := "unknown", "unknown", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		goVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "build",
+		Name:        "info",
+		Help:        "Build of the running binary, always 1.",
+		ConstLabels: prometheus.Labels{"version": version, "revision": revision, "go_version": goVersion},
+	}, func() float64 { return 1 })
+}
+
+// SplitMethod splits "/package.Service/Method" into its service and method.
+func SplitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// Code is the status of a call, context errors from interceptors that gave up
+// before reaching the wire become Canceled and DeadlineExceeded.
+func Code(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
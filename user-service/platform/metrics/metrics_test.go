@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/RPApp/platform/metrics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSplitMethod(t *testing.T) {
+	testTable := map[string]struct {
+		fullMethod string
+		service    string
+		method     string
+	}{
+		"full":      {fullMethod: "/user.UserService/FindByUsername", service: "user.UserService", method: "FindByUsername"},
+		"no prefix": {fullMethod: "user.UserService/Create", service: "user.UserService", method: "Create"},
+		"malformed": {fullMethod: "Create", service: "unknown", method: "Create"},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			service, method := metrics.SplitMethod(tc.fullMethod)
+			require.Equal(t, tc.service, service)
+			require.Equal(t, tc.method, method)
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	testTable := map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"ok":              {code: codes.OK},
+		"status":          {err: status.Error(codes.NotFound, "no user found"), code: codes.NotFound},
+		"canceled":        {err: context.Canceled, code: codes.Canceled},
+		"deadline":        {err: context.DeadlineExceeded, code: codes.DeadlineExceeded},
+		"any other error": {err: errors.New("connection reset"), code: codes.Unknown},
+	}
+
+	for name, tc := range testTable {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.code, metrics.Code(tc.err))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	metrics.Handler(metrics.NewRegistry("test")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "test_build_info{")
+	require.Contains(t, rec.Body.String(), "go_goroutines")
+}
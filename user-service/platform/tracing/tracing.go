@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Exporters are where spans can be sent, "none" keeps propagating the trace
+// context of callers without recording spans.
+const (
+	None   = "none"
+	Stdout = "stdout"
+	OTLP   = "otlp"
+)
+
+// NewExporter builds the exporter named kind, stdout writes pretty printed
+// spans to w for local debugging and otlp posts them to the collector at the
+// base URL endpoint.
+func NewExporter(kind, endpoint string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case Stdout:
+		return stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	case OTLP:
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("otlp endpoint: %w", err)
+		}
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(u.Host),
+			otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+			otlptracehttp.WithTimeout(10 * time.Second),
+		}
+		if u.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	}
+	return nil, fmt.Errorf("unknown span exporter %q", kind)
+}
+
+// Setup installs the W3C trace context propagator and, unless exporter is
+// nil, a tracer provider batching spans of service to it. New traces are
+// sampled at ratio, traces started by a caller follow its decision. Shutdown
+// flushes the spans still buffered.
+func Setup(service string, exporter sdktrace.SpanExporter, ratio float64) (shutdown func(context.Context) error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if exporter == nil {
+		return func(context.Context) error { return nil }
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown
+}
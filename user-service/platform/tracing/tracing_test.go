@@ -0,0 +1,101 @@
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spriigan/RPApp/platform/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/collector/v1/traces", r.URL.Path)
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, req))
+		received <- req
+	}))
+	defer collector.Close()
+
+	exporter, err := tracing.NewExporter(tracing.OTLP, collector.URL+"/collector/", io.Discard)
+	require.NoError(t, err)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child", trace.WithAttributes(attribute.String("db.statement", "select 1")))
+	child.SetStatus(codes.Error, "connection reset")
+	child.End()
+
+	req := <-received
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	require.Equal(t, "child", span.Name)
+	traceID, parentID := parent.SpanContext().TraceID(), parent.SpanContext().SpanID()
+	require.Equal(t, traceID[:], span.TraceId)
+	require.Equal(t, parentID[:], span.ParentSpanId)
+	require.Equal(t, "connection reset", span.Status.Message)
+	require.Equal(t, "db.statement", span.Attributes[0].Key)
+	require.Equal(t, "select 1", span.Attributes[0].Value.GetStringValue())
+
+	parent.End()
+	<-received
+	require.NoError(t, provider.Shutdown(context.Background()))
+}
+
+func TestOTLPExporterRejected(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	exporter, err := tracing.NewExporter(tracing.OTLP, collector.URL, io.Discard)
+	require.NoError(t, err)
+	spans := tracetest.SpanStubs{{Name: "span"}}.Snapshots()
+	err = exporter.ExportSpans(context.Background(), spans)
+	require.ErrorContains(t, err, "400 Bad Request")
+}
+
+// keeper keeps the spans past shutdown, which the in-memory exporter forgets.
+type keeper struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keeper) Shutdown(context.Context) error { return nil }
+
+func TestSetup(t *testing.T) {
+	exporter := keeper{tracetest.NewInMemoryExporter()}
+	shutdown := tracing.Setup("user-service", exporter, 0)
+
+	tracer := otel.Tracer("test")
+	_, span := tracer.Start(context.Background(), "unsampled")
+	span.End()
+
+	// A caller that sampled its trace is followed even though the ratio is 0.
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+	_, span = tracer.Start(ctx, "sampled")
+	span.End()
+
+	require.NoError(t, shutdown(context.Background()))
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "sampled", spans[0].Name)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	require.Contains(t, spans[0].Resource.Attributes(), attribute.String("service.name", "user-service"))
+}
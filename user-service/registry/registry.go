@@ -1,34 +1,237 @@
 package registry
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
+	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spriigan/RPApp/config"
+	"github.com/spriigan/RPApp/health"
+	"github.com/spriigan/RPApp/interface/cache"
 	"github.com/spriigan/RPApp/interface/controller"
 	repo "github.com/spriigan/RPApp/interface/repository"
+	"github.com/spriigan/RPApp/interface/suggest"
+	"github.com/spriigan/RPApp/metrics"
+	"github.com/spriigan/RPApp/outbox"
 	"github.com/spriigan/RPApp/usecases/interactor"
 	"github.com/spriigan/RPApp/usecases/repository"
 	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"github.com/spriigan/RPApp/webhook"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Registry interface {
 	NewUserServer() models.UserServiceServer
+	NewAuditServer() models.AuditServiceServer
+	NewWebhookServer() models.WebhookServiceServer
+	NewWatchServer() models.UserWatchServiceServer
+	WatchHub() *watch.Hub
+	RunSuggestIndex(ctx context.Context)
+	HealthMonitor() *health.Monitor
+	Postgres() bool
+	RegisterServices(s grpc.ServiceRegistrar)
+	NewOutboxRelay(publisher outbox.Publisher) *outbox.Relay
+	NewWebhookEnqueuer() outbox.Publisher
+	NewWebhookWorker() *webhook.Worker
 }
 
 type registry struct {
 	DB *sql.DB
+	// Replicas are read replicas of DB users are looked up on.
+	Replicas []*sql.DB
+	// Pool is where users are read and written with the pgxpool driver.
+	Pool    *pgxpool.Pool
+	Config  config.Config
+	Metrics *metrics.Metrics
+	hub     *watch.Hub
+	health  *health.Monitor
+	// users and index are built once by userRepository.
+	once  sync.Once
+	users repository.UserRepository
+	index index
 }
 
-func New(db *sql.DB) *registry {
-	return &registry{DB: db}
+// index is the username index in front of the users, see suggest.
+type index interface {
+	Run(ctx context.Context, feed suggest.Feed)
+	Ready() bool
+}
+
+// New wires the services on top of db, nil with the memory backend. Only
+// postgres backs more than UserService, the audit, webhook and watch services
+// are left out with the other backends.
+func New(db *sql.DB, cfg config.Config, m *metrics.Metrics) *registry {
+	r := &registry{
+		DB:      db,
+		Config:  cfg,
+		Metrics: m,
+	}
+	if !r.Postgres() {
+		var pinger health.Pinger = inProcess{}
+		if db != nil {
+			pinger = db
+		}
+		r.health = health.NewMonitor(pinger, cfg.Health.Interval, cfg.Health.Timeout, models.UserService_ServiceDesc.ServiceName)
+		return r
+	}
+	r.hub = watch.NewHub(repo.NewChangeRepository(db), cfg.Watch.Buffer, cfg.Watch.PollInterval, cfg.Watch.Retention)
+	r.health = health.NewMonitor(db, cfg.Health.Interval, cfg.Health.Timeout,
+		models.UserService_ServiceDesc.ServiceName,
+		models.AuditService_ServiceDesc.ServiceName,
+		models.WebhookService_ServiceDesc.ServiceName,
+		models.UserWatchService_ServiceDesc.ServiceName,
+	)
+	return r
+}
+
+// inProcess stands in for the database of the memory backend, there is
+// nothing to lose touch with.
+type inProcess struct{}
+
+func (inProcess) PingContext(ctx context.Context) error {
+	return nil
+}
+
+// Postgres tells whether users are stored in postgres, which the outbox, the
+// webhooks and the watch hub need.
+func (r *registry) Postgres() bool {
+	return r.Config.Database.Backend() == config.Postgres
 }
 
 func (r *registry) NewUserServer() models.UserServiceServer {
 	return controller.NewUserServer(r.newUserInteractor())
 }
 
-func (r *registry) newUserRepository() repository.UserRepository {
-	return repo.NewUserRepository(r.DB)
+func (r *registry) NewAuditServer() models.AuditServiceServer {
+	return controller.NewAuditServer(r.newAuditInteractor())
+}
+
+func (r *registry) NewWebhookServer() models.WebhookServiceServer {
+	return controller.NewWebhookServer(interactor.NewWebhookInteractor(repo.NewWebhookRepository(r.DB)))
+}
+
+func (r *registry) NewWatchServer() models.UserWatchServiceServer {
+	return controller.NewWatchServer(interactor.NewWatchInteractor(repo.NewChangeRepository(r.DB), r.hub))
+}
+
+// WatchHub is shared by every WatchUsers stream, it has to be running for them
+// to receive changes. It is nil unless users are stored in postgres.
+func (r *registry) WatchHub() *watch.Hub {
+	return r.hub
+}
+
+// HealthMonitor backs grpc.health.v1, it has to be running for the services
+// to be reported SERVING.
+func (r *registry) HealthMonitor() *health.Monitor {
+	return r.health
+}
+
+func (r *registry) RegisterServices(s grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(s, r.health.Server())
+	models.RegisterUserServiceServer(s, r.NewUserServer())
+	if !r.Postgres() {
+		return
+	}
+	models.RegisterAuditServiceServer(s, r.NewAuditServer())
+	models.RegisterWebhookServiceServer(s, r.NewWebhookServer())
+	models.RegisterUserWatchServiceServer(s, r.NewWatchServer())
+}
+
+// userRepository is built once, the username index in front of it has to be
+// shared by every server and kept running by RunSuggestIndex.
+func (r *registry) userRepository() repository.UserRepository {
+	r.once.Do(r.newUserRepository)
+	return r.users
+}
+
+func (r *registry) newUserRepository() {
+	var users repository.UserRepository
+	switch r.Config.Database.Backend() {
+	case config.Memory:
+		users = repo.NewMemoryUserRepository()
+	case config.SQLite:
+		users = repo.NewSQLiteUserRepository(r.DB)
+	default:
+		users = repo.NewUserRepository(r.DB)
+		if r.Pool != nil {
+			users = repo.NewPgxUserRepository(r.Pool)
+		} else if len(r.Replicas) > 0 {
+			replicas := repo.NewReplicas(r.DB, r.Replicas, r.Config.Database.ReplicaStickiness, time.Now)
+			users = repo.NewReplicatedUserRepository(replicas)
+		}
+	}
+	if r.Config.Cache.Enabled {
+		stats := cache.NewMetrics(r.Metrics.Registry, metrics.Namespace)
+		lru := cache.NewLRU(r.Config.Cache.Size, r.Config.Cache.TTL, r.Config.Cache.NegativeTTL, time.Now, stats)
+		users = cache.NewUserRepository(users, lru, stats)
+	}
+	indexed := suggest.NewUserRepository(users, suggest.NewIndex())
+	indexed.WarmTimeout = r.Config.Database.WarmTimeout
+	r.health.Await("username index", indexed.Ready)
+	r.users, r.index = indexed, indexed
+}
+
+// RunSuggestIndex warms the username index and, with postgres, keeps it in
+// sync with the changes the watch hub sees from every process. The instance
+// reports NOT_SERVING until the index is warmed.
+func (r *registry) RunSuggestIndex(ctx context.Context) {
+	r.userRepository()
+	var feed suggest.Feed
+	if r.hub != nil {
+		feed = r.hub
+	}
+	r.index.Run(ctx, feed)
 }
+
+// newTransactor runs writes in transactions of the backend users are stored in.
+func (r *registry) newTransactor() repository.Transactor {
+	switch r.Config.Database.Backend() {
+	case config.Memory:
+		return repo.NewMemoryTransactor()
+	case config.SQLite:
+		return repo.NewSQLiteTransactor(r.DB)
+	}
+	opts := repo.TxOptions{
+		Isolation: r.Config.Database.IsolationLevel(),
+		Retries:   r.Config.Database.TxRetries,
+	}
+	if r.Pool != nil {
+		return repo.NewPgxTransactor(r.Pool, opts)
+	}
+	return repo.NewTransactor(r.DB, opts)
+}
+
 func (r *registry) newUserInteractor() interactor.UserInteractor {
-	return interactor.NewUserInteractor(r.newUserRepository())
+	users := interactor.NewUserInteractor(r.userRepository())
+	users.Tx = r.newTransactor()
+	users.Hash = r.Metrics.Bcrypt.Time(users.Hash)
+	return users
+}
+
+func (r *registry) newAuditRepository() repository.AuditRepository {
+	return repo.NewAuditRepository(r.DB)
+}
+
+func (r *registry) newAuditInteractor() interactor.AuditInteractor {
+	return interactor.NewAuditInteractor(r.newAuditRepository())
+}
+
+func (r *registry) NewOutboxRelay(publisher outbox.Publisher) *outbox.Relay {
+	return outbox.NewRelay(repo.NewOutboxRepository(r.DB, r.Config.Outbox.MaxAttempts), publisher, r.Config.Outbox.Interval, r.Config.Outbox.BatchSize)
+}
+
+func (r *registry) NewWebhookEnqueuer() outbox.Publisher {
+	return webhook.NewEnqueuer(repo.NewWebhookRepository(r.DB))
+}
+
+func (r *registry) NewWebhookWorker() *webhook.Worker {
+	client := &http.Client{Timeout: r.Config.Webhooks.RequestTimeout}
+	store := repo.NewWebhookRepository(r.DB)
+	store.Lease = time.Duration(r.Config.Webhooks.BatchSize)*r.Config.Webhooks.RequestTimeout + r.Config.Webhooks.Interval
+	return webhook.NewWorker(store, client, webhook.DefaultRetryPolicy, r.Config.Webhooks.Interval, r.Config.Webhooks.BatchSize)
 }
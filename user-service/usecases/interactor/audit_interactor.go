@@ -0,0 +1,39 @@
+package interactor
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+type AuditInteractor interface {
+	FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error)
+}
+
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+type auditInteractor struct {
+	Repo repository.AuditRepository
+}
+
+func NewAuditInteractor(repo repository.AuditRepository) *auditInteractor {
+	return &auditInteractor{Repo: repo}
+}
+
+func (in *auditInteractor) FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	if filter.Limit > MaxAuditLimit {
+		filter.Limit = MaxAuditLimit
+	}
+	events, err := in.Repo.FindAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,88 @@
+package interactor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAuditRepo struct {
+	mock.Mock
+}
+
+func (in *mockAuditRepo) FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error) {
+	args := in.Called(filter.Limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditEvents), args.Error(1)
+}
+
+var auditInteractor interactor.AuditInteractor
+var mockAudit *mockAuditRepo
+
+func TestFindAuditEvents(t *testing.T) {
+	events := &models.AuditEvents{Events: []*models.AuditEvent{{}, {}}}
+	testTable := map[string]struct {
+		limit   int32
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.AuditEvents, err error)
+	}{
+		"succes call": {
+			limit: 10,
+			arrange: func(t *testing.T) {
+				mockAudit.On("FindAuditEvents", int32(10)).Return(events, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.NoError(t, err)
+				require.Equal(t, events, actual)
+			},
+		},
+		"default limit": {
+			arrange: func(t *testing.T) {
+				mockAudit.On("FindAuditEvents", int32(interactor.DefaultAuditLimit)).Return(events, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.NoError(t, err)
+			},
+		},
+		"limit is capped": {
+			limit: 100000,
+			arrange: func(t *testing.T) {
+				mockAudit.On("FindAuditEvents", int32(interactor.MaxAuditLimit)).Return(events, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.NoError(t, err)
+			},
+		},
+		"fail call": {
+			limit: 10,
+			arrange: func(t *testing.T) {
+				mockAudit.On("FindAuditEvents", int32(10)).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.AuditEvents, err error) {
+				require.Error(t, err)
+				require.Nil(t, actual)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := auditInteractor.FindAuditEvents(ctx, &models.AuditFilter{Limit: v.limit})
+
+			v.assert(t, result, err)
+		})
+	}
+}
@@ -3,7 +3,9 @@ package interactor
 import (
 	"context"
 	"errors"
+	"strings"
 
+	"github.com/spriigan/RPApp/audit"
 	"github.com/spriigan/RPApp/usecases/repository"
 	"github.com/spriigan/RPApp/user-proto/grpc/models"
 	"golang.org/x/crypto/bcrypt"
@@ -15,22 +17,68 @@ type UserInteractor interface {
 	FindByUsername(ctx context.Context, username string) (*models.User, error)
 	DeleteByUsername(ctx context.Context, username string) error
 	Update(ctx context.Context, user *models.UserPayload) error
+	Suggest(ctx context.Context, prefix string, limit int) (*models.Users, error)
 }
 
 var ErrDuplicateKeyInDatabase = errors.New("duplicate key in database")
+var ErrEmptyPrefix = errors.New("prefix must not be empty")
+
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 50
+)
 
 type userInteractor struct {
 	Repo repository.UserRepository
+	// Tx wraps every write, by default in nothing and the repository starts
+	// the transaction itself.
+	Tx repository.Transactor
+	// Hash turns a password into what is stored, bcrypt at its default cost
+	// unless replaced.
+	Hash func(password []byte) ([]byte, error)
+	// Compare tells whether hash is of password, nil when it is. It has to
+	// agree with Hash.
+	Compare func(hash, password []byte) error
 }
 
 func NewUserInteractor(repo repository.UserRepository) *userInteractor {
-	return &userInteractor{Repo: repo}
+	return &userInteractor{Repo: repo, Tx: noTx{}, Hash: hashPassword, Compare: bcrypt.CompareHashAndPassword}
+}
+
+// noTx leaves transactions to the repository.
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func hashPassword(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+}
+
+// hashFor hashes password unless the caller already left, bcrypt ignores ctx
+// and the work would be spent for nothing.
+func (in *userInteractor) hashFor(ctx context.Context, password string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	hash, err := in.Hash([]byte(password))
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
 func (in *userInteractor) Create(ctx context.Context, user *models.UserPayload) (*models.UserBio, error) {
-	hash, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	user.Password = string(hash)
-	_, err := in.Repo.Create(ctx, user)
+	hash, err := in.hashFor(ctx, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
+	err = in.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := in.Repo.Create(ctx, user)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +102,9 @@ func (in *userInteractor) FindByUsername(ctx context.Context, username string) (
 }
 
 func (in *userInteractor) DeleteByUsername(ctx context.Context, username string) error {
-	err := in.Repo.DeleteByUsername(ctx, username)
+	err := in.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		return in.Repo.DeleteByUsername(ctx, username)
+	})
 	if err != nil {
 		return err
 	}
@@ -62,11 +112,40 @@ func (in *userInteractor) DeleteByUsername(ctx context.Context, username string)
 }
 
 func (in *userInteractor) Update(ctx context.Context, user *models.UserPayload) error {
-	hash, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	user.Password = string(hash)
-	err := in.Repo.Update(ctx, user)
+	password := []byte(user.Password)
+	hash, err := in.hashFor(ctx, user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	// The new hash never equals the stored one, only the password tells
+	// whether it changed.
+	ctx = audit.WithPasswordCheck(ctx, func(stored string) bool {
+		return in.Compare([]byte(stored), password) == nil
+	})
+	err = in.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		return in.Repo.Update(ctx, user)
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (in *userInteractor) Suggest(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return nil, ErrEmptyPrefix
+	}
+	if limit <= 0 {
+		limit = DefaultSuggestLimit
+	}
+	if limit > MaxSuggestLimit {
+		limit = MaxSuggestLimit
+	}
+	users, err := in.Repo.FindByUsernamePrefix(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -50,12 +50,25 @@ func (in *mockUserRepo) Update(ctx context.Context, user *models.UserPayload) er
 	return args.Error(0)
 }
 
+func (in *mockUserRepo) FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (*models.Users, error) {
+	args := in.Called(prefix, limit)
+	arg1 := args.Get(0)
+	if arg1 == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Users), args.Error(1)
+}
+
 var userInteractor interactor.UserInteractor
 var mockRepo *mockUserRepo
 
 func TestMain(m *testing.M) {
 	mockRepo = new(mockUserRepo)
 	userInteractor = interactor.NewUserInteractor(mockRepo)
+	mockAudit = new(mockAuditRepo)
+	auditInteractor = interactor.NewAuditInteractor(mockAudit)
+	mockWebhook = new(mockWebhookRepo)
+	webhookInteractor = interactor.NewWebhookInteractor(mockWebhook)
 	os.Exit(m.Run())
 }
 
@@ -96,6 +109,28 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateAfterCallerLeft(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := userInteractor.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Nil(t, result)
+}
+
+func TestHashFails(t *testing.T) {
+	repo := new(mockUserRepo)
+	users := interactor.NewUserInteractor(repo)
+	users.Hash = func(password []byte) ([]byte, error) { return nil, errors.New("password too long") }
+
+	_, err := users.Create(context.Background(), &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+	require.EqualError(t, err, "password too long")
+	err = users.Update(context.Background(), &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+	require.EqualError(t, err, "password too long")
+	repo.AssertNotCalled(t, "Create", mock.Anything)
+	repo.AssertNotCalled(t, "Update")
+}
+
 func TestFindUsers(t *testing.T) {
 	users := &models.Users{
 		User: []*models.UserBio{
@@ -255,3 +290,153 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestSuggest(t *testing.T) {
+	users := &models.Users{
+		User: []*models.UserBio{
+			{Username: "ryan"},
+			{Username: "ryanpujo"},
+		},
+	}
+	testTable := map[string]struct {
+		prefix  string
+		limit   int
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Users, err error)
+	}{
+		"succes call": {
+			prefix: "ry",
+			limit:  5,
+			arrange: func(t *testing.T) {
+				mockRepo.On("FindByUsernamePrefix", "ry", 5).Return(users, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.NoError(t, err)
+				require.Equal(t, users, actual)
+			},
+		},
+		"default limit": {
+			prefix: " ry ",
+			arrange: func(t *testing.T) {
+				mockRepo.On("FindByUsernamePrefix", "ry", interactor.DefaultSuggestLimit).Return(users, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.NoError(t, err)
+				require.NotNil(t, actual)
+			},
+		},
+		"limit is capped": {
+			prefix: "ry",
+			limit:  1000,
+			arrange: func(t *testing.T) {
+				mockRepo.On("FindByUsernamePrefix", "ry", interactor.MaxSuggestLimit).Return(users, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.NoError(t, err)
+				require.NotNil(t, actual)
+			},
+		},
+		"empty prefix": {
+			prefix:  "  ",
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.ErrorIs(t, err, interactor.ErrEmptyPrefix)
+				require.Nil(t, actual)
+			},
+		},
+		"fail call": {
+			prefix: "ry",
+			limit:  5,
+			arrange: func(t *testing.T) {
+				mockRepo.On("FindByUsernamePrefix", "ry", 5).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.Users, err error) {
+				require.Error(t, err)
+				require.Nil(t, actual)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := userInteractor.Suggest(ctx, v.prefix, v.limit)
+
+			v.assert(t, result, err)
+		})
+	}
+}
+
+type txKey struct{}
+
+// fakeTx marks the ctx it runs work with, and fails the commit with err.
+type fakeTx struct {
+	runs int
+	err  error
+}
+
+func (tx *fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.runs++
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		return err
+	}
+	return tx.err
+}
+
+// txRepo fails every write made outside a transaction.
+type txRepo struct {
+	mockUserRepo
+}
+
+func inTx(ctx context.Context) error {
+	if ctx.Value(txKey{}) == nil {
+		return errors.New("not in a transaction")
+	}
+	return nil
+}
+
+func (r *txRepo) Create(ctx context.Context, user *models.UserPayload) (int, error) {
+	return 1, inTx(ctx)
+}
+
+func (r *txRepo) DeleteByUsername(ctx context.Context, username string) error {
+	return inTx(ctx)
+}
+
+func (r *txRepo) Update(ctx context.Context, user *models.UserPayload) error {
+	return inTx(ctx)
+}
+
+func TestWritesWithinTx(t *testing.T) {
+	writes := map[string]func(ctx context.Context, users interactor.UserInteractor) error{
+		"create": func(ctx context.Context, users interactor.UserInteractor) error {
+			_, err := users.Create(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+			return err
+		},
+		"update": func(ctx context.Context, users interactor.UserInteractor) error {
+			return users.Update(ctx, &models.UserPayload{Bio: &models.UserBio{Username: "ryan"}})
+		},
+		"delete": func(ctx context.Context, users interactor.UserInteractor) error {
+			return users.DeleteByUsername(ctx, "ryan")
+		},
+	}
+
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			tx := new(fakeTx)
+			users := interactor.NewUserInteractor(new(txRepo))
+			users.Tx = tx
+			users.Hash = func(password []byte) ([]byte, error) { return password, nil }
+
+			require.NoError(t, write(context.Background(), users))
+			require.Equal(t, 1, tx.runs)
+
+			tx.err = errors.New("could not serialize access")
+			require.ErrorIs(t, write(context.Background(), users), tx.err)
+		})
+	}
+}
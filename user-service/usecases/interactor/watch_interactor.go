@@ -0,0 +1,73 @@
+package interactor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+)
+
+type WatchInteractor interface {
+	Watch(ctx context.Context, afterID int64, send func(*models.UserChange) error) error
+}
+
+var ErrInvalidCursor = errors.New("after_id must not be negative")
+
+const replayBatch = 100
+
+type watchInteractor struct {
+	Repo repository.ChangeRepository
+	Hub  *watch.Hub
+}
+
+func NewWatchInteractor(repo repository.ChangeRepository, hub *watch.Hub) *watchInteractor {
+	return &watchInteractor{Repo: repo, Hub: hub}
+}
+
+// Watch sends the changes recorded after afterID, then every new change as it
+// happens, until ctx is done or send fails. It subscribes before replaying so
+// nothing recorded meanwhile is lost, changes seen twice are skipped by id.
+func (in *watchInteractor) Watch(ctx context.Context, afterID int64, send func(*models.UserChange) error) error {
+	if afterID < 0 {
+		return ErrInvalidCursor
+	}
+	sub := in.Hub.Subscribe()
+	defer sub.Close()
+
+	last := afterID
+	for afterID > 0 {
+		changes, err := in.Repo.FindChanges(ctx, last, replayBatch)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err = send(change); err != nil {
+				return err
+			}
+			last = change.Id
+		}
+		if len(changes) < replayBatch {
+			break
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-sub.C:
+			if !ok {
+				return sub.Err()
+			}
+			if change.Id <= last {
+				continue
+			}
+			if err := send(change); err != nil {
+				return err
+			}
+			last = change.Id
+		}
+	}
+}
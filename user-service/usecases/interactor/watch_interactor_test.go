@@ -0,0 +1,134 @@
+package interactor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockChangeRepo struct {
+	mock.Mock
+}
+
+func (in *mockChangeRepo) FindChanges(ctx context.Context, afterID int64, limit int) ([]*models.UserChange, error) {
+	args := in.Called(afterID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.UserChange), args.Error(1)
+}
+
+func (in *mockChangeRepo) LatestChangeID(ctx context.Context) (int64, error) {
+	args := in.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (in *mockChangeRepo) ListenChanges(ctx context.Context, notify func()) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (in *mockChangeRepo) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func changes(ids ...int64) []*models.UserChange {
+	result := make([]*models.UserChange, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, &models.UserChange{Id: id})
+	}
+	return result
+}
+
+func TestWatch(t *testing.T) {
+	testTable := map[string]struct {
+		afterID int64
+		live    bool
+		arrange func(t *testing.T, repo *mockChangeRepo)
+		assert  func(t *testing.T, sent []int64, err error)
+	}{
+		"replays then streams without duplicates": {
+			afterID: 3,
+			live:    true,
+			arrange: func(t *testing.T, repo *mockChangeRepo) {
+				repo.On("FindChanges", int64(3)).Return(changes(4, 5), nil).Once()
+				repo.On("FindChanges", int64(4)).Return(changes(5, 6), nil).Once()
+				repo.On("FindChanges", int64(6)).Return(changes(), nil).Maybe()
+			},
+			assert: func(t *testing.T, sent []int64, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []int64{4, 5, 6}, sent)
+			},
+		},
+		"live only": {
+			live: true,
+			arrange: func(t *testing.T, repo *mockChangeRepo) {
+				repo.On("FindChanges", int64(4)).Return(changes(5, 6), nil).Once()
+			},
+			assert: func(t *testing.T, sent []int64, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []int64{5, 6}, sent)
+			},
+		},
+		"negative cursor": {
+			afterID: -1,
+			arrange: func(t *testing.T, repo *mockChangeRepo) {},
+			assert: func(t *testing.T, sent []int64, err error) {
+				require.ErrorIs(t, err, interactor.ErrInvalidCursor)
+			},
+		},
+		"replay fails": {
+			afterID: 3,
+			arrange: func(t *testing.T, repo *mockChangeRepo) {
+				repo.On("FindChanges", int64(3)).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, sent []int64, err error) {
+				require.Error(t, err)
+				require.Empty(t, sent)
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			repo := new(mockChangeRepo)
+			repo.On("LatestChangeID").Return(int64(4), nil)
+			v.arrange(t, repo)
+			hub := watch.NewHub(repo, 10, time.Hour, time.Hour)
+			require.NoError(t, hub.Start(context.Background()))
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			sent := make(chan int64, 10)
+			done := make(chan error)
+			go func() {
+				done <- interactor.NewWatchInteractor(repo, hub).Watch(ctx, v.afterID, func(change *models.UserChange) error {
+					sent <- change.Id
+					if change.Id == 6 {
+						cancel()
+					}
+					return nil
+				})
+			}()
+			if v.live {
+				require.Eventually(t, func() bool { return hub.Len() == 1 }, time.Second, time.Millisecond)
+				_ = hub.Flush(ctx)
+			}
+			err := <-done
+			close(sent)
+			ids := make([]int64, 0)
+			for id := range sent {
+				ids = append(ids, id)
+			}
+
+			v.assert(t, ids, err)
+		})
+	}
+}
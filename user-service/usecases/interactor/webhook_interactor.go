@@ -0,0 +1,141 @@
+package interactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+
+	"github.com/spriigan/RPApp/events"
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/webhook"
+)
+
+type WebhookInteractor interface {
+	CreateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error)
+	FindWebhooks(ctx context.Context) (*models.Webhooks, error)
+	FindWebhook(ctx context.Context, id int64) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error)
+}
+
+var (
+	ErrInvalidWebhookURL     = errors.New("webhook url must be an absolute http or https url")
+	ErrUnknownEventType      = errors.New("unknown event type")
+	ErrInvalidDeliveryStatus = errors.New("delivery status must be one of pending, delivering, succeeded or dead")
+)
+
+const (
+	DefaultDeliveryLimit = 50
+	MaxDeliveryLimit     = 200
+)
+
+type webhookInteractor struct {
+	Repo repository.WebhookRepository
+}
+
+func NewWebhookInteractor(repo repository.WebhookRepository) *webhookInteractor {
+	return &webhookInteractor{Repo: repo}
+}
+
+func validateWebhook(payload *models.WebhookPayload) error {
+	u, err := url.Parse(payload.GetUrl())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	for _, eventType := range payload.GetEventTypes() {
+		known := false
+		for _, t := range events.Types {
+			known = known || t == eventType
+		}
+		if !known {
+			return ErrUnknownEventType
+		}
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func (in *webhookInteractor) CreateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	if err := validateWebhook(payload); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	created, err := in.Repo.CreateWebhook(ctx, &models.Webhook{
+		Url:        payload.Url,
+		EventTypes: payload.EventTypes,
+		Active:     payload.Active,
+		Secret:     secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (in *webhookInteractor) FindWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	hooks, err := in.Repo.FindWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (in *webhookInteractor) FindWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	hook, err := in.Repo.FindWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (in *webhookInteractor) UpdateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	if err := validateWebhook(payload); err != nil {
+		return nil, err
+	}
+	hook, err := in.Repo.UpdateWebhook(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (in *webhookInteractor) DeleteWebhook(ctx context.Context, id int64) error {
+	err := in.Repo.DeleteWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (in *webhookInteractor) FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error) {
+	switch filter.Status {
+	case "", webhook.StatusPending, webhook.StatusDelivering, webhook.StatusSucceeded, webhook.StatusDead:
+	default:
+		return nil, ErrInvalidDeliveryStatus
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultDeliveryLimit
+	}
+	if filter.Limit > MaxDeliveryLimit {
+		filter.Limit = MaxDeliveryLimit
+	}
+	deliveries, err := in.Repo.FindDeliveries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,181 @@
+package interactor_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/usecases/interactor"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWebhookRepo struct {
+	mock.Mock
+}
+
+func (in *mockWebhookRepo) CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error) {
+	args := in.Called(hook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *mockWebhookRepo) FindWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	args := in.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhooks), args.Error(1)
+}
+
+func (in *mockWebhookRepo) FindWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	args := in.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *mockWebhookRepo) UpdateWebhook(ctx context.Context, payload *models.WebhookPayload) (*models.Webhook, error) {
+	args := in.Called(payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (in *mockWebhookRepo) DeleteWebhook(ctx context.Context, id int64) error {
+	args := in.Called(id)
+	return args.Error(0)
+}
+
+func (in *mockWebhookRepo) FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error) {
+	args := in.Called(filter.Limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Deliveries), args.Error(1)
+}
+
+var webhookInteractor interactor.WebhookInteractor
+var mockWebhook *mockWebhookRepo
+
+func TestCreateWebhook(t *testing.T) {
+	testTable := map[string]struct {
+		payload *models.WebhookPayload
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Webhook, err error)
+	}{
+		"succes call": {
+			payload: &models.WebhookPayload{Url: "https://example.com/hook", EventTypes: []string{"user.created"}, Active: true},
+			arrange: func(t *testing.T) {
+				mockWebhook.On("CreateWebhook", mock.MatchedBy(func(hook *models.Webhook) bool {
+					return hook.Url == "https://example.com/hook" && strings.HasPrefix(hook.Secret, "whsec_")
+				})).Return(&models.Webhook{Id: 1}, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.NoError(t, err)
+				require.Equal(t, int64(1), actual.Id)
+			},
+		},
+		"invalid scheme": {
+			payload: &models.WebhookPayload{Url: "ftp://example.com/hook"},
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.ErrorIs(t, err, interactor.ErrInvalidWebhookURL)
+				require.Nil(t, actual)
+			},
+		},
+		"relative url": {
+			payload: &models.WebhookPayload{Url: "/hook"},
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.ErrorIs(t, err, interactor.ErrInvalidWebhookURL)
+			},
+		},
+		"unknown event type": {
+			payload: &models.WebhookPayload{Url: "https://example.com/hook", EventTypes: []string{"user.renamed"}},
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.ErrorIs(t, err, interactor.ErrUnknownEventType)
+			},
+		},
+		"fail call": {
+			payload: &models.WebhookPayload{Url: "https://example.com/hook"},
+			arrange: func(t *testing.T) {
+				mockWebhook.On("CreateWebhook", mock.Anything).Return(nil, errors.New("got an error")).Once()
+			},
+			assert: func(t *testing.T, actual *models.Webhook, err error) {
+				require.Error(t, err)
+				require.Nil(t, actual)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookInteractor.CreateWebhook(ctx, v.payload)
+
+			v.assert(t, result, err)
+		})
+	}
+}
+
+func TestFindDeliveries(t *testing.T) {
+	deliveries := &models.Deliveries{Deliveries: []*models.Delivery{{Id: 1}}}
+	testTable := map[string]struct {
+		filter  *models.DeliveryFilter
+		arrange func(t *testing.T)
+		assert  func(t *testing.T, actual *models.Deliveries, err error)
+	}{
+		"default limit": {
+			filter: &models.DeliveryFilter{WebhookId: 1},
+			arrange: func(t *testing.T) {
+				mockWebhook.On("FindDeliveries", int32(interactor.DefaultDeliveryLimit)).Return(deliveries, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Deliveries, err error) {
+				require.NoError(t, err)
+				require.Equal(t, deliveries, actual)
+			},
+		},
+		"limit is capped": {
+			filter: &models.DeliveryFilter{WebhookId: 1, Status: "dead", Limit: 10000},
+			arrange: func(t *testing.T) {
+				mockWebhook.On("FindDeliveries", int32(interactor.MaxDeliveryLimit)).Return(deliveries, nil).Once()
+			},
+			assert: func(t *testing.T, actual *models.Deliveries, err error) {
+				require.NoError(t, err)
+			},
+		},
+		"invalid status": {
+			filter:  &models.DeliveryFilter{WebhookId: 1, Status: "lost"},
+			arrange: func(t *testing.T) {},
+			assert: func(t *testing.T, actual *models.Deliveries, err error) {
+				require.ErrorIs(t, err, interactor.ErrInvalidDeliveryStatus)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			v.arrange(t)
+
+			result, err := webhookInteractor.FindDeliveries(ctx, v.filter)
+
+			v.assert(t, result, err)
+		})
+	}
+}
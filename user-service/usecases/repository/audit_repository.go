@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+type AuditRepository interface {
+	FindAuditEvents(ctx context.Context, filter *models.AuditFilter) (*models.AuditEvents, error)
+}
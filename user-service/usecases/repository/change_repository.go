@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+type ChangeRepository interface {
+	// FindChanges lists the changes after afterID. Ids follow the order the
+	// changes were committed in, none can show up later behind afterID.
+	FindChanges(ctx context.Context, afterID int64, limit int) ([]*models.UserChange, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	// ListenChanges calls notify every time a change is recorded until ctx is
+	// done or the connection is lost.
+	ListenChanges(ctx context.Context, notify func()) error
+	PruneChanges(ctx context.Context, before time.Time) (int64, error)
+}
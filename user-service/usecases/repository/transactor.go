@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// Transactor runs work that has to commit or roll back as a whole. The
+// repositories called with the ctx fn is given take part in the transaction,
+// and a WithinTx nested in fn joins it instead of starting another.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -12,4 +12,5 @@ type UserRepository interface {
 	FindByUsername(ctx context.Context, username string) (*models.User, error)
 	DeleteByUsername(ctx context.Context, username string) error
 	Update(ctx context.Context, user *models.UserPayload) error
+	FindByUsernamePrefix(ctx context.Context, prefix string, limit int) (*models.Users, error)
 }
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error)
+	FindWebhooks(ctx context.Context) (*models.Webhooks, error)
+	FindWebhook(ctx context.Context, id int64) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *models.WebhookPayload) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	FindDeliveries(ctx context.Context, filter *models.DeliveryFilter) (*models.Deliveries, error)
+}
@@ -0,0 +1,180 @@
+// Package compat keeps the protos of user-service wire compatible with the
+// clients built on earlier versions. A schema of every message field and
+// service method is recorded, later versions may add to it but not renumber,
+// rename, retype or remove what it holds.
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type Field struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Cardinality string `json:"cardinality"`
+	// Type is the full name of the message or enum of the field, if any.
+	Type string `json:"type,omitempty"`
+}
+
+type Message struct {
+	Fields map[protoreflect.FieldNumber]Field `json:"fields"`
+	// Reserved are the numbers of removed fields, which may not be used again.
+	Reserved []protoreflect.FieldNumber `json:"reserved,omitempty"`
+}
+
+type Method struct {
+	Input           string `json:"input"`
+	Output          string `json:"output"`
+	ClientStreaming bool   `json:"client_streaming,omitempty"`
+	ServerStreaming bool   `json:"server_streaming,omitempty"`
+}
+
+// Schema is what the protos promise on the wire, messages and methods by
+// full name.
+type Schema struct {
+	Messages map[string]Message `json:"messages"`
+	Methods  map[string]Method  `json:"methods"`
+}
+
+// Describe records the messages, nested ones included, and the service
+// methods of files.
+func Describe(files ...protoreflect.FileDescriptor) Schema {
+	schema := Schema{Messages: make(map[string]Message), Methods: make(map[string]Method)}
+	for _, file := range files {
+		describeMessages(schema, file.Messages())
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				method := methods.Get(j)
+				schema.Methods[string(method.FullName())] = Method{
+					Input:           string(method.Input().FullName()),
+					Output:          string(method.Output().FullName()),
+					ClientStreaming: method.IsStreamingClient(),
+					ServerStreaming: method.IsStreamingServer(),
+				}
+			}
+		}
+	}
+	return schema
+}
+
+func describeMessages(schema Schema, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		described := Message{Fields: make(map[protoreflect.FieldNumber]Field)}
+		fields := message.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			field := fields.Get(j)
+			described.Fields[field.Number()] = Field{
+				Name:        string(field.Name()),
+				Kind:        field.Kind().String(),
+				Cardinality: field.Cardinality().String(),
+				Type:        typeName(field),
+			}
+		}
+		reserved := message.ReservedRanges()
+		for j := 0; j < reserved.Len(); j++ {
+			for n := reserved.Get(j)[0]; n < reserved.Get(j)[1]; n++ {
+				described.Reserved = append(described.Reserved, n)
+			}
+		}
+		schema.Messages[string(message.FullName())] = described
+		describeMessages(schema, message.Messages())
+	}
+}
+
+func typeName(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.Message() != nil:
+		return string(field.Message().FullName())
+	case field.Enum() != nil:
+		return string(field.Enum().FullName())
+	}
+	return ""
+}
+
+// Check lists what current breaks of old, nothing when clients built on old
+// keep working.
+func Check(old, current Schema) []string {
+	var problems []string
+	for name, message := range old.Messages {
+		now, ok := current.Messages[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("message %s was removed", name))
+			continue
+		}
+		numbers := make(map[string]protoreflect.FieldNumber, len(now.Fields))
+		for number, field := range now.Fields {
+			numbers[field.Name] = number
+		}
+		for number, field := range message.Fields {
+			moved, ok := now.Fields[number]
+			switch {
+			case !ok && numbers[field.Name] != 0:
+				problems = append(problems, fmt.Sprintf("%s.%s was renumbered from %d to %d", name, field.Name, number, numbers[field.Name]))
+			case !ok && !reserved(now, number):
+				problems = append(problems, fmt.Sprintf("%s.%s was removed without reserving %d", name, field.Name, number))
+			case ok && moved.Name != field.Name:
+				problems = append(problems, fmt.Sprintf("%s field %d was renamed from %s to %s", name, number, field.Name, moved.Name))
+			case ok && (moved.Kind != field.Kind || moved.Cardinality != field.Cardinality || moved.Type != field.Type):
+				problems = append(problems, fmt.Sprintf("%s.%s changed from %s to %s", name, field.Name, describeField(field), describeField(moved)))
+			}
+		}
+		for _, number := range message.Reserved {
+			if field, ok := now.Fields[number]; ok {
+				problems = append(problems, fmt.Sprintf("%s.%s reuses the reserved %d", name, field.Name, number))
+			}
+		}
+	}
+	for name, method := range old.Methods {
+		now, ok := current.Methods[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("method %s was removed", name))
+		case now != method:
+			problems = append(problems, fmt.Sprintf("method %s changed its signature", name))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func reserved(message Message, number protoreflect.FieldNumber) bool {
+	for _, n := range message.Reserved {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+func describeField(field Field) string {
+	if field.Type != "" {
+		return fmt.Sprintf("%s %s", field.Cardinality, field.Type)
+	}
+	return fmt.Sprintf("%s %s", field.Cardinality, field.Kind)
+}
+
+// Load reads a schema Save wrote.
+func Load(path string) (Schema, error) {
+	var schema Schema
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return schema, err
+	}
+	return schema, json.Unmarshal(data, &schema)
+}
+
+func (s Schema) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,135 @@
+package compat_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/spriigan/RPApp/user-proto/compat"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var update = flag.Bool("update", false, "record the current protos in testdata/schema.json")
+
+const recorded = "testdata/schema.json"
+
+// TestSchema fails when the protos break what was recorded of them. Additions
+// have to be recorded too, with go test ./compat -update, for later changes to
+// be held to them.
+func TestSchema(t *testing.T) {
+	current := compat.Describe(
+		models.File_user_proto,
+		models.File_audit_proto,
+		models.File_watch_proto,
+		models.File_webhook_proto,
+		eventsv1.File_events_v1_user_events_proto,
+	)
+	if *update {
+		require.NoError(t, current.Save(recorded))
+	}
+	old, err := compat.Load(recorded)
+	require.NoError(t, err)
+	require.Empty(t, compat.Check(old, current))
+	require.Equal(t, old, current, "the protos changed, record them with go test ./compat -update")
+}
+
+func TestCheck(t *testing.T) {
+	schema := func() compat.Schema {
+		return compat.Schema{
+			Messages: map[string]compat.Message{
+				"user.UserBio": {Fields: map[protoreflect.FieldNumber]compat.Field{
+					1: {Name: "Id", Kind: "int64", Cardinality: "optional"},
+					4: {Name: "Username", Kind: "string", Cardinality: "optional"},
+				}},
+				"user.Users": {Fields: map[protoreflect.FieldNumber]compat.Field{
+					1: {Name: "user", Kind: "message", Cardinality: "repeated", Type: "user.UserBio"},
+				}},
+			},
+			Methods: map[string]compat.Method{
+				"user.UserService.FindUsers": {Input: "google.protobuf.Empty", Output: "user.Users"},
+			},
+		}
+	}
+	bio := func(s compat.Schema) map[protoreflect.FieldNumber]compat.Field {
+		return s.Messages["user.UserBio"].Fields
+	}
+
+	testTable := map[string]struct {
+		change func(s *compat.Schema)
+		expect []string
+	}{
+		"unchanged": {
+			change: func(s *compat.Schema) {},
+		},
+		"field added": {
+			change: func(s *compat.Schema) {
+				bio(*s)[5] = compat.Field{Name: "Email", Kind: "string", Cardinality: "optional"}
+			},
+		},
+		"field renumbered": {
+			change: func(s *compat.Schema) {
+				bio(*s)[7] = bio(*s)[4]
+				delete(bio(*s), 4)
+			},
+			expect: []string{"user.UserBio.Username was renumbered from 4 to 7"},
+		},
+		"field removed": {
+			change: func(s *compat.Schema) {
+				delete(bio(*s), 4)
+			},
+			expect: []string{"user.UserBio.Username was removed without reserving 4"},
+		},
+		"field removed and reserved": {
+			change: func(s *compat.Schema) {
+				delete(bio(*s), 4)
+				s.Messages["user.UserBio"] = compat.Message{Fields: bio(*s), Reserved: []protoreflect.FieldNumber{4}}
+			},
+		},
+		"field renamed": {
+			change: func(s *compat.Schema) {
+				bio(*s)[4] = compat.Field{Name: "Handle", Kind: "string", Cardinality: "optional"}
+			},
+			expect: []string{"user.UserBio field 4 was renamed from Username to Handle"},
+		},
+		"field retyped": {
+			change: func(s *compat.Schema) {
+				bio(*s)[1] = compat.Field{Name: "Id", Kind: "string", Cardinality: "optional"}
+			},
+			expect: []string{"user.UserBio.Id changed from optional int64 to optional string"},
+		},
+		"message removed": {
+			change: func(s *compat.Schema) {
+				delete(s.Messages, "user.Users")
+			},
+			expect: []string{"message user.Users was removed"},
+		},
+		"method changed": {
+			change: func(s *compat.Schema) {
+				s.Methods["user.UserService.FindUsers"] = compat.Method{Input: "google.protobuf.Empty", Output: "user.Users", ServerStreaming: true}
+			},
+			expect: []string{"method user.UserService.FindUsers changed its signature"},
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			current := schema()
+			test.change(&current)
+			require.Equal(t, test.expect, compat.Check(schema(), current))
+		})
+	}
+}
+
+func TestReservedReused(t *testing.T) {
+	old := compat.Schema{Messages: map[string]compat.Message{
+		"user.User": {Fields: map[protoreflect.FieldNumber]compat.Field{}, Reserved: []protoreflect.FieldNumber{6}},
+	}}
+	current := compat.Schema{Messages: map[string]compat.Message{
+		"user.User": {Fields: map[protoreflect.FieldNumber]compat.Field{
+			6: {Name: "secret", Kind: "string", Cardinality: "optional"},
+		}},
+	}}
+	require.Equal(t, []string{"user.User.secret reuses the reserved 6"}, compat.Check(old, current))
+}
@@ -28,6 +28,7 @@ type UserServiceClient interface {
 	FindByUsername(ctx context.Context, in *Username, opts ...grpc.CallOption) (*UserBio, error)
 	DeleteByUsername(ctx context.Context, in *Username, opts ...grpc.CallOption) (*empty.Empty, error)
 	Update(ctx context.Context, in *UserPayload, opts ...grpc.CallOption) (*empty.Empty, error)
+	SuggestUsernames(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*Users, error)
 }
 
 type userServiceClient struct {
@@ -83,6 +84,15 @@ func (c *userServiceClient) Update(ctx context.Context, in *UserPayload, opts ..
 	return out, nil
 }
 
+func (c *userServiceClient) SuggestUsernames(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*Users, error) {
+	out := new(Users)
+	err := c.cc.Invoke(ctx, "/user.UserService/SuggestUsernames", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility
@@ -92,6 +102,7 @@ type UserServiceServer interface {
 	FindByUsername(context.Context, *Username) (*UserBio, error)
 	DeleteByUsername(context.Context, *Username) (*empty.Empty, error)
 	Update(context.Context, *UserPayload) (*empty.Empty, error)
+	SuggestUsernames(context.Context, *SuggestRequest) (*Users, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -114,6 +125,9 @@ func (UnimplementedUserServiceServer) DeleteByUsername(context.Context, *Usernam
 func (UnimplementedUserServiceServer) Update(context.Context, *UserPayload) (*empty.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
 }
+func (UnimplementedUserServiceServer) SuggestUsernames(context.Context, *SuggestRequest) (*Users, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SuggestUsernames not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 
 // UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -217,6 +231,24 @@ func _UserService_Update_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_SuggestUsernames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SuggestUsernames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.UserService/SuggestUsernames",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SuggestUsernames(ctx, req.(*SuggestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -244,6 +276,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Update",
 			Handler:    _UserService_Update_Handler,
 		},
+		{
+			MethodName: "SuggestUsernames",
+			Handler:    _UserService_SuggestUsernames_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
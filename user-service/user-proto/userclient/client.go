@@ -0,0 +1,179 @@
+// Package userclient is the Go client of user-service. It calls the services
+// of the generated stubs with a timeout per attempt, sends idempotent calls
+// again while user-service is unavailable, pages through listings and fails
+// with errors errors.Is matches against the sentinels of this package.
+package userclient
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Version is the version of the client and the protos it was built with,
+// the module is tagged user-proto/v<Version>.
+const Version = "1.1.0"
+
+type Options struct {
+	// Timeout bounds every attempt of a call made without a deadline.
+	Timeout time.Duration
+	// Attempts is how many times an idempotent call is sent at most, backing
+	// off exponentially from InitialBackoff up to MaxBackoff in between.
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// PageSize is how many items iterators ask for at a time.
+	PageSize int32
+}
+
+var DefaultOptions = Options{
+	Timeout:        5 * time.Second,
+	Attempts:       3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	PageSize:       50,
+}
+
+type Client struct {
+	users    models.UserServiceClient
+	audit    models.AuditServiceClient
+	webhooks models.WebhookServiceClient
+	opts     Options
+}
+
+// New calls user-service on conn, options left zero taking their default.
+// The call options given to a method apply to each of its attempts, a
+// grpc.Header ends up with the header of the last one.
+func New(conn grpc.ClientConnInterface, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = DefaultOptions.Attempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultOptions.InitialBackoff
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = DefaultOptions.MaxBackoff
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultOptions.PageSize
+	}
+	return &Client{
+		users:    models.NewUserServiceClient(conn),
+		audit:    models.NewAuditServiceClient(conn),
+		webhooks: models.NewWebhookServiceClient(conn),
+		opts:     opts,
+	}
+}
+
+// call invokes method, again after a pause when it is idempotent and
+// user-service could not be reached, until the attempts run out or ctx is
+// done.
+func (c *Client) call(ctx context.Context, method string, idempotent bool, invoke func(ctx context.Context) error) error {
+	backoff := c.opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, invoke)
+		if err == nil {
+			return nil
+		}
+		if !idempotent || attempt >= c.opts.Attempts || !retryable(err) || ctx.Err() != nil {
+			return wrap(method, err)
+		}
+		timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return wrap(method, err)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, invoke func(ctx context.Context) error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+	return invoke(ctx)
+}
+
+// retryable tells whether the call may go through when sent again, an
+// attempt running out of its own timeout included.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// Register creates a user. It is not sent again, a second attempt could
+// find the username taken by the first.
+func (c *Client) Register(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) (*models.UserBio, error) {
+	var bio *models.UserBio
+	err := c.call(ctx, "RegisterUser", false, func(ctx context.Context) (err error) {
+		bio, err = c.users.RegisterUser(ctx, user, opts...)
+		return err
+	})
+	return bio, err
+}
+
+func (c *Client) FindUsers(ctx context.Context, opts ...grpc.CallOption) ([]*models.UserBio, error) {
+	var users *models.Users
+	err := c.call(ctx, "FindUsers", true, func(ctx context.Context) (err error) {
+		users, err = c.users.FindUsers(ctx, &emptypb.Empty{}, opts...)
+		return err
+	})
+	return users.GetUser(), err
+}
+
+// FindByUsername fails with ErrNotFound when nobody goes by username.
+func (c *Client) FindByUsername(ctx context.Context, username string, opts ...grpc.CallOption) (*models.UserBio, error) {
+	var bio *models.UserBio
+	err := c.call(ctx, "FindByUsername", true, func(ctx context.Context) (err error) {
+		bio, err = c.users.FindByUsername(ctx, &models.Username{Username: username}, opts...)
+		return err
+	})
+	return bio, err
+}
+
+// DeleteByUsername succeeds when nobody goes by username, which makes it safe
+// to send again.
+func (c *Client) DeleteByUsername(ctx context.Context, username string, opts ...grpc.CallOption) error {
+	return c.call(ctx, "DeleteByUsername", true, func(ctx context.Context) error {
+		_, err := c.users.DeleteByUsername(ctx, &models.Username{Username: username}, opts...)
+		return err
+	})
+}
+
+// Update replaces the user with the id of user.Bio. It is not sent again,
+// every update being recorded in the audit log.
+func (c *Client) Update(ctx context.Context, user *models.UserPayload, opts ...grpc.CallOption) error {
+	return c.call(ctx, "Update", false, func(ctx context.Context) error {
+		_, err := c.users.Update(ctx, user, opts...)
+		return err
+	})
+}
+
+// SuggestUsernames returns users whose username starts with prefix, limit
+// zero leaving the count to user-service.
+func (c *Client) SuggestUsernames(ctx context.Context, prefix string, limit int32, opts ...grpc.CallOption) ([]*models.UserBio, error) {
+	var users *models.Users
+	err := c.call(ctx, "SuggestUsernames", true, func(ctx context.Context) (err error) {
+		users, err = c.users.SuggestUsernames(ctx, &models.SuggestRequest{Prefix: prefix, Limit: limit}, opts...)
+		return err
+	})
+	return users.GetUser(), err
+}
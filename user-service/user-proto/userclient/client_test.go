@@ -0,0 +1,211 @@
+package userclient_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/user-proto/userclient"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// server answers the calls with errs in turn, then succeeds.
+type server struct {
+	models.UnimplementedUserServiceServer
+	models.UnimplementedAuditServiceServer
+	errs   []error
+	calls  int
+	events []*models.AuditEvent
+	asked  []*models.AuditFilter
+	header metadata.MD
+}
+
+func (s *server) fail() error {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+func (s *server) RegisterUser(ctx context.Context, in *models.UserPayload) (*models.UserBio, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	if err := grpc.SetHeader(ctx, s.header); err != nil {
+		return nil, err
+	}
+	return in.Bio, nil
+}
+
+func (s *server) FindUsers(ctx context.Context, in *emptypb.Empty) (*models.Users, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &models.Users{User: []*models.UserBio{{Username: "shoto"}}}, nil
+}
+
+func (s *server) FindByUsername(ctx context.Context, in *models.Username) (*models.UserBio, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &models.UserBio{Username: in.Username}, nil
+}
+
+// ListAuditEvents pages through events, which are sorted newest first.
+func (s *server) ListAuditEvents(ctx context.Context, in *models.AuditFilter) (*models.AuditEvents, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	s.asked = append(s.asked, in)
+	var page []*models.AuditEvent
+	for _, event := range s.events {
+		if (in.BeforeId == 0 || event.Id < in.BeforeId) && len(page) < int(in.Limit) {
+			page = append(page, event)
+		}
+	}
+	return &models.AuditEvents{Events: page}, nil
+}
+
+func dial(t *testing.T, s *server, opts userclient.Options) *userclient.Client {
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	models.RegisterUserServiceServer(srv, s)
+	models.RegisterAuditServiceServer(srv, s)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return userclient.New(conn, opts)
+}
+
+func TestRetries(t *testing.T) {
+	opts := userclient.Options{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	testTable := map[string]struct {
+		errs   []error
+		call   func(c *userclient.Client) error
+		calls  int
+		expect error
+	}{
+		"recovers": {
+			errs: []error{unavailable, unavailable},
+			call: func(c *userclient.Client) error {
+				users, err := c.FindUsers(context.Background())
+				if err == nil && len(users) != 1 {
+					return errors.New("got no users")
+				}
+				return err
+			},
+			calls: 3,
+		},
+		"gives up": {
+			errs: []error{unavailable, unavailable, unavailable},
+			call: func(c *userclient.Client) error {
+				_, err := c.FindByUsername(context.Background(), "shoto")
+				return err
+			},
+			calls:  3,
+			expect: userclient.ErrUnavailable,
+		},
+		"not retryable": {
+			errs: []error{status.Error(codes.NotFound, "no user found")},
+			call: func(c *userclient.Client) error {
+				_, err := c.FindByUsername(context.Background(), "dabi")
+				return err
+			},
+			calls:  1,
+			expect: userclient.ErrNotFound,
+		},
+		"not idempotent": {
+			errs: []error{unavailable},
+			call: func(c *userclient.Client) error {
+				_, err := c.Register(context.Background(), &models.UserPayload{Bio: &models.UserBio{Username: "shoto"}})
+				return err
+			},
+			calls:  1,
+			expect: userclient.ErrUnavailable,
+		},
+	}
+
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			s := &server{errs: test.errs}
+			err := test.call(dial(t, s, opts))
+			require.Equal(t, test.calls, s.calls)
+			if test.expect == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, test.expect)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	s := &server{errs: []error{status.Error(codes.AlreadyExists, "username is already taken")}}
+	_, err := dial(t, s, userclient.Options{}).Register(context.Background(), &models.UserPayload{})
+
+	var typed *userclient.Error
+	require.ErrorAs(t, err, &typed)
+	require.Equal(t, "RegisterUser", typed.Method)
+	require.Equal(t, "username is already taken", typed.Message)
+	require.ErrorIs(t, err, userclient.ErrAlreadyExists)
+	require.NotErrorIs(t, err, userclient.ErrNotFound)
+	require.Equal(t, codes.AlreadyExists, status.Code(err), "the status is kept")
+}
+
+func TestCallOptions(t *testing.T) {
+	s := &server{header: metadata.Pairs("x-read-primary-until", "1700000005000")}
+	var header metadata.MD
+	_, err := dial(t, s, userclient.Options{}).Register(context.Background(), &models.UserPayload{Bio: &models.UserBio{Username: "shoto"}}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1700000005000"}, header.Get("x-read-primary-until"))
+}
+
+func TestTimeout(t *testing.T) {
+	s := &server{errs: []error{status.Error(codes.DeadlineExceeded, "took too long")}}
+	c := dial(t, s, userclient.Options{Attempts: 1, Timeout: time.Second})
+	_, err := c.FindUsers(context.Background())
+	require.ErrorIs(t, err, userclient.ErrTimeout)
+}
+
+func TestAuditEvents(t *testing.T) {
+	s := &server{errs: []error{status.Error(codes.Unavailable, "connection refused")}}
+	for id := int64(7); id > 0; id-- {
+		s.events = append(s.events, &models.AuditEvent{Id: id, Actor: "admin"})
+	}
+	c := dial(t, s, userclient.Options{InitialBackoff: time.Millisecond, PageSize: 3})
+	filter := &models.AuditFilter{Actor: "admin", BeforeId: 7}
+
+	events := c.AuditEvents(context.Background(), filter)
+	var ids []int64
+	for events.Next() {
+		ids = append(ids, events.Event().Id)
+	}
+	require.NoError(t, events.Err())
+	require.Equal(t, []int64{6, 5, 4, 3, 2, 1}, ids)
+	require.Equal(t, 3, len(s.asked), "a full page is followed by one more")
+	require.Equal(t, int64(4), s.asked[1].BeforeId)
+	require.Equal(t, "admin", s.asked[1].Actor)
+	require.Equal(t, int64(7), filter.BeforeId, "the filter is left as it was")
+
+	s.errs, s.calls = []error{status.Error(codes.InvalidArgument, "since must be before until")}, 0
+	events = c.AuditEvents(context.Background(), nil)
+	require.False(t, events.Next())
+	require.ErrorIs(t, events.Err(), userclient.ErrInvalidArgument)
+}
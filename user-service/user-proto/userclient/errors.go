@@ -0,0 +1,65 @@
+package userclient
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The sentinels an *Error matches with errors.Is, by the code user-service
+// answered with.
+var (
+	ErrNotFound         = errors.New("userclient: not found")
+	ErrAlreadyExists    = errors.New("userclient: already exists")
+	ErrInvalidArgument  = errors.New("userclient: invalid argument")
+	ErrPermissionDenied = errors.New("userclient: permission denied")
+	ErrUnavailable      = errors.New("userclient: user-service is unavailable")
+	ErrTimeout          = errors.New("userclient: deadline exceeded")
+)
+
+var sentinels = map[codes.Code]error{
+	codes.NotFound:          ErrNotFound,
+	codes.AlreadyExists:     ErrAlreadyExists,
+	codes.InvalidArgument:   ErrInvalidArgument,
+	codes.PermissionDenied:  ErrPermissionDenied,
+	codes.Unauthenticated:   ErrPermissionDenied,
+	codes.Unavailable:       ErrUnavailable,
+	codes.ResourceExhausted: ErrUnavailable,
+	codes.DeadlineExceeded:  ErrTimeout,
+}
+
+// Error is what a call fails with once user-service answered it or could not
+// be reached. It still carries its gRPC status for status.Code and friends.
+type Error struct {
+	Method  string
+	Code    codes.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Method, e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	sentinel, ok := sentinels[e.Code]
+	return ok && sentinel == target
+}
+
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// wrap turns the status err carries into an *Error, errors without one are
+// returned as they are.
+func wrap(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return &Error{Method: method, Code: st.Code(), Message: st.Message()}
+}
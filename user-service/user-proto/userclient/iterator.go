@@ -0,0 +1,173 @@
+package userclient
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// The most user-service answers with at a time, larger pages are cut down
+// to them.
+const (
+	maxAuditPage    = 500
+	maxDeliveryPage = 200
+)
+
+// pager walks a listing newest first a page at a time, asking for the items
+// before the last one it was given. A page shorter than asked for is the last.
+type pager struct {
+	ctx    context.Context
+	size   int32
+	before int64
+	done   bool
+	err    error
+}
+
+// next fetches the following page, false once there is none.
+func (p *pager) next(fetch func(ctx context.Context, before int64, size int32) (n int, last int64, err error)) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	n, last, err := fetch(p.ctx, p.before, p.size)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	p.before = last
+	p.done = n < int(p.size)
+	return n > 0
+}
+
+// AuditEventIterator goes through audit events newest first:
+//
+//	events := client.AuditEvents(ctx, &models.AuditFilter{Actor: "admin"})
+//	for events.Next() {
+//		event := events.Event()
+//	}
+//	if err := events.Err(); err != nil {
+//		return err
+//	}
+type AuditEventIterator struct {
+	pager
+	filter *models.AuditFilter
+	page   []*models.AuditEvent
+	event  *models.AuditEvent
+	client *Client
+}
+
+// AuditEvents iterates over the audit events filter matches, its Limit is
+// the page size and its BeforeId where to start from.
+func (c *Client) AuditEvents(ctx context.Context, filter *models.AuditFilter) *AuditEventIterator {
+	if filter == nil {
+		filter = &models.AuditFilter{}
+	}
+	filter = proto.Clone(filter).(*models.AuditFilter)
+	size := filter.Limit
+	if size <= 0 {
+		size = c.opts.PageSize
+	}
+	if size > maxAuditPage {
+		size = maxAuditPage
+	}
+	return &AuditEventIterator{
+		pager:  pager{ctx: ctx, size: size, before: filter.BeforeId},
+		filter: filter,
+		client: c,
+	}
+}
+
+func (it *AuditEventIterator) Next() bool {
+	if len(it.page) == 0 && !it.pager.next(it.fetch) {
+		return false
+	}
+	it.event, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *AuditEventIterator) fetch(ctx context.Context, before int64, size int32) (int, int64, error) {
+	it.filter.BeforeId, it.filter.Limit = before, size
+	var events *models.AuditEvents
+	err := it.client.call(ctx, "ListAuditEvents", true, func(ctx context.Context) (err error) {
+		events, err = it.client.audit.ListAuditEvents(ctx, it.filter)
+		return err
+	})
+	if err != nil || len(events.Events) == 0 {
+		return 0, before, err
+	}
+	it.page = events.Events
+	return len(it.page), it.page[len(it.page)-1].Id, nil
+}
+
+// Event is the event Next moved to.
+func (it *AuditEventIterator) Event() *models.AuditEvent {
+	return it.event
+}
+
+// Err is why Next stopped early, nil when the events ran out.
+func (it *AuditEventIterator) Err() error {
+	return it.err
+}
+
+// DeliveryIterator goes through webhook deliveries newest first, the same
+// way AuditEventIterator does.
+type DeliveryIterator struct {
+	pager
+	filter   *models.DeliveryFilter
+	page     []*models.Delivery
+	delivery *models.Delivery
+	client   *Client
+}
+
+// Deliveries iterates over the deliveries filter matches, its Limit is the
+// page size and its BeforeId where to start from.
+func (c *Client) Deliveries(ctx context.Context, filter *models.DeliveryFilter) *DeliveryIterator {
+	if filter == nil {
+		filter = &models.DeliveryFilter{}
+	}
+	filter = proto.Clone(filter).(*models.DeliveryFilter)
+	size := filter.Limit
+	if size <= 0 {
+		size = c.opts.PageSize
+	}
+	if size > maxDeliveryPage {
+		size = maxDeliveryPage
+	}
+	return &DeliveryIterator{
+		pager:  pager{ctx: ctx, size: size, before: filter.BeforeId},
+		filter: filter,
+		client: c,
+	}
+}
+
+func (it *DeliveryIterator) Next() bool {
+	if len(it.page) == 0 && !it.pager.next(it.fetch) {
+		return false
+	}
+	it.delivery, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *DeliveryIterator) fetch(ctx context.Context, before int64, size int32) (int, int64, error) {
+	it.filter.BeforeId, it.filter.Limit = before, size
+	var deliveries *models.Deliveries
+	err := it.client.call(ctx, "FindDeliveries", true, func(ctx context.Context) (err error) {
+		deliveries, err = it.client.webhooks.FindDeliveries(ctx, it.filter)
+		return err
+	})
+	if err != nil || len(deliveries.Deliveries) == 0 {
+		return 0, before, err
+	}
+	it.page = deliveries.Deliveries
+	return len(it.page), it.page[len(it.page)-1].Id, nil
+}
+
+// Delivery is the delivery Next moved to.
+func (it *DeliveryIterator) Delivery() *models.Delivery {
+	return it.delivery
+}
+
+// Err is why Next stopped early, nil when the deliveries ran out.
+func (it *DeliveryIterator) Err() error {
+	return it.err
+}
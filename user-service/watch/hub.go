@@ -0,0 +1,219 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spriigan/RPApp/usecases/repository"
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"golang.org/x/exp/slog"
+)
+
+var ErrSlowSubscriber = errors.New("subscriber could not keep up with the change stream")
+
+// Subscription receives every change the hub sees after it was created. C is
+// closed when the subscriber falls more than the hub buffer behind, Err then
+// tells why.
+type Subscription struct {
+	C   <-chan *models.UserChange
+	ch  chan *models.UserChange
+	hub *Hub
+	err error
+}
+
+// Hub follows the user_changes log, woken up by Postgres notifications and by
+// a periodic poll in case one is missed, and fans every change out to its
+// subscribers.
+type Hub struct {
+	repo      repository.ChangeRepository
+	buffer    int
+	batch     int
+	poll      time.Duration
+	retention time.Duration
+
+	mu     sync.Mutex
+	last   int64
+	ready  bool
+	subs   map[*Subscription]struct{}
+	wake   chan struct{}
+	closed bool
+}
+
+func NewHub(repo repository.ChangeRepository, buffer int, poll, retention time.Duration) *Hub {
+	return &Hub{
+		repo:      repo,
+		buffer:    buffer,
+		batch:     100,
+		poll:      poll,
+		retention: retention,
+		subs:      make(map[*Subscription]struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (h *Hub) Subscribe() *Subscription {
+	ch := make(chan *models.UserChange, h.buffer)
+	sub := &Subscription{C: ch, ch: ch, hub: h}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		sub.err = context.Canceled
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Len is the number of live subscriptions.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.ch)
+	}
+}
+
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (h *Hub) broadcast(changes []*models.UserChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, change := range changes {
+		for sub := range h.subs {
+			select {
+			case sub.ch <- change:
+			default:
+				sub.err = ErrSlowSubscriber
+				delete(h.subs, sub)
+				close(sub.ch)
+			}
+		}
+		h.last = change.Id
+	}
+}
+
+func (h *Hub) cursor() (int64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last, h.ready
+}
+
+// Start positions the hub at the latest recorded change so only changes made
+// from now on are broadcast.
+func (h *Hub) Start(ctx context.Context) error {
+	last, err := h.repo.LatestChangeID(ctx)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = last
+	h.ready = true
+	return nil
+}
+
+// Flush broadcasts every change recorded since the last one seen.
+func (h *Hub) Flush(ctx context.Context) error {
+	for {
+		last, ready := h.cursor()
+		if !ready {
+			return nil
+		}
+		changes, err := h.repo.FindChanges(ctx, last, h.batch)
+		if err != nil {
+			return err
+		}
+		h.broadcast(changes)
+		if len(changes) < h.batch {
+			return nil
+		}
+	}
+}
+
+func (h *Hub) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) {
+	for {
+		err := h.repo.ListenChanges(ctx, h.notify)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("lost the user change notifications, listening again", "error", err)
+		h.notify()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		sub.err = context.Canceled
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Run follows the change log until ctx is done, then closes every
+// subscription. Changes older than the retention are pruned on the way.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.closeAll()
+	for {
+		err := h.Start(ctx)
+		if err == nil {
+			break
+		}
+		slog.Warn("user change hub is not ready yet", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	go h.listen(ctx)
+
+	poll := time.NewTicker(h.poll)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prune.C:
+			if _, err := h.repo.PruneChanges(ctx, time.Now().Add(-h.retention)); err != nil && ctx.Err() == nil {
+				slog.Error("failed to prune user changes", "error", err)
+			}
+			continue
+		case <-h.wake:
+		case <-poll.C:
+		}
+		if err := h.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to read user changes", "error", err)
+		}
+	}
+}
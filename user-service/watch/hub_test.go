@@ -0,0 +1,141 @@
+package watch_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/user-proto/grpc/models"
+	"github.com/spriigan/RPApp/watch"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryChanges struct {
+	mu      sync.Mutex
+	changes []*models.UserChange
+	notify  func()
+}
+
+func (m *memoryChanges) record(n int) {
+	m.mu.Lock()
+	for i := 0; i < n; i++ {
+		m.changes = append(m.changes, &models.UserChange{Id: int64(len(m.changes) + 1), Type: "user.updated"})
+	}
+	notify := m.notify
+	m.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+func (m *memoryChanges) FindChanges(ctx context.Context, afterID int64, limit int) ([]*models.UserChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]*models.UserChange, 0, limit)
+	for _, change := range m.changes {
+		if change.Id > afterID && len(result) < limit {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryChanges) LatestChangeID(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.changes)), nil
+}
+
+func (m *memoryChanges) ListenChanges(ctx context.Context, notify func()) error {
+	m.mu.Lock()
+	m.notify = notify
+	m.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *memoryChanges) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func ids(sub *watch.Subscription, n int) []int64 {
+	result := make([]int64, 0, n)
+	for i := 0; i < n; i++ {
+		change, ok := <-sub.C
+		if !ok {
+			break
+		}
+		result = append(result, change.Id)
+	}
+	return result
+}
+
+func TestFlush(t *testing.T) {
+	repo := &memoryChanges{}
+	repo.record(3)
+	hub := watch.NewHub(repo, 10, time.Hour, time.Hour)
+	ctx := context.Background()
+	sub := hub.Subscribe()
+	defer sub.Close()
+
+	require.NoError(t, hub.Flush(ctx))
+	require.Empty(t, sub.C, "nothing is broadcast before the hub is started")
+
+	require.NoError(t, hub.Start(ctx))
+	repo.record(2)
+	require.NoError(t, hub.Flush(ctx))
+	require.Equal(t, []int64{4, 5}, ids(sub, 2))
+	require.NoError(t, sub.Err())
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	repo := &memoryChanges{}
+	hub := watch.NewHub(repo, 2, time.Hour, time.Hour)
+	ctx := context.Background()
+	require.NoError(t, hub.Start(ctx))
+	slow, fast := hub.Subscribe(), hub.Subscribe()
+
+	repo.record(2)
+	require.NoError(t, hub.Flush(ctx))
+	require.Equal(t, []int64{1, 2}, ids(fast, 2))
+	repo.record(1)
+	require.NoError(t, hub.Flush(ctx))
+
+	require.Equal(t, []int64{1, 2}, ids(slow, 3))
+	require.ErrorIs(t, slow.Err(), watch.ErrSlowSubscriber)
+	require.Equal(t, []int64{3}, ids(fast, 1))
+	slow.Close()
+	fast.Close()
+}
+
+func TestRun(t *testing.T) {
+	repo := &memoryChanges{}
+	repo.record(1)
+	hub := watch.NewHub(repo, 10, time.Hour, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	sub := hub.Subscribe()
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return repo.notify != nil
+	}, time.Second, time.Millisecond)
+	repo.record(1)
+	require.Equal(t, []int64{2}, ids(sub, 1))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hub did not stop")
+	}
+	_, ok := <-sub.C
+	require.False(t, ok)
+	require.ErrorIs(t, sub.Err(), context.Canceled)
+}
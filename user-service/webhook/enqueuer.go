@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/spriigan/RPApp/outbox"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Enqueuer is an outbox.Publisher turning every relayed event into webhook
+// deliveries. Receivers get the envelope as JSON with proto field names.
+type Enqueuer struct {
+	store Store
+}
+
+func NewEnqueuer(store Store) *Enqueuer {
+	return &Enqueuer{store: store}
+}
+
+func (e *Enqueuer) Publish(ctx context.Context, msg outbox.Message) error {
+	var envelope eventsv1.Envelope
+	if err := proto.Unmarshal(msg.Payload, &envelope); err != nil {
+		return err
+	}
+	body, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(&envelope)
+	if err != nil {
+		return err
+	}
+	return e.store.Enqueue(ctx, msg.ID, msg.Type, body)
+}
+
+func (e *Enqueuer) Close() error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package webhook_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spriigan/RPApp/outbox"
+	eventsv1 "github.com/spriigan/RPApp/user-proto/events/v1"
+	"github.com/spriigan/RPApp/webhook"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEnqueuerPublish(t *testing.T) {
+	envelope := &eventsv1.Envelope{
+		Id:   "evt-1",
+		Type: "user.deleted",
+		Payload: &eventsv1.Envelope_UserDeleted{
+			UserDeleted: &eventsv1.UserDeleted{Id: 4, Username: "dabi"},
+		},
+	}
+	payload, err := proto.Marshal(envelope)
+	require.NoError(t, err)
+	store := &memoryStore{}
+
+	err = webhook.NewEnqueuer(store).Publish(context.Background(), outbox.Message{ID: "evt-1", Type: "user.deleted", Payload: payload})
+
+	require.NoError(t, err)
+	require.Len(t, store.enqueued, 1)
+	require.Contains(t, store.enqueued[0], `"user_deleted"`)
+	require.Contains(t, store.enqueued[0], `"username":"dabi"`)
+
+	err = webhook.NewEnqueuer(store).Publish(context.Background(), outbox.Message{ID: "evt-2", Payload: []byte("garbage")})
+	require.Error(t, err)
+}
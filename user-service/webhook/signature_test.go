@@ -0,0 +1,73 @@
+package webhook_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"user.created"}`)
+	signature := webhook.Sign("whsec_test", now.Unix(), body)
+	testTable := map[string]struct {
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		expect    error
+	}{
+		"valid": {
+			secret:    "whsec_test",
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: signature,
+			body:      body,
+		},
+		"wrong secret": {
+			secret:    "whsec_other",
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: signature,
+			body:      body,
+			expect:    webhook.ErrInvalidSignature,
+		},
+		"tampered body": {
+			secret:    "whsec_test",
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: signature,
+			body:      []byte(`{"type":"user.deleted"}`),
+			expect:    webhook.ErrInvalidSignature,
+		},
+		"replayed with another timestamp": {
+			secret:    "whsec_test",
+			timestamp: strconv.FormatInt(now.Unix()+1, 10),
+			signature: signature,
+			body:      body,
+			expect:    webhook.ErrInvalidSignature,
+		},
+		"stale": {
+			secret:    "whsec_test",
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: webhook.Sign("whsec_test", now.Add(-10*time.Minute).Unix(), body),
+			body:      body,
+			expect:    webhook.ErrStaleSignature,
+		},
+		"malformed timestamp": {
+			secret:    "whsec_test",
+			timestamp: "yesterday",
+			signature: signature,
+			body:      body,
+			expect:    webhook.ErrInvalidSignature,
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			err := webhook.Verify(v.secret, v.timestamp, v.signature, v.body, 5*time.Minute, now)
+
+			require.Equal(t, v.expect, err)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"golang.org/x/exp/slog"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	// StatusDelivering is a delivery a worker claimed and is attempting.
+	StatusDelivering = "delivering"
+	StatusSucceeded  = "succeeded"
+	StatusDead       = "dead"
+)
+
+// Delivery is one event to send to one webhook.
+type Delivery struct {
+	ID        int64
+	WebhookID int64
+	URL       string
+	Secret    string
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+}
+
+// Outcome is what a delivery attempt produced and the state it moves the
+// delivery to.
+type Outcome struct {
+	StatusCode    int
+	Error         string
+	Duration      time.Duration
+	Status        string
+	NextAttemptAt time.Time
+}
+
+type Store interface {
+	// Enqueue creates a pending delivery of the event for every active webhook
+	// subscribed to its type. Enqueueing the same event twice is a no-op.
+	Enqueue(ctx context.Context, eventID, eventType string, payload []byte) error
+	// DeliverDue hands up to limit pending deliveries whose next attempt is due
+	// to deliver and records the outcome of each. No transaction is held open
+	// while deliver runs.
+	DeliverDue(ctx context.Context, limit int, deliver func(ctx context.Context, d Delivery) Outcome) (int, error)
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter randomizes each delay by up to this fraction, in both directions.
+	Jitter float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 8,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    time.Hour,
+	Jitter:      0.2,
+}
+
+// Backoff is the wait after the given failed attempt, starting at 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+type Worker struct {
+	store    Store
+	client   *http.Client
+	policy   RetryPolicy
+	interval time.Duration
+	batch    int
+	now      func() time.Time
+}
+
+func NewWorker(store Store, client *http.Client, policy RetryPolicy, interval time.Duration, batch int) *Worker {
+	return &Worker{
+		store:    store,
+		client:   client,
+		policy:   policy,
+		interval: interval,
+		batch:    batch,
+		now:      time.Now,
+	}
+}
+
+func (w *Worker) Flush(ctx context.Context) (int, error) {
+	return w.store.DeliverDue(ctx, w.batch, w.Deliver)
+}
+
+// Run delivers due webhooks every interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("webhook worker failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver posts the payload once and decides whether the delivery succeeded,
+// should be retried later or is given up on.
+func (w *Worker) Deliver(ctx context.Context, d Delivery) Outcome {
+	start := w.now()
+	outcome := Outcome{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err == nil {
+		timestamp := start.Unix()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "RPApp-Webhooks/1.0")
+		req.Header.Set(HeaderEvent, d.EventType)
+		req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, d.Payload))
+
+		var res *http.Response
+		res, err = w.client.Do(req)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+			outcome.StatusCode = res.StatusCode
+			if res.StatusCode < 200 || res.StatusCode > 299 {
+				err = fmt.Errorf("receiver answered %s", res.Status)
+			}
+		}
+	}
+	outcome.Duration = w.now().Sub(start)
+
+	if err == nil {
+		outcome.Status = StatusSucceeded
+		return outcome
+	}
+	outcome.Error = err.Error()
+	attempt := d.Attempts + 1
+	if attempt >= w.policy.MaxAttempts {
+		outcome.Status = StatusDead
+		return outcome
+	}
+	outcome.Status = StatusPending
+	outcome.NextAttemptAt = w.now().Add(w.policy.Backoff(attempt))
+	return outcome
+}
@@ -0,0 +1,160 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spriigan/RPApp/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu         sync.Mutex
+	enqueued   []string
+	deliveries []webhook.Delivery
+	outcomes   []webhook.Outcome
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, eventID, eventType string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueued = append(s.enqueued, eventID+" "+eventType+" "+string(payload))
+	return nil
+}
+
+func (s *memoryStore) DeliverDue(ctx context.Context, limit int, deliver func(ctx context.Context, d webhook.Delivery) webhook.Outcome) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i := range s.deliveries {
+		if n == limit {
+			break
+		}
+		outcome := deliver(ctx, s.deliveries[i])
+		s.deliveries[i].Attempts++
+		s.outcomes = append(s.outcomes, outcome)
+		n++
+	}
+	return n, nil
+}
+
+func TestBackoff(t *testing.T) {
+	policy := webhook.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	require.Equal(t, time.Second, policy.Backoff(1))
+	require.Equal(t, 2*time.Second, policy.Backoff(2))
+	require.Equal(t, 8*time.Second, policy.Backoff(4))
+	require.Equal(t, 10*time.Second, policy.Backoff(5))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.Backoff(2)
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.LessOrEqual(t, delay, 3*time.Second)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	policy := webhook.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	testTable := map[string]struct {
+		status   int
+		attempts int
+		assert   func(t *testing.T, outcome webhook.Outcome)
+	}{
+		"succes call": {
+			status: http.StatusNoContent,
+			assert: func(t *testing.T, outcome webhook.Outcome) {
+				require.Equal(t, webhook.StatusSucceeded, outcome.Status)
+				require.Equal(t, http.StatusNoContent, outcome.StatusCode)
+				require.Empty(t, outcome.Error)
+			},
+		},
+		"retried later": {
+			status: http.StatusInternalServerError,
+			assert: func(t *testing.T, outcome webhook.Outcome) {
+				require.Equal(t, webhook.StatusPending, outcome.Status)
+				require.Equal(t, http.StatusInternalServerError, outcome.StatusCode)
+				require.NotEmpty(t, outcome.Error)
+				require.WithinDuration(t, time.Now().Add(time.Minute), outcome.NextAttemptAt, 5*time.Second)
+			},
+		},
+		"dead after the last attempt": {
+			status:   http.StatusGone,
+			attempts: 2,
+			assert: func(t *testing.T, outcome webhook.Outcome) {
+				require.Equal(t, webhook.StatusDead, outcome.Status)
+				require.True(t, outcome.NextAttemptAt.IsZero())
+			},
+		},
+	}
+
+	for k, v := range testTable {
+		t.Run(k, func(t *testing.T) {
+			body := []byte(`{"id":"evt"}`)
+			var received http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				payload, _ := io.ReadAll(r.Body)
+				require.Equal(t, body, payload)
+				received = r.Header.Clone()
+				w.WriteHeader(v.status)
+			}))
+			defer server.Close()
+			worker := webhook.NewWorker(&memoryStore{}, server.Client(), policy, time.Second, 10)
+
+			outcome := worker.Deliver(context.Background(), webhook.Delivery{
+				ID:        7,
+				URL:       server.URL,
+				Secret:    "whsec_test",
+				EventType: "user.created",
+				Payload:   body,
+				Attempts:  v.attempts,
+			})
+
+			v.assert(t, outcome)
+			require.Equal(t, "user.created", received.Get(webhook.HeaderEvent))
+			require.Equal(t, "7", received.Get(webhook.HeaderDelivery))
+			require.NoError(t, webhook.Verify("whsec_test", received.Get(webhook.HeaderTimestamp),
+				received.Get(webhook.HeaderSignature), body, time.Minute, time.Now()))
+		})
+	}
+}
+
+func TestDeliverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	worker := webhook.NewWorker(&memoryStore{}, http.DefaultClient, webhook.DefaultRetryPolicy, time.Second, 10)
+
+	outcome := worker.Deliver(context.Background(), webhook.Delivery{URL: url})
+
+	require.Equal(t, webhook.StatusPending, outcome.Status)
+	require.Zero(t, outcome.StatusCode)
+	require.NotEmpty(t, outcome.Error)
+}
+
+func TestFlush(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	store := &memoryStore{deliveries: []webhook.Delivery{{ID: 1, URL: server.URL}, {ID: 2, URL: server.URL}, {ID: 3, URL: server.URL}}}
+	worker := webhook.NewWorker(store, server.Client(), webhook.DefaultRetryPolicy, time.Second, 2)
+
+	n, err := worker.Flush(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, 2, calls)
+	for _, outcome := range store.outcomes {
+		require.Equal(t, webhook.StatusSucceeded, outcome.Status)
+	}
+}